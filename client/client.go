@@ -2,19 +2,31 @@ package main
 
 // imported packages
 import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"maps"
+	"math"
 	"net"
 	"os"
-	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/eiannone/keyboard"
+	"github.com/faiface/beep"
 	"github.com/faiface/beep/mp3"
 	"github.com/faiface/beep/speaker"
 	"golang.org/x/crypto/ssh/terminal"
@@ -31,7 +43,63 @@ const (
 	CONNECTION_TYPE = "tcp"
 
 	// other
-	MAX_PACKET_SIZE = 1024
+	MAX_PACKET_SIZE  = 1024
+	PACKET_DELIMITER = '\n' // marks the end of a packet on the wire
+
+	// reconnecting
+	RECONNECT_MIN_DELAY = 1 * time.Second  // wait before the first attempt to reconnect
+	RECONNECT_MAX_DELAY = 30 * time.Second // longest wait between attempts to reconnect
+	CONNECT_TIMEOUT     = 5 * time.Second  // how long to wait for the server to open the data socket
+
+	// remember me
+	REMEMBER_ME_PATH = "./session.json" // where the device name and session token are kept between launches
+
+	// chat strand
+	HISTORY_TIMEOUT   = 10 * time.Second // how long the history of a channel that was joined is waited for
+	QUOTE_LENGTH      = 40               // longest quote of a message shown above a reply to it
+	BUBBLE_MIN_WIDTH  = 26               // fewest columns inside a message bubble, unless the strand is narrower
+	BUBBLE_MAX_WIDTH  = 60               // most columns inside a message bubble, so lines stay easy to read
+	MAX_PREVIEW_LINES = 12               // lines of a message shown in the chat strand before it is cut short
+	SCROLL_STEP       = 3                // rows the arrow keys scroll the chat strand by
+	CODE_FENCE        = "```"            // starts and ends a code block in a message
+	CODE_BACKGROUND   = "\x1b[48;5;236m" // background of code blocks
+	PASTE_MODE_PROMPT = "paste mode - enter adds a line, ctrl+d sends, esc cancels"
+
+	// typing indicators
+	TYPING_INTERVAL = 3 * time.Second // how often the channel is told again that the user is still typing
+	TYPING_TIMEOUT  = 6 * time.Second // how long someone is shown as typing without being heard from again
+
+	// main menu
+	MENTIONS_OPTION        = "MENTIONS"        // option of the main menu that opens the mentions inbox
+	DIRECT_MESSAGES_OPTION = "DIRECT MESSAGES" // option of the main menu that opens direct messages
+
+	// sounds
+	MENTION_SOUND_RATIO = 1.5 // how much faster and higher the receive sound is played for mentions
+
+	// file transfers
+	DOWNLOADS_DIR       = "./downloads"    // where downloaded files are saved
+	PART_SUFFIX         = ".part"          // added to the path of a file while it is being downloaded
+	MAX_FILE_SIZE       = 10 * 1024 * 1024 // largest file the server takes
+	TRANSFER_CHUNK_SIZE = 32 * 1024        // bytes of a file sent in each chunk
+	TRANSFER_TIMEOUT    = 30 * time.Second // how long a transfer may wait for the server before it is dropped
+
+	// chat screen
+	SIDEBAR_WIDTH     = 24              // columns of the channel list beside the chat strand, including its border
+	SIDEBAR_MIN_WIDTH = 80              // narrowest terminal the channel list is shown on
+	TAB_WIDTH         = 8               // columns between tab stops
+	HELP_COLUMN       = 40              // column the descriptions on the help screen line up at
+	NOTICE_DURATION   = 5 * time.Second // how long a notice stays across the top of the screen
+)
+
+// terminal control sequences
+const (
+	ALTERNATE_SCREEN = "\x1b[?1049h" // switches to a screen of its own so the shell is left as it was
+	MAIN_SCREEN      = "\x1b[?1049l" // switches back to the screen of the shell
+	HIDE_CURSOR      = "\x1b[?25l"
+	SHOW_CURSOR      = "\x1b[?25h"
+	CLEAR_LINE       = "\x1b[K"      // clears the rest of the line the cursor is on
+	CLEAR_SCREEN     = "\x1b[2J"     // clears every row, used when the terminal changes size
+	MOVE_CURSOR      = "\x1b[%d;%dH" // moves the cursor to a row and column, counted from 1
 )
 
 // ansi text styles
@@ -54,21 +122,51 @@ const (
 
 // maps commands to integers
 var command_to_int = map[string]int{
-	"/help":         1,
-	"/exit":         2,
-	"/main":         3,
-	"/log_out":      4,
-	"/list-c":       5,
-	"/list-s":       6,
-	"/disconnect-c": 7,
-	"/disconnect-s": 8,
-	"/ban-c":        9,
-	"/ban-s":        10,
-	"/create":       11,
-	"/delete":       12,
-	"/change-topic": 13,
-	"/add-mod":      14,
-	"/rm-mod":       15,
+	"/help":            1,
+	"/exit":            2,
+	"/main":            3,
+	"/log_out":         4,
+	"/list-c":          5,
+	"/list-s":          6,
+	"/disconnect-c":    7,
+	"/disconnect-s":    8,
+	"/ban-c":           9,
+	"/ban-s":           10,
+	"/create":          11,
+	"/delete":          12,
+	"/change-topic":    13,
+	"/add-mod":         14,
+	"/rm-mod":          15,
+	"/lockouts":        17,
+	"/unlock":          18,
+	"/log_out_all":     21,
+	"/devices":         22,
+	"/revoke":          23,
+	"/msg":             24,
+	"/invite":          26,
+	"/join":            27,
+	"/kick":            28,
+	"/add-channel-mod": 30,
+	"/rm-channel-mod":  31,
+	"/roles":           32,
+	"/create-role":     33,
+	"/delete-role":     34,
+	"/grant-role":      35,
+	"/revoke-role":     36,
+	"/edit":            37,
+	"/delete-msg":      38,
+	"/reply":           39,
+	"/thread":          40,
+	"/react":           41,
+	"/search":          44,
+	"/pin":             46,
+	"/unpin":           47,
+	"/set-description": 48,
+	"/pins":            49,
+	"/upload":          50,
+	"/download":        51,
+	"/view":            52,
+	"/paste":           53,
 }
 
 // commands types
@@ -97,6 +195,79 @@ const (
 
 	// system
 	CONNECT // used to establish data socket connection
+
+	// admin commands
+	LOCKOUTS // lists accounts and addresses with failed logins
+	UNLOCK   // clears the lockout of an account or address
+
+	// system
+	PING // sent by the server to check that the client is still there
+	PONG // sent in response to a ping
+
+	// public commands
+	LOG_OUT_ALL // logs the account out on every device
+	DEVICES     // lists the devices the account is signed in on
+	REVOKE      // signs the account out on one device
+	MSG         // sends a direct message to a user
+
+	// system
+	DIRECT_MSG // used by the server to deliver a direct message
+
+	// public commands
+	INVITE // adds a user to the members of the current channel
+	JOIN   // joins a password protected channel
+
+	// moderator commands
+	KICK // removes a user from the current channel
+
+	// system
+	REMOVED // used by the server to say the client was removed from its channel
+
+	// channel owner commands
+	ADD_CHANNEL_MOD // makes a user a moderator of the current channel
+	RM_CHANNEL_MOD  // takes the moderator role of the current channel away from a user
+
+	// role management commands
+	ROLES       // lists every role and its permissions
+	CREATE_ROLE // creates a custom role from a set of permissions
+	DELETE_ROLE // deletes a custom role
+	GRANT_ROLE  // gives a custom role to a user
+	REVOKE_ROLE // takes a custom role away from a user
+
+	// public commands
+	EDIT_MSG   // changes the text of a message
+	DELETE_MSG // deletes a message
+
+	// client commands, these are handled without asking the server
+	REPLY  // sends a message in reply to another message
+	THREAD // shows only the replies around one message
+
+	// public commands
+	REACT    // adds a reaction to a message or takes it away
+	MENTIONS // gets the messages the user was mentioned in
+
+	// system
+	MENTION // tells the client it was mentioned in a message
+
+	// public commands
+	SEARCH         // finds messages by their words, channel, author and date
+	SEARCH_CONTEXT // gets the messages around a search result
+
+	// moderator commands
+	PIN             // pins a message to the channel
+	UNPIN           // takes a message off the pins of the channel
+	SET_DESCRIPTION // changes the description of the channel
+
+	// client commands, these are handled without asking the server
+	PINS // shows only the pinned messages of the channel
+
+	// public commands
+	UPLOAD   // sends a file to share in the channel
+	DOWNLOAD // gets a file that was shared in a channel
+
+	// client commands, these are handled without asking the server
+	VIEW  // shows the whole of a long message
+	PASTE // lets the next message have more than one line
 )
 
 // client states
@@ -112,17 +283,32 @@ const (
 
 // packet types
 const (
-	ACCEPT       = iota // 0	Used to indicate a name or password was accepted
-	DENY                // 1	Used to indicate a name or password was denied
-	MESSAGE             // 2	Used to send a standard message to a channel
-	JOIN_MSG            // 3 Used to send a joining message to a chat
-	LEAVE_MSG           // 4 Used to send a leaving message to a chat
-	REGISTRATION        // 5	Used to send a username or password for registering a user
-	LOGIN               // 6	Used to send a username or password for loggin in
-	MENU_OPTION         // 7	Used to send menu options
-	CLOSE               // 8 Used to close a function if the state changes of the client
-	ESC                 // 9 used when a user uses escape to go back
-	REFRESH             // 10 used to refresh certain screens
+	ACCEPT        = iota // 0	Used to indicate a name or password was accepted
+	DENY                 // 1	Used to indicate a name or password was denied
+	MESSAGE              // 2	Used to send a standard message to a channel
+	JOIN_MSG             // 3 Used to send a joining message to a chat
+	LEAVE_MSG            // 4 Used to send a leaving message to a chat
+	REGISTRATION         // 5	Used to send a username or password for registering a user
+	LOGIN                // 6	Used to send a username or password for loggin in
+	MENU_OPTION          // 7	Used to send menu options
+	CLOSE                // 8 Used to close a function if the state changes of the client
+	ESC                  // 9 used when a user uses escape to go back
+	REFRESH              // 10 used to refresh certain screens
+	RATE_LIMITED         // 11 used when the server says the client is sending too quickly
+	RESUME               // 12 used to resume a session after reconnecting
+	SESSION_TOKEN        // 13 used by the server to give the client a token for resuming its session
+	HISTORY              // 14 used to ask for the messages sent after a given message id, and by the server to give them
+	MESSAGE_SENT         // 15 used by the server to give the id of a message the client sent
+	EDITED               // 16 used by the server to give the new text of a message
+	DELETED              // 17 used by the server to say a message was deleted
+	REACTED              // 18 used by the server to give the reactions to a message
+	MENTIONED            // 19 used to show a mention from another channel in the chat strand
+	READ_MARKER          // 20 used by the server to give the last message the user read in a channel
+	TYPING               // 21 used to say a user is typing in a channel
+	NOT_TYPING           // 22 used to say a user stopped typing in a channel
+	CHANNEL_INFO         // 23 used by the server to give the name, topic, description and pins of the channel
+	TRANSFER             // 24 used to show how a file transfer went in the chat strand
+	OLDER_HISTORY        // 25 used to ask for the messages sent before a given message id, and by the server to give a page of them
 )
 
 // roles for the client
@@ -143,9 +329,111 @@ const (
 
 // struct to hold packet information
 type Data_packet struct {
-	Type     int
-	Username string
-	Data     []byte
+	Type       int
+	Username   string
+	Data       []byte
+	Message_id int
+	Sent       time.Time
+	Edited     bool
+	Deleted    bool
+	Parent_id  int                 // id of the message this one replies to
+	Reactions  map[string][]string // users who reacted to the message, keyed by reaction
+	Mentions   []string            // users mentioned in the message
+
+	// sent with a channel list
+	Unread          []int // unread messages in each channel of the list
+	Unread_mentions []int // unread messages mentioning the user in each channel of the list
+}
+
+// struct to hold what is remembered between launches
+type Remembered_session struct {
+	Device string
+	Token  string
+}
+
+// struct to hold a direct message
+type Direct_message struct {
+	Id   int
+	From string
+	To   string
+	Text []byte
+	Sent time.Time
+}
+
+// struct to hold the name, topic, description and pinned messages of the channel
+type Channel_info struct {
+	Name        string
+	Topic       string
+	Description string
+	Pinned      []Data_packet
+}
+
+// struct to hold a message the user was mentioned in
+type Mention struct {
+	Message_id int
+	Channel    string
+	From       string
+	Text       []byte
+	Sent       time.Time
+}
+
+// struct to hold a line of a message as it is shown
+type Message_line struct {
+	Text string
+	Code bool // lines of code blocks are not wrapped and have their own background
+}
+
+// struct to ask the server to upload or download a file
+type Transfer_request struct {
+	Name     string
+	Size     int64
+	Checksum string
+	Address  string // where the server connects to for sending the chunks
+	Offset   int64  // bytes of a download that arrived before
+}
+
+// struct to hold which file a transfer is for and where it starts
+type Transfer_start struct {
+	Id       int
+	Name     string
+	Size     int64
+	Checksum string
+	Offset   int64
+}
+
+// struct to hold one chunk of a file on a transfer connection
+type Transfer_chunk struct {
+	Offset int64
+	Data   []byte
+	Done   bool   // set on the packet that ends a transfer
+	Error  string // why a transfer failed
+}
+
+// struct to hold a message found by a search
+type Search_result struct {
+	Message_id int
+	Channel    string
+	Username   string
+	Text       []byte
+	Sent       time.Time
+	Edited     bool
+}
+
+// struct to hold a page of search results
+type Search_page struct {
+	Results   []Search_result
+	Total     int // results on every page
+	Page      int
+	Page_size int
+}
+
+// struct to hold what the chat screen shows besides the chat strand
+type Chat_view struct {
+	Input        []byte // what is being typed
+	Err_msg      []byte // why the last command failed
+	Thread_id    int    // message whose thread is shown instead of the whole channel, 0 when there is none
+	Showing_pins bool   // whether only the pinned messages are shown
+	Paste_mode   bool   // true while enter adds a line to the message instead of sending it
 }
 
 type Command_packet struct {
@@ -162,15 +450,176 @@ var (
 	terminal_height int
 	username        string
 	horizontal_line []byte
-	vertical_space  []byte
 	current_channel []byte
 	command_socket  net.Conn
 	data_socket     net.Conn
+	command_reader  *bufio.Reader
+	data_reader     *bufio.Reader
+
+	// responses read from the command socket that are waiting for read_command_packet
+	command_responses = make(chan Command_packet, 16)
+
+	// token given by the server for resuming the session, kept between launches
+	session_token string
+
+	// name of this device, sent to the server so sessions can be told apart
+	device string
+
+	// set while the main menu is showing the channel list
+	menu_ready bool
+
+	// set when the channel list was already fetched for the next main menu
+	menu_prefetched bool
+
+	// channel the next main menu goes straight into, for opening a search result from another channel
+	opening_channel string
+
+	// connection bookkeeping for reconnecting
+	reconnect_mutex       sync.Mutex
+	connection_mutex      sync.Mutex
+	connection_generation int
+	connection_closed     = make(chan struct{}) // closed once the current connection is lost
+	last_command_type     int
 
 	channels []string
 
 	chat_strand []Data_packet
 	mutex_chat  sync.Mutex
+
+	// what the chat screen shows besides the chat strand. Only changed by the go routine reading the keyboard
+	// while screen_mutex is held, so that go routine reads it without the lock and every other one with it
+	chat_view Chat_view
+
+	// topic, description and pinned messages of the channel
+	channel_info       Channel_info
+	mutex_channel_info sync.Mutex
+
+	// direct messages keyed by the other user in the conversation
+	conversations          = make(map[string][]Direct_message)
+	unread_direct_messages = make(map[string]int)
+	mutex_dm               sync.Mutex
+
+	// conversation that is open so it can be redrawn when a message arrives
+	open_conversation string
+
+	// mentions that arrived since the mentions inbox was last opened
+	unread_mentions int
+	mutex_mentions  sync.Mutex
+
+	// unread messages and unread mentions in each channel, keyed by channel name
+	channel_unread   = make(map[string]int)
+	channel_mentions = make(map[string]int)
+	mutex_unread     sync.Mutex
+
+	// first message the user had not read when joining the channel, and whether the chat strand is kept
+	// scrolled to it rather than to the newest message
+	first_unread_id  int
+	pinned_to_unread bool
+
+	// message the chat strand is scrolled back to and how far below its first row the top of the screen is,
+	// scroll_id is 0 while the newest messages are shown. Only changed while screen_mutex is held
+	scroll_id       int
+	scroll_row      int
+	unseen_messages int // messages that arrived below the screen while scrolled back
+
+	// the chat strand as it was last drawn, for working out where scrolling goes
+	strand_top    int         // row at the top of the screen
+	strand_bottom int         // row at the top of the screen when the newest message is shown
+	strand_page   int         // rows of the chat strand on the screen, which is how far a page scrolls
+	message_rows  map[int]int // first row of every message, keyed by message id
+
+	// message older messages were asked for before, 0 while none are being fetched,
+	// and whether the server has said there are none older. Only used while screen_mutex is held
+	older_history_id  int
+	reached_beginning bool
+
+	// true while the server is sending the history of a channel that was just joined,
+	// and the timer that stops waiting for it if it never arrives
+	loading_history bool
+	history_timer   *time.Timer
+
+	// message a search result opened the channel at, 0 once the chat strand has been scrolled to it.
+	// Only used while screen_mutex is held
+	jump_id int
+
+	// users typing in the channel and when they were last heard to be typing
+	typing_users = make(map[string]time.Time)
+	mutex_typing sync.Mutex
+
+	// the screen, only drawn on while screen_mutex is held. screen_view draws what is on the screen
+	// so it can be drawn again when something it shows changes
+	screen_mutex  sync.Mutex
+	screen_view   func(screen *strings.Builder)
+	screen_open   bool
+	status_notice string // shown across the top of every screen, such as while reconnecting
+
+	// @username as the server finds it in messages
+	mention_regex = regexp.MustCompile(`@\pL[\pL\pN_-]*[\pL\pN]`)
+
+	// ranges of characters that take up two columns on the terminal, in order
+	wide_runes = [][2]rune{
+		{0x1100, 0x115f},   // hangul jamo
+		{0x231a, 0x231b},   // watch and hourglass
+		{0x2329, 0x232a},   // angle brackets
+		{0x23e9, 0x23ec},   // media buttons
+		{0x23f0, 0x23f0},   // alarm clock
+		{0x23f3, 0x23f3},   // hourglass
+		{0x25fd, 0x25fe},   // small squares
+		{0x2614, 0x2615},   // umbrella and hot drink
+		{0x2648, 0x2653},   // zodiac
+		{0x267f, 0x267f},   // wheelchair
+		{0x2693, 0x2693},   // anchor
+		{0x26a1, 0x26a1},   // high voltage
+		{0x26aa, 0x26ab},   // circles
+		{0x26bd, 0x26be},   // balls
+		{0x26c4, 0x26c5},   // snowman and sun
+		{0x26ce, 0x26ce},   // ophiuchus
+		{0x26d4, 0x26d4},   // no entry
+		{0x26ea, 0x26ea},   // church
+		{0x26f2, 0x26f3},   // fountain and golf
+		{0x26f5, 0x26f5},   // sailboat
+		{0x26fa, 0x26fa},   // tent
+		{0x26fd, 0x26fd},   // fuel pump
+		{0x2705, 0x2705},   // check mark
+		{0x270a, 0x270b},   // hands
+		{0x2728, 0x2728},   // sparkles
+		{0x274c, 0x274c},   // cross mark
+		{0x274e, 0x274e},   // cross mark button
+		{0x2753, 0x2755},   // question and exclamation marks
+		{0x2757, 0x2757},   // exclamation mark
+		{0x2795, 0x2797},   // plus, minus and divide
+		{0x27b0, 0x27b0},   // curly loop
+		{0x27bf, 0x27bf},   // double curly loop
+		{0x2b1b, 0x2b1c},   // large squares
+		{0x2b50, 0x2b50},   // star
+		{0x2b55, 0x2b55},   // circle
+		{0x2e80, 0x303e},   // cjk radicals and punctuation
+		{0x3041, 0x33ff},   // kana and cjk compatibility
+		{0x3400, 0x4dbf},   // cjk extension a
+		{0x4e00, 0x9fff},   // cjk unified ideographs
+		{0xa000, 0xa4cf},   // yi
+		{0xa960, 0xa97f},   // hangul jamo extended
+		{0xac00, 0xd7a3},   // hangul syllables
+		{0xf900, 0xfaff},   // cjk compatibility ideographs
+		{0xfe10, 0xfe19},   // vertical forms
+		{0xfe30, 0xfe6f},   // cjk compatibility forms
+		{0xff00, 0xff60},   // fullwidth forms
+		{0xffe0, 0xffe6},   // fullwidth signs
+		{0x16fe0, 0x18cff}, // tangut and khitan
+		{0x1b000, 0x1b2ff}, // kana supplement
+		{0x1f004, 0x1f004}, // mahjong tile
+		{0x1f0cf, 0x1f0cf}, // joker
+		{0x1f18e, 0x1f18e}, // ab button
+		{0x1f191, 0x1f19a}, // squared words
+		{0x1f200, 0x1f251}, // enclosed ideographs
+		{0x1f300, 0x1f64f}, // pictographs and emoticons
+		{0x1f680, 0x1f6ff}, // transport and map symbols
+		{0x1f7e0, 0x1f7eb}, // coloured shapes
+		{0x1f90c, 0x1f9ff}, // supplemental pictographs
+		{0x1fa70, 0x1faff}, // pictographs extended
+		{0x20000, 0x2fffd}, // cjk extension b and later
+		{0x30000, 0x3fffd}, // cjk extension g and later
+	}
 )
 
 // --------------------------------------------------------------------------------------------------------
@@ -203,1031 +652,1245 @@ func main() {
  * This function initializes the client
  */
 func initialize_client() {
-	clear_terminal()
 	client_status = CHOOSING_SIGN_IN_OPT
 	get_terminal_dimensions()
 	create_horizantal_line()
-	create_vertical_space()
+	load_remembered_session()
+	open_screen()
 	connect_to_server()
 	establish_data_connection()
+	go handle_inbound_commands(connection_generation)
 	setup_signal_handler()
+	setup_resize_handler()
 	print_client_status()
 	print_splash_screen()
+	resume_remembered_session()
 }
 
 /*
- * This function clears the terminal
+ * This function loads the device name and session token saved by the last launch.
+ * A device name is made up the first time the client runs
  */
-func clear_terminal() {
-	cmd := exec.Command("clear")
-	cmd.Stdout = os.Stdout
-	cmd.Run()
+func load_remembered_session() {
+	var remembered Remembered_session
+
+	// reading saved session
+	json_data, err := os.ReadFile(REMEMBER_ME_PATH)
+	if err == nil {
+		if err := json.Unmarshal(json_data, &remembered); err != nil {
+			fmt.Println("system: Ignoring unreadable " + REMEMBER_ME_PATH)
+		}
+	}
+
+	// naming this device after the host with a random suffix so two clients on one host differ
+	if remembered.Device == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "device"
+		}
+		suffix := make([]byte, 3)
+		rand.Read(suffix)
+		remembered.Device = host + "-" + hex.EncodeToString(suffix)
+	}
+
+	device = remembered.Device
+	session_token = remembered.Token
+	save_remembered_session()
 }
 
 /*
- * This function gets the terminal dimensions
+ * This function saves the device name and session token for the next launch
  */
-func get_terminal_dimensions() {
-	// Get the file descriptor for standard output
-	fd := int(syscall.Stdout)
-
-	// Get the terminal size
-	width, height, err := terminal.GetSize(fd)
+func save_remembered_session() {
+	json_data, err := json.Marshal(Remembered_session{Device: device, Token: session_token})
 	if err != nil {
-		fmt.Println("Error getting terminal size:", err)
+		fmt.Println("system: Failed to save session -", err)
 		return
 	}
 
-	// setting global vars
-	terminal_width = width
-	terminal_height = height
+	// only this user should be able to read the token
+	if err := os.WriteFile(REMEMBER_ME_PATH, json_data, 0600); err != nil {
+		fmt.Println("system: Failed to save session -", err)
+	}
 }
 
 /*
- * This function creates a horizantal line based on terminal size
+ * This function signs in with the remembered session token so the user skips the sign in screens
  */
-func create_horizantal_line() {
-	for i := 0; i < terminal_width; i++ {
-		horizontal_line = append(horizontal_line, '-')
+func resume_remembered_session() {
+	if session_token == "" {
+		return
 	}
-}
 
-/*
- * This function creates a vertical space the same hight as the terminal
- */
-func create_vertical_space() {
-	for i := 0; i < terminal_height; i++ {
-		vertical_space = append(vertical_space, '\n')
+	// presenting session token
+	send_data_packet(Data_packet{Type: RESUME, Data: []byte(session_token)})
+	packet := read_data_packet()
+
+	// forgetting the token if the server no longer accepts it
+	if packet.Type != ACCEPT {
+		session_token = ""
+		save_remembered_session()
+		return
 	}
-}
+	username = packet.Username
 
-/*
- * This function attempts to connect to the
- * server and closes the client if it fails
- */
-func connect_to_server() {
-	var err error
-	command_socket, err = net.Dial(CONNECTION_TYPE, SERVER_HOST+":"+COMMAND_PORT)
-	if err != nil {
-		error_exit(err)
+	// keeping the fresh token the server sends back
+	packet = read_data_packet()
+	if packet.Type != SESSION_TOKEN {
+		custom_error_exit(OUT_OF_SYNC)
 	}
+	session_token = string(packet.Data)
+	save_remembered_session()
+
+	client_status = IN_MAIN_MENU
 }
 
 /*
- * This function creates a second connection with the server for data
+ * This function forgets the session and sends the client back to the sign in menu after logging out
  */
-func establish_data_connection() {
-	// creating a tempary passive socket to listen for the server connecting
-	tmp_passive_socket := create_socket()
+func forget_session() {
+	// closing function on server side that is using data_socket
+	dpack := Data_packet{Type: CLOSE, Username: username, Data: []byte("Logged out")}
+	send_data_packet(dpack)
 
-	// creating connection packet to be sent
-	packet := Command_packet{Type: CONNECT, Username: username, Arguments: []byte(CLIENT_HOST + ":" + DATA_PORT)}
+	session_token = ""
+	save_remembered_session()
 
-	// sending connection packet
-	send_command_packet(packet)
+	username = ""
+	current_channel = nil
+	mutex_chat.Lock()
+	chat_strand = nil
+	mutex_chat.Unlock()
+	client_status = CHOOSING_SIGN_IN_OPT
+}
+
+// -----------------------------------------------------------------------------------------------------------------------
+// SCREEN
+// -----------------------------------------------------------------------------------------------------------------------
 
-	// waiting for the server to accept
-	data_socket = accept_connection(tmp_passive_socket)
+/*
+ * This function switches to a screen of the client's own, the shell is shown again when the client closes
+ */
+func open_screen() {
+	screen_mutex.Lock()
+	defer screen_mutex.Unlock()
 
-	// closing the passive socket
-	tmp_passive_socket.Close()
+	os.Stdout.WriteString(ALTERNATE_SCREEN)
+	screen_open = true
 }
 
 /*
- * This function creates a signal catcher for
- * when the user enter ctrl-c
+ * This function switches back to the screen of the shell so messages printed while closing stay on it
  */
-func setup_signal_handler() {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGINT)
-	go handle_ctrl_c(sigChan)
+func close_screen() {
+	screen_mutex.Lock()
+	defer screen_mutex.Unlock()
+
+	if screen_open {
+		os.Stdout.WriteString(RESET + SHOW_CURSOR + MAIN_SCREEN)
+		screen_open = false
+	}
 }
 
 /*
- * This function prints the client status after successfully launching
+ * This function puts a view on the screen. The view writes the whole screen, top to bottom, and is kept so
+ * the screen can be drawn again when something it shows changes
  */
-func print_client_status() {
-	fmt.Println(string(horizontal_line))
-	fmt.Println("system: Command socket connected on:")
-	fmt.Println("\t- address:\t ", SERVER_HOST)
-	fmt.Println("\t- port:\t\t ", COMMAND_PORT)
-	fmt.Println("system: Data socket connected on:")
-	fmt.Println("\t- address:\t ", SERVER_HOST)
-	fmt.Println("\t- port:\t\t ", COMMAND_PORT)
-	fmt.Println(string(horizontal_line))
-	time.Sleep(2 * time.Second)
+func draw(view func(screen *strings.Builder)) {
+	screen_mutex.Lock()
+	defer screen_mutex.Unlock()
+
+	screen_view = view
+	paint_screen()
 }
 
 /*
- * This function prints the splash screen for the program
+ * This function draws a view only while the client is in the given state, so it does not cover what another
+ * go routine put up after moving the client out of that state
  */
-func print_splash_screen() {
-	clear_terminal()
-	loading_bar := make([]byte, terminal_width)
-	for i := 0; i < terminal_width; i++ {
-		fmt.Print(string(vertical_space[0 : (terminal_height-15)/2]))
-		banner_line_1 := "__        __   _                         "
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_1))/2)+len(banner_line_1), banner_line_1)
-		banner_line_2 := "\\ \\      / /__| | ___ ___  _ __ ___   ___"
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_2))/2)+len(banner_line_2), banner_line_2)
-		banner_line_3 := "  \\ \\ /\\ / / _ \\ |/ __/ _ \\| '_ ` _ \\ / _ \\"
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_3))/2)+len(banner_line_3), banner_line_3)
-		banner_line_4 := "   \\ V  V /  __/ | (_| (_) | | | | | |  __/"
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_4))/2)+len(banner_line_4), banner_line_4)
-		banner_line_5 := "    \\_/\\_/ \\___|_|\\___\\___/|_| |_| |_|\\___|"
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_5))/2)+len(banner_line_5), banner_line_5)
-		banner_line_6 := "_____  "
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_6))/2)+len(banner_line_6), banner_line_6)
-		banner_line_7 := "|_   _|__"
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_7))/2)+len(banner_line_7), banner_line_7)
-		banner_line_8 := "   | |/ _ \\"
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_8))/2)+len(banner_line_8), banner_line_8)
-		banner_line_9 := "    | | (_) |"
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_9))/2)+len(banner_line_9), banner_line_9)
-		banner_line_10 := "   |_|\\___/"
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_10))/2)+len(banner_line_10), banner_line_10)
-		banner_line_11 := "  ____ _   _    _  _____ _  _  ____   ___"
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_11))/2)+len(banner_line_11), banner_line_11)
-		banner_line_12 := "  / ___| | | |  / \\|_   _| || ||___ \\ / _ \\"
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_12))/2)+len(banner_line_12), banner_line_12)
-		banner_line_13 := "  | |   | |_| | / _ \\ | | | || |_ __) | (_) |"
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_13))/2)+len(banner_line_13), banner_line_13)
-		banner_line_14 := "  | |___|  _  |/ ___ \\| | |__   _/ __/ \\__, |"
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_14))/2)+len(banner_line_14), banner_line_14)
-		banner_line_15 := "  \\____|_| |_/_/   \\_\\_|    |_||_____|  /_/"
-		fmt.Printf("%*s\n", ((terminal_width-len(banner_line_15))/2)+len(banner_line_15), banner_line_15)
-		fmt.Print(string(vertical_space[0 : (terminal_height-(terminal_height-15)/2)-15-3]))
-		fmt.Println(string(horizontal_line))
-		fmt.Println(string(loading_bar))
-		fmt.Println(string(horizontal_line))
+func draw_in_state(state int, view func(screen *strings.Builder)) {
+	screen_mutex.Lock()
+	defer screen_mutex.Unlock()
 
-		// increasing loading bar by one each iteration
-		loading_bar = append(loading_bar, '=')
-		time.Sleep(20 * time.Millisecond)
+	if client_status != state {
+		return
 	}
+	screen_view = view
+	paint_screen()
 }
 
 /*
- * This function creates a listening socket and handles the possible errors
+ * This function changes what the chat screen shows besides the chat strand, it is only called by the go routine
+ * reading the keyboard
  */
-func create_socket() net.Listener {
-	listener, err := net.Listen(CONNECTION_TYPE, CLIENT_HOST+":"+DATA_PORT)
-	if err != nil {
-		error_exit(err)
-	}
-	return listener
+func update_chat_view(change func(view *Chat_view)) {
+	screen_mutex.Lock()
+	defer screen_mutex.Unlock()
+
+	change(&chat_view)
 }
 
 /*
- * This function accepts a client's connection
- * It exits if there is an error and returns the net.Conn upon success
+ * This function draws the view that is on the screen again, it is called by go routines that change what it shows
  */
-func accept_connection(passive_socket net.Listener) net.Conn {
-	connection, err := passive_socket.Accept()
-	if err != nil {
-		fmt.Println("Error accepting: ", err.Error())
-		os.Exit(1)
+func redraw() {
+	screen_mutex.Lock()
+	defer screen_mutex.Unlock()
+
+	if screen_view != nil {
+		paint_screen()
 	}
-	return connection
 }
 
 /*
- * This function sends a packet to the command socket
+ * This function sets the notice shown across the top of the screen, an empty notice takes it down
  */
-func send_command_packet(packet Command_packet) {
-	json_data := marshal_command_packet(packet)
-	write_to_connection(json_data, command_socket)
+func set_status_notice(notice string) {
+	screen_mutex.Lock()
+	status_notice = notice
+	screen_mutex.Unlock()
+	redraw()
 }
 
 /*
- * This function reads a packet from the command socket
+ * This function shows a notice across the top of the screen for a few seconds
  */
-func read_command_packet() Command_packet {
-	json_data, amount_read := read_from_connection(command_socket)
-	packet := unmarshal_command_packet(json_data[:amount_read])
-	return packet
+func flash_status_notice(notice string) {
+	set_status_notice(notice)
+	time.AfterFunc(NOTICE_DURATION, func() {
+		screen_mutex.Lock()
+		expired := status_notice == notice
+		screen_mutex.Unlock()
+		if expired {
+			set_status_notice("")
+		}
+	})
 }
 
 /*
- * This function sends a packet to the data socket
+ * This function writes the view onto the terminal in one go. Every row is written in place rather than
+ * clearing the terminal first so nothing flickers, and the cursor is left where the view stopped writing.
+ * The screen mutex must be held by the caller
  */
-func send_data_packet(packet Data_packet) {
-	json_data := marshal_data_packet(packet)
-	write_to_connection(json_data, data_socket)
+func paint_screen() {
+	if !screen_open {
+		return
+	}
+
+	// building the view
+	var view strings.Builder
+	screen_view(&view)
+	rows := get_screen_rows(view.String(), terminal_width, terminal_height)
+	if status_notice != "" {
+		rows[0] = INVERSE + cut_to_width(" "+status_notice+strings.Repeat(" ", terminal_width), terminal_width) + RESET
+	}
+
+	// writing every row of the terminal
+	var output strings.Builder
+	output.WriteString(HIDE_CURSOR)
+	for i := 0; i < terminal_height; i++ {
+		fmt.Fprintf(&output, MOVE_CURSOR, i+1, 1)
+		if i < len(rows) {
+			output.WriteString(rows[i])
+		}
+		output.WriteString(RESET + CLEAR_LINE)
+	}
+
+	// leaving the cursor at the end of what was written, which is where the user types
+	last := len(rows) - 1
+	fmt.Fprintf(&output, MOVE_CURSOR, last+1, min(text_width(rows[last])+1, terminal_width))
+	output.WriteString(SHOW_CURSOR)
+	os.Stdout.WriteString(output.String())
 }
 
 /*
- * This function reads a packet from the data socket
+ * This function splits what a view wrote into the rows of the terminal. Lines wider than the terminal
+ * carry on in the next row, and when there are more rows than fit only the last ones are kept, as if the
+ * terminal had scrolled
  */
-func read_data_packet() Data_packet {
-	json_data, amount_read := read_from_connection(data_socket)
-	packet := unmarshal_data_packet(json_data[:amount_read])
-	return packet
+func get_screen_rows(text string, width int, height int) []string {
+	var rows []string
+	for _, line := range strings.Split(text, "\n") {
+		rows = append(rows, wrap_row(line, width)...)
+	}
+	if len(rows) > height {
+		rows = rows[len(rows)-height:]
+	}
+	return rows
 }
 
 /*
- * This function writes to a specified socket
+ * This function cuts a line that may hold colour codes into rows of the given width. Tabs are turned into
+ * spaces up to the next tab stop and the colour a row ends in is carried on into the next one
  */
-func write_to_connection(data []byte, connection net.Conn) {
-	_, err := connection.Write(data)
-	if err != nil {
-		fmt.Println("system: Failed to write to socket")
+func wrap_row(line string, width int) []string {
+	var rows []string
+	var row strings.Builder
+	style := ""
+	used := 0
+	for i := 0; i < len(line); i++ {
+		// keeping colour codes, remembering them so the next row can start in the same colour
+		if line[i] == '\x1b' {
+			end := strings.IndexByte(line[i:], 'm')
+			if end == -1 {
+				break
+			}
+			code := line[i : i+end+1]
+			if code == RESET {
+				style = ""
+			} else {
+				style += code
+			}
+			row.WriteString(code)
+			i += end
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(line[i:])
+		i += size - 1
+		text := string(r)
+		cells := rune_width(r)
+		if r == '\t' {
+			cells = TAB_WIDTH - used%TAB_WIDTH
+			text = strings.Repeat(" ", cells)
+		}
+
+		// starting a new row when the character does not fit on this one
+		if used+cells > width && used > 0 {
+			rows = append(rows, row.String()+RESET)
+			row.Reset()
+			row.WriteString(style)
+			used = 0
+		}
+		row.WriteString(text)
+		used += cells
 	}
+	return append(rows, row.String())
 }
 
 /*
- * This function reads from a specified socket
+ * This function gets the terminal dimensions
  */
-func read_from_connection(connection net.Conn) ([]byte, int) {
-	data := make([]byte, MAX_PACKET_SIZE)
-	amount_read, err := connection.Read(data)
+func get_terminal_dimensions() {
+	// Get the file descriptor for standard output
+	fd := int(syscall.Stdout)
+
+	// Get the terminal size
+	width, height, err := terminal.GetSize(fd)
 	if err != nil {
-		fmt.Println("system: Failed to read from socket")
+		fmt.Println("Error getting terminal size:", err)
+		return
 	}
-	return data, amount_read
+
+	// setting global vars
+	terminal_width = width
+	terminal_height = height
 }
 
 /*
- * This function marshals a packet into a json file
+ * This function creates a horizantal line based on terminal size, it is made again whenever the size changes
  */
-func marshal_data_packet(packet Data_packet) []byte {
-	json_data, err := json.Marshal(packet)
-	if err != nil {
-		fmt.Println("server: Error marshaling data-", err.Error())
-	}
-	return json_data
+func create_horizantal_line() {
+	horizontal_line = []byte(strings.Repeat("-", terminal_width))
 }
 
 /*
- * This function unmarshals json data into a packetand handles the possible errors
+ * This function gets a number of empty lines for spacing a view out vertically, none when the terminal is
+ * too short to fit them
  */
-func unmarshal_data_packet(json_data []byte) Data_packet {
-	var packet Data_packet
-	err := json.Unmarshal(json_data, &packet)
-	if err != nil {
-		error_exit(err)
-	}
-	return packet
+func blank_lines(count int) string {
+	return strings.Repeat("\n", max(count, 0))
 }
 
 /*
- * This function marshals a packet into a json file
+ * This function attempts to connect to the
+ * server and closes the client if it fails
  */
-func marshal_command_packet(packet Command_packet) []byte {
-	json_data, err := json.Marshal(packet)
+func connect_to_server() {
+	var err error
+	command_socket, err = dial_server()
 	if err != nil {
-		fmt.Println("server: Error marshaling data-", err.Error())
+		error_exit(err)
 	}
-	return json_data
+	command_reader = bufio.NewReaderSize(command_socket, MAX_PACKET_SIZE)
 }
 
 /*
- * This function unmarshals json data into a packetand handles the possible errors
+ * This function creates a second connection with the server for data
  */
-func unmarshal_command_packet(json_data []byte) Command_packet {
-	var packet Command_packet
-	err := json.Unmarshal(json_data, &packet)
+func establish_data_connection() {
+	var err error
+	data_socket, err = open_data_connection(command_socket)
 	if err != nil {
 		error_exit(err)
 	}
-	return packet
+	data_reader = bufio.NewReaderSize(data_socket, MAX_PACKET_SIZE)
 }
 
 /*
- * this function closes the sockets and exits the program
+ * This function opens a command socket to the server
  */
-func shutdown() {
-	fmt.Println("system: Shutting down...")
-	command_socket.Close()
-	data_socket.Close()
-	os.Exit(0)
+func dial_server() (net.Conn, error) {
+	return net.Dial(CONNECTION_TYPE, SERVER_HOST+":"+COMMAND_PORT)
 }
 
 /*
- * This function handles the client choosing to either sign in or register
+ * This function asks the server to open a data socket back to the client over the given command socket
+ * and waits for it to connect
  */
-func choose_sign_in_opt() {
-	var packet Data_packet
-	current_choice := 0
+func open_data_connection(command net.Conn) (net.Conn, error) {
+	// creating a tempary passive socket to listen for the server connecting
+	listener, err := net.Listen(CONNECTION_TYPE, CLIENT_HOST+":"+DATA_PORT)
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
 
-	// creating channel to send client choice
-	choice_channel := make(chan int)
+	// creating connection packet to be sent
+	packet := Command_packet{Type: CONNECT, Username: username, Arguments: []byte(CLIENT_HOST + ":" + DATA_PORT), Message: []byte(device)}
 
-	// creating routine to handle printing the sign in menu
-	go display_sign_in_menu(choice_channel)
+	// sending connection packet
+	write_to_connection(marshal_command_packet(packet), command)
 
-	// creating a keyboard reader
-	if err := keyboard.Open(); err != nil {
-		panic(err)
-	}
-	defer keyboard.Close()
+	// waiting for the server to accept, giving up if it never connects
+	listener.(*net.TCPListener).SetDeadline(time.Now().Add(CONNECT_TIMEOUT))
+	return listener.Accept()
+}
 
-	// looping until enter is pressed
-	for {
-		// getting key press
-		_, key, err := keyboard.GetSingleKey()
-		if err != nil {
-			panic(err)
-		}
-
-		// Check if the pressed key is the up or down arrow
-		if key == keyboard.KeyArrowUp {
-			current_choice += 2
-			current_choice = current_choice % 3
-			choice_channel <- current_choice
-		} else if key == keyboard.KeyArrowDown {
-			current_choice++
-			current_choice = current_choice % 3
-			choice_channel <- current_choice
-		}
+/*
+ * This function creates a signal catcher for
+ * when the user enter ctrl-c
+ */
+func setup_signal_handler() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGINT)
+	go handle_ctrl_c(sigChan)
+}
 
-		// Break the loop if Enter key is pressed
-		if key == keyboard.KeyEnter {
-			// send signal to display_sign_in_menu
-			choice_channel <- 3
+/*
+ * This function sets up the listener for the terminal changing size
+ */
+func setup_resize_handler() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGWINCH)
+	go handle_resize(sigChan)
+}
 
-			// setting state based on choice and preparing
-			// packet to be sent to the server with the choice
-			if current_choice == 0 {
-				packet.Type = MENU_OPTION
-				packet.Data = []byte("LOGIN")
-				client_status = LOGGING_IN
-				send_data_packet(packet)
-			} else if current_choice == 1 {
-				packet.Type = MENU_OPTION
-				packet.Data = []byte("REGISTER")
-				client_status = REGISTERING
-				send_data_packet(packet)
-			} else if current_choice == 2 {
-				var cpack Command_packet
-				cpack.Type = EXIT
-				cpack.Username = ""
-				cpack.Arguments = []byte("client disconnecting")
-				client_status = QUITTING
-				exit_command(cpack)
-			}
-			break
-		}
+/*
+ * This function lays the screen out again every time the terminal changes size
+ */
+func handle_resize(sigChan chan os.Signal) {
+	for range sigChan {
+		resize_screen()
 	}
 }
 
 /*
- * This function is called as a go routine to display the sign in options
+ * This function reads the new size of the terminal and draws the view on the screen again to fit it
  */
-func display_sign_in_menu(choice chan int) {
-	currently_selected := 0
-	opt_1 := 0
-	opt_2 := 0
-	opt_3 := 0
+func resize_screen() {
+	screen_mutex.Lock()
+	defer screen_mutex.Unlock()
 
-	for {
-		select {
-		case c := <-choice:
-			switch c {
-			case 3:
-				return
-			case 0:
-				currently_selected = 0
-			case 1:
-				currently_selected = 1
-			case 2:
-				currently_selected = 2
-			}
-		default:
-			switch currently_selected {
-			case 0:
-				if opt_1 == 16 {
-					opt_1 = 0
-				} else if opt_1 < 8 {
-					display_opt_0_selected()
-					opt_1++
-				} else if opt_1 < 16 {
-					display_opt()
-					opt_1++
-				}
-				time.Sleep(30 * time.Millisecond)
+	get_terminal_dimensions()
+	create_horizantal_line()
 
-			case 1:
-				if opt_2 == 16 {
-					opt_2 = 0
-				} else if opt_2 < 8 {
-					display_opt_1_selected()
-					opt_2++
-				} else if opt_2 < 16 {
-					display_opt()
-					opt_2++
-				}
-				time.Sleep(30 * time.Millisecond)
-			case 2:
-				if opt_3 == 16 {
-					opt_3 = 0
-				} else if opt_3 < 8 {
-					display_opt_2_selected()
-					opt_3++
-				} else if opt_3 < 16 {
-					display_opt()
-					opt_3++
-				}
-				time.Sleep(30 * time.Millisecond)
-			}
-		}
+	// clearing what the terminal left behind when it moved the old rows around
+	if screen_open && screen_view != nil {
+		os.Stdout.WriteString(CLEAR_SCREEN)
+		paint_screen()
 	}
 }
 
 /*
- * This function prints a screen with the login option selected
+ * This function prints the client status after successfully launching
  */
-func display_opt_0_selected() {
-	clear_terminal()
-	fmt.Println(string(horizontal_line))
-	line_1 := "Select an option below"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-	line_2 := "Use the up and down arrows to change selection"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-	fmt.Println(string(horizontal_line))
-	fmt.Print(string(vertical_space[4 : (terminal_height)/2-4]))
-	line_3 := "--> LOGIN <--"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-	fmt.Print("\n")
-	line_4 := "CREATE ACCOUNT"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_4))/2)+len(line_4), line_4)
-	fmt.Print("\n")
-	line_5 := "QUIT"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_5))/2)+len(line_5), line_5)
-	fmt.Print(string(vertical_space[(terminal_height)/2+3:]))
+func print_client_status() {
+	draw(func(screen *strings.Builder) {
+		fmt.Fprintln(screen, string(horizontal_line))
+		fmt.Fprintln(screen, "system: Command socket connected on:")
+		fmt.Fprintln(screen, "\t- address:\t ", SERVER_HOST)
+		fmt.Fprintln(screen, "\t- port:\t\t ", COMMAND_PORT)
+		fmt.Fprintln(screen, "system: Data socket connected on:")
+		fmt.Fprintln(screen, "\t- address:\t ", SERVER_HOST)
+		fmt.Fprintln(screen, "\t- port:\t\t ", COMMAND_PORT)
+		fmt.Fprintln(screen, string(horizontal_line))
+	})
+	time.Sleep(2 * time.Second)
 }
 
 /*
- * This function prints a screen with the register option selected
+ * This function prints the splash screen for the program
  */
-func display_opt_1_selected() {
-	clear_terminal()
-	fmt.Println(string(horizontal_line))
-	line_1 := "Select an option below"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-	line_2 := "Use the up and down arrows to change selection"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-	fmt.Println(string(horizontal_line))
-	fmt.Print(string(vertical_space[4 : (terminal_height)/2-4]))
-	line_3 := "LOGIN"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-	fmt.Print("\n")
-	line_4 := "--> CREATE ACCOUNT <--"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_4))/2)+len(line_4), line_4)
-	fmt.Print("\n")
-	line_5 := "QUIT"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_5))/2)+len(line_5), line_5)
-	fmt.Print(string(vertical_space[(terminal_height)/2+3:]))
+func print_splash_screen() {
+	loading_bar := make([]byte, terminal_width)
+	for i := 0; i < terminal_width; i++ {
+		draw(func(screen *strings.Builder) {
+			fmt.Fprint(screen, blank_lines((terminal_height-15)/2))
+			banner_line_1 := "__        __   _                         "
+			fmt.Fprintln(screen, center_text(banner_line_1))
+			banner_line_2 := "\\ \\      / /__| | ___ ___  _ __ ___   ___"
+			fmt.Fprintln(screen, center_text(banner_line_2))
+			banner_line_3 := "  \\ \\ /\\ / / _ \\ |/ __/ _ \\| '_ ` _ \\ / _ \\"
+			fmt.Fprintln(screen, center_text(banner_line_3))
+			banner_line_4 := "   \\ V  V /  __/ | (_| (_) | | | | | |  __/"
+			fmt.Fprintln(screen, center_text(banner_line_4))
+			banner_line_5 := "    \\_/\\_/ \\___|_|\\___\\___/|_| |_| |_|\\___|"
+			fmt.Fprintln(screen, center_text(banner_line_5))
+			banner_line_6 := "_____  "
+			fmt.Fprintln(screen, center_text(banner_line_6))
+			banner_line_7 := "|_   _|__"
+			fmt.Fprintln(screen, center_text(banner_line_7))
+			banner_line_8 := "   | |/ _ \\"
+			fmt.Fprintln(screen, center_text(banner_line_8))
+			banner_line_9 := "    | | (_) |"
+			fmt.Fprintln(screen, center_text(banner_line_9))
+			banner_line_10 := "   |_|\\___/"
+			fmt.Fprintln(screen, center_text(banner_line_10))
+			banner_line_11 := "  ____ _   _    _  _____ _  _  ____   ___"
+			fmt.Fprintln(screen, center_text(banner_line_11))
+			banner_line_12 := "  / ___| | | |  / \\|_   _| || ||___ \\ / _ \\"
+			fmt.Fprintln(screen, center_text(banner_line_12))
+			banner_line_13 := "  | |   | |_| | / _ \\ | | | || |_ __) | (_) |"
+			fmt.Fprintln(screen, center_text(banner_line_13))
+			banner_line_14 := "  | |___|  _  |/ ___ \\| | |__   _/ __/ \\__, |"
+			fmt.Fprintln(screen, center_text(banner_line_14))
+			banner_line_15 := "  \\____|_| |_/_/   \\_\\_|    |_||_____|  /_/"
+			fmt.Fprintln(screen, center_text(banner_line_15))
+			fmt.Fprint(screen, blank_lines((terminal_height-(terminal_height-15)/2)-15-3))
+			fmt.Fprintln(screen, string(horizontal_line))
+			fmt.Fprintln(screen, string(loading_bar))
+			fmt.Fprintln(screen, string(horizontal_line))
+		})
+
+		// increasing loading bar by one each iteration
+		loading_bar = append(loading_bar, '=')
+		time.Sleep(20 * time.Millisecond)
+	}
 }
 
 /*
- * This function prints a screen with the quit option selected
+ * This function sends a packet to the command socket
  */
-func display_opt_2_selected() {
-	clear_terminal()
-	fmt.Println(string(horizontal_line))
-	line_1 := "Select an option below"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-	line_2 := "Use the up and down arrows to change selection"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-	fmt.Println(string(horizontal_line))
-	fmt.Print(string(vertical_space[4 : (terminal_height)/2-4]))
-	line_3 := "LOGIN"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-	fmt.Print("\n")
-	line_4 := "CREATE ACCOUNT"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_4))/2)+len(line_4), line_4)
-	fmt.Print("\n")
-	line_5 := "--> QUIT <--"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_5))/2)+len(line_5), line_5)
-	fmt.Print(string(vertical_space[(terminal_height)/2+3:]))
+func send_command_packet(packet Command_packet) {
+	// remembering the command so it can still be answered if the connection is lost
+	if packet.Type != PONG {
+		last_command_type = packet.Type
+	}
+
+	json_data := marshal_command_packet(packet)
+	command, _ := get_sockets()
+	write_to_connection(json_data, command)
 }
 
 /*
- * This function displays all options of the sign in menu with none selected
+ * This function gets the command and data sockets of the current connection, which reconnecting replaces
  */
-func display_opt() {
-	clear_terminal()
-	fmt.Println(string(horizontal_line))
-	line_1 := "Select an option below"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-	line_2 := "Use the up and down arrows to change selection"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-	fmt.Println(string(horizontal_line))
-	fmt.Print(string(vertical_space[4 : (terminal_height)/2-4]))
-	line_3 := "LOGIN"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-	fmt.Print("\n")
-	line_4 := "CREATE ACCOUNT"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_4))/2)+len(line_4), line_4)
-	fmt.Print("\n")
-	line_5 := "QUIT"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_5))/2)+len(line_5), line_5)
-	fmt.Print(string(vertical_space[(terminal_height)/2+3:]))
+func get_sockets() (net.Conn, net.Conn) {
+	connection_mutex.Lock()
+	defer connection_mutex.Unlock()
+
+	return command_socket, data_socket
 }
 
 /*
- * This function is responcible for registering the client with the server.
- * This includes getting a username and password to create an account
+ * This function reads a packet from the command socket.
+ * If the connection is lost while waiting it returns a response
+ * to the last command saying so, so that the caller can carry on
  */
-func register_user() {
-	if !get_username_for_registration() {
-		return
-	}
-
-	if !get_password_for_registration() {
-		return
+func read_command_packet() Command_packet {
+	connection_mutex.Lock()
+	closed := connection_closed
+	connection_mutex.Unlock()
+
+	select {
+	case packet := <-command_responses:
+		return packet
+	case <-closed:
+		msg := []byte("Lost connection to the server")
+		return Command_packet{Type: last_command_type, Username: username, Arguments: msg, Message: msg}
 	}
 }
 
 /*
- * This function loops until it gets a valid username from the user
+ * This function reads the command socket, answering heartbeats from the
+ * server and passing every other packet on to read_command_packet
  */
-func get_username_for_registration() bool {
-	var packet Data_packet
-	var input []byte
-
-	// clearing terminal
-	clear_terminal()
-
-	print_registration_username(string(input), nil)
-
+func handle_inbound_commands(generation int) {
+	connection_mutex.Lock()
+	reader := command_reader
+	connection_mutex.Unlock()
 	for {
-		if err := keyboard.Open(); err != nil {
-			panic(err)
+		json_data, amount_read := read_from_connection(reader)
+		if amount_read < 0 {
+			connection_lost(generation)
+			return
 		}
 
-		// getting user input
-		for {
-			// getting key press
-			char, key, err := keyboard.GetSingleKey()
-			if err != nil {
-				panic(err)
-			}
+		// answering heartbeat
+		packet := unmarshal_command_packet(json_data[:amount_read])
+		if packet.Type == PING {
+			send_command_packet(Command_packet{Type: PONG, Username: username})
+			continue
+		}
 
-			// checking if enter key was pressed
-			if key == keyboard.KeyEnter {
-				break
-			} else if key == keyboard.KeyBackspace || key == keyboard.KeyBackspace2 {
-				if len(input) > 0 {
-					input = input[:len(input)-1]
-				}
-			} else if key == keyboard.KeyCtrlC {
-				var cpack Command_packet
-				cpack.Type = EXIT
-				cpack.Username = ""
-				cpack.Arguments = []byte("client disconnecting")
-				client_status = QUITTING
-				exit_command(cpack)
-			} else if key == keyboard.KeySpace {
-				input = append(input, ' ')
-			} else if key == keyboard.KeyTab || key == keyboard.KeyArrowLeft || key == keyboard.KeyArrowRight || key == keyboard.KeyArrowDown || key == keyboard.KeyArrowUp {
-				continue
-			} else if key == keyboard.KeyEsc {
-				client_status = CHOOSING_SIGN_IN_OPT
-				username = ""
-				var dpack Data_packet
-				dpack.Type = ESC
-				dpack.Data = []byte("User hit ESC")
-				send_data_packet(dpack)
-				return false
-			} else {
-				input = append(input, byte(char))
-			}
-			// clearing the terminal
-			clear_terminal()
+		// receiving direct message
+		if packet.Type == DIRECT_MSG {
+			receive_direct_message(packet)
+			continue
+		}
 
-			// printing login screen
-			print_registration_username(string(input), nil)
+		// noting a mention
+		if packet.Type == MENTION {
+			receive_mention(packet)
+			continue
 		}
 
-		// checking if the user entered a command
-		if is_comand(string(input)) {
-			msg := handle_command(string(input))
-			input = nil
-			clear_terminal()
-			print_registration_username(string(input), msg)
+		// leaving a channel the user was removed from
+		if packet.Type == REMOVED {
+			removed_from_channel(packet)
 			continue
 		}
 
-		// declaring and initializing packet
-		packet = Data_packet{Type: REGISTRATION, Data: []byte(input)}
+		command_responses <- packet
+	}
+}
 
-		// sending packet containging username
-		send_data_packet(packet)
+/*
+ * This function handles losing the connection to the server by reconnecting.
+ * Only the first routine to notice a lost connection reconnects, the rest wait for it to finish
+ */
+func connection_lost(generation int) {
+	// ignoring errors caused by the client closing its own sockets
+	if client_status == QUITTING {
+		return
+	}
 
-		// waiting for response
-		packet = read_data_packet()
+	reconnect_mutex.Lock()
+	defer reconnect_mutex.Unlock()
 
-		// checking response from server
-		// checking if username was accepted
-		if packet.Type == ACCEPT {
-			break
-		} else {
-			go play_sound("error.mp3")
-			print_registration_username(string(input), packet.Data)
-		}
+	// checking if another routine already reconnected
+	connection_mutex.Lock()
+	if generation != connection_generation {
+		connection_mutex.Unlock()
+		return
 	}
-	return true
+
+	// waking up anything waiting on a command response
+	close(connection_closed)
+	connection_mutex.Unlock()
+
+	// making sure every routine using the old connection notices it is gone
+	command, data := get_sockets()
+	command.Close()
+	data.Close()
+
+	reconnect()
 }
 
 /*
- * This function loops until it gets a valid password from the user
+ * This function reconnects to the server, waiting longer after every failed attempt
  */
-func get_password_for_registration() bool {
-	var packet Data_packet
-	var input []byte
+func reconnect() {
+	delay := RECONNECT_MIN_DELAY
 
-	// clearing terminal
-	clear_terminal()
+	for attempt := 1; ; attempt++ {
+		set_status_notice(fmt.Sprintf("Lost connection to the server. Reconnecting in %s (attempt %d)...", delay, attempt))
+		time.Sleep(delay)
 
-	print_registration_password(string(input), nil)
-
-	for {
-		if err := keyboard.Open(); err != nil {
-			panic(err)
+		// giving up on reconnecting if the user quit in the meantime
+		if client_status == QUITTING {
+			return
 		}
 
-		// getting user input
-		for {
-			// getting key press
-			char, key, err := keyboard.GetSingleKey()
-			if err != nil {
-				panic(err)
+		// opening both sockets again
+		command, err := dial_server()
+		if err == nil {
+			data, err := open_data_connection(command)
+			if err == nil {
+				reader := bufio.NewReaderSize(data, MAX_PACKET_SIZE)
+
+				// picking up where the client left off
+				if resume_session(data, reader) {
+					connection_mutex.Lock()
+					command_socket = command
+					command_reader = bufio.NewReaderSize(command, MAX_PACKET_SIZE)
+					data_socket = data
+					data_reader = reader
+					connection_generation++
+					connection_closed = make(chan struct{})
+					generation := connection_generation
+					connection_mutex.Unlock()
+
+					go handle_inbound_commands(generation)
+					flash_status_notice("Reconnected to the server")
+					return
+				}
+				data.Close()
 			}
+			command.Close()
+		}
 
-			// checking if enter key was pressed
-			if key == keyboard.KeyEnter {
-				break
-			} else if key == keyboard.KeyBackspace || key == keyboard.KeyBackspace2 {
-				if len(input) > 0 {
-					input = input[:len(input)-1]
-				}
-			} else if key == keyboard.KeyCtrlC {
-				var cpack Command_packet
-				cpack.Type = EXIT
-				cpack.Username = ""
-				cpack.Arguments = []byte("client disconnecting")
-				client_status = QUITTING
-				exit_command(cpack)
-			} else if key == keyboard.KeySpace {
-				input = append(input, ' ')
-			} else if key == keyboard.KeyTab || key == keyboard.KeyArrowLeft || key == keyboard.KeyArrowRight || key == keyboard.KeyArrowDown || key == keyboard.KeyArrowUp {
-				continue
-			} else if key == keyboard.KeyEsc {
-				client_status = CHOOSING_SIGN_IN_OPT
-				username = ""
-				var dpack Data_packet
-				dpack.Type = ESC
-				dpack.Data = []byte("User hit ESC")
-				send_data_packet(dpack)
-				return false
-			} else {
-				input = append(input, byte(char))
-			}
-			// clearing the terminal
-			clear_terminal()
+		// backing off before trying again
+		delay = min(delay*2, RECONNECT_MAX_DELAY)
+	}
+}
 
-			// printing login screen
-			print_registration_password(string(input), nil)
+/*
+ * This function puts the server back where the client left off on a new connection.
+ * It resumes the session if there is one, rejoins the channel the user was in and asks for the messages
+ * that were missed. It returns false if the connection was lost again
+ */
+func resume_session(data net.Conn, reader *bufio.Reader) bool {
+	// starting over on the sign in screen the user was on if there is no session to resume
+	if session_token == "" {
+		if client_status == LOGGING_IN {
+			write_to_connection(marshal_data_packet(Data_packet{Type: MENU_OPTION, Data: []byte("LOGIN")}), data)
+		} else if client_status == REGISTERING {
+			write_to_connection(marshal_data_packet(Data_packet{Type: MENU_OPTION, Data: []byte("REGISTER")}), data)
+		} else {
+			client_status = CHOOSING_SIGN_IN_OPT
 		}
+		return true
+	}
 
-		// checking if the user entered a command
-		if is_comand(string(input)) {
-			msg := handle_command(string(input))
-			input = nil
-			clear_terminal()
-			print_registration_password(string(input), msg)
-			continue
-		}
+	// presenting session token
+	write_to_connection(marshal_data_packet(Data_packet{Type: RESUME, Username: username, Data: []byte(session_token)}), data)
+	packet, ok := read_data_packet_from(reader)
+	if !ok {
+		return false
+	}
 
-		// declaring and initializing packet
-		packet = Data_packet{Type: REGISTRATION, Data: []byte(input)}
+	// going back to the sign in menu if the session can not be resumed
+	if packet.Type != ACCEPT {
+		flash_status_notice(string(packet.Data))
+		session_token = ""
+		save_remembered_session()
+		username = ""
+		client_status = CHOOSING_SIGN_IN_OPT
+		return true
+	}
 
-		// sending packet containging username
-		send_data_packet(packet)
+	// keeping the fresh token the server sends back
+	packet, ok = read_data_packet_from(reader)
+	if !ok {
+		return false
+	}
+	session_token = string(packet.Data)
+	save_remembered_session()
+
+	// fetching the channel list again if the menu is showing or the user needs to rejoin a channel
+	if client_status == MESSAGING || menu_ready {
+		write_to_connection(marshal_data_packet(Data_packet{Type: MAIN, Username: username, Data: []byte("READY")}), data)
+		packet, ok = read_data_packet_from(reader)
+		if !ok {
+			return false
+		}
+		channels = build_menu(string(packet.Data))
+		set_unread_counts(packet)
+	}
 
-		// waiting for response
-		packet = read_data_packet()
+	if client_status != MESSAGING {
+		return true
+	}
 
-		// checking response from server
-		// checking if username was accepted
-		if packet.Type == ACCEPT {
-			client_status = IN_MAIN_MENU
-			break
-		} else {
-			go play_sound("error.mp3")
-			print_registration_password(string(input), packet.Data)
-		}
+	// going back to the main menu if the channel no longer exists
+	if !slices.Contains(channels[:len(channels)-3], string(current_channel)) {
+		flash_status_notice("The channel you were in no longer exists")
+		mutex_chat.Lock()
+		chat_strand = nil
+		mutex_chat.Unlock()
+		menu_prefetched = true
+		client_status = IN_MAIN_MENU
+		return true
 	}
 
+	// rejoining channel
+	write_to_connection(marshal_data_packet(Data_packet{Type: MENU_OPTION, Username: username, Data: current_channel}), data)
+
+	// asking for the messages sent while the client was gone, a page of older messages that was lost is
+	// asked for again the next time the user scrolls back
+	write_to_connection(marshal_data_packet(Data_packet{Type: HISTORY, Username: username, Data: []byte(strconv.Itoa(last_message_id()))}), data)
+	screen_mutex.Lock()
+	older_history_id = 0
+	screen_mutex.Unlock()
 	return true
 }
 
 /*
- * prints login screen with username prompt
+ * This function gets the id of the newest message in the chat strand
  */
-func print_registration_username(input string, error []byte) {
-	var bar []byte
-	var padding []byte
-
-	for i := 0; i < terminal_width; i++ {
-		bar = append(horizontal_line, '-')
-	}
+func last_message_id() int {
+	mutex_chat.Lock()
+	defer mutex_chat.Unlock()
 
-	for i := 0; i < terminal_width; i++ {
-		padding = append(padding, ' ')
+	last := 0
+	for _, packet := range chat_strand {
+		last = max(last, packet.Message_id)
 	}
+	return last
+}
 
-	if error == nil {
-		// printing prompt
-		fmt.Println(string(horizontal_line))
-		fmt.Println("Please enter a username. (NOTE: This will be visible to all other users)")
-		fmt.Println("Username requirements:")
-		fmt.Println("- Must start with: \"A-Z\" or \"a-z\"")
-		fmt.Println("- Must end with: \"A-Z\", \"a-z\", or \"0-9\"")
-		fmt.Println("- May contain: \"A-Z\", \"a-z\", \"0-9\", \"-\", \"_\"")
-		fmt.Println("- Must be 5-20 characters long")
-		fmt.Println(string(horizontal_line))
-
-		// printing top space
-		fmt.Print(string(vertical_space[:terminal_height/2-10]))
+/*
+ * This function gets the id of the oldest message in the chat strand, 0 if none of its messages have ids
+ */
+func first_message_id() int {
+	mutex_chat.Lock()
+	defer mutex_chat.Unlock()
 
-		// printing text box
-		if len(input) > 20 {
-			line_1 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			line_2 := "| " + input + " |"
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		} else {
-			line_1 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			var line_2 string
-			if len(input)%2 == 0 {
-				line_2 = "|" + string(padding[:(22-len(input))/2]) + input + string(padding[:(22-len(input))/2]) + "|"
-			} else {
-				line_2 = "|" + string(padding[:(22-len(input))/2+1]) + input + string(padding[:(22-len(input))/2]) + "|"
-			}
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		}
-		if terminal_height%2 == 0 {
-			fmt.Print(string(vertical_space[:terminal_height/2-2]))
-		} else {
-			fmt.Print(string(vertical_space[:terminal_height/2-1]))
-		}
-	} else {
-		fmt.Println(string(horizontal_line))
-		fmt.Println("Please enter a username. (NOTE: This will be visible to all other users)")
-		fmt.Println("Username requirements:")
-		fmt.Println("- Must start with: \"A-Z\" or \"a-z\"")
-		fmt.Println("- Must end with: \"A-Z\", \"a-z\", or \"0-9\"")
-		fmt.Println("- May contain: \"A-Z\", \"a-z\", \"0-9\", \"-\", \"_\"")
-		fmt.Println("- Must be 5-20 characters long")
-		fmt.Println(string(horizontal_line))
-		fmt.Print(string(vertical_space[:terminal_height/2-10]))
-		if len(input) > 20 {
-			line_1 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			line_2 := "| " + input + " |"
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		} else {
-			line_1 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			var line_2 string
-			if len(input)%2 == 0 {
-				line_2 = "|" + string(padding[:(22-len(input))/2]) + input + string(padding[:(22-len(input))/2]) + "|"
-			} else {
-				line_2 = "|" + string(padding[:(22-len(input))/2+1]) + input + string(padding[:(22-len(input))/2]) + "|"
-			}
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		}
-		line_4 := "         " + RED + string(error) + RESET
-		fmt.Printf("%*s\n", ((terminal_width-len(line_4))/2)+len(line_4), line_4)
-		if terminal_height%2 == 0 {
-			fmt.Print(string(vertical_space[:terminal_height/2-3]))
-		} else {
-			fmt.Print(string(vertical_space[:terminal_height/2-2]))
+	first := 0
+	for _, packet := range chat_strand {
+		if packet.Message_id != 0 && (first == 0 || packet.Message_id < first) {
+			first = packet.Message_id
 		}
 	}
+	return first
 }
 
 /*
- * prints login screen with password prompt
+ * This function adds a packet to the chat strand, keeping messages with ids in order
+ * and skipping messages that are already there
  */
-func print_registration_password(input string, error []byte) {
-	var bar []byte
-	var padding []byte
-
-	for i := 0; i < terminal_width; i++ {
-		bar = append(horizontal_line, '-')
-	}
+func add_to_chat_strand(packet Data_packet) bool {
+	mutex_chat.Lock()
+	defer mutex_chat.Unlock()
 
-	for i := 0; i < terminal_width; i++ {
-		padding = append(padding, ' ')
+	// packets without an id always go at the end
+	if packet.Message_id == 0 {
+		chat_strand = append(chat_strand, packet)
+		return true
 	}
 
-	if error == nil {
-		// prompting user
-		fmt.Println(string(horizontal_line))
-		fmt.Println("Please enter a passowrd")
-		fmt.Println("Password requirements:")
-		fmt.Println("- Must contain at least one captial letter")
-		fmt.Println("- Must contain at least one number")
-		fmt.Println("- Must contain at least one special character (!, @, #, $, %, ?)")
-		fmt.Println("- Must be at least 7 characters long")
-		fmt.Println(string(horizontal_line))
-		fmt.Print(string(vertical_space[:terminal_height/2-10]))
-
-		if len(input) > 20 {
-			line_1 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			line_2 := "| " + input + " |"
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		} else {
-			line_1 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			var line_2 string
-			if len(input)%2 == 0 {
-				line_2 = "|" + string(padding[:(22-len(input))/2]) + input + string(padding[:(22-len(input))/2]) + "|"
-			} else {
-				line_2 = "|" + string(padding[:(22-len(input))/2+1]) + input + string(padding[:(22-len(input))/2]) + "|"
-			}
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		}
-		if terminal_height%2 == 0 {
-			fmt.Print(string(vertical_space[:terminal_height/2-2]))
-		} else {
-			fmt.Print(string(vertical_space[:terminal_height/2-1]))
-		}
-	} else {
-		fmt.Println(string(horizontal_line))
-		fmt.Println("Please enter a passowrd")
-		fmt.Println("Password requirements:")
-		fmt.Println("- Must contain at least one captial letter")
-		fmt.Println("- Must contain at least one number")
-		fmt.Println("- Must contain at least one special character (!, @, #, $, %, ?)")
-		fmt.Println("- Must be at least 7 characters long")
-		fmt.Println(string(horizontal_line))
-		fmt.Print(string(vertical_space[:terminal_height/2-10]))
-
-		if len(input) > 20 {
-			line_1 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			line_2 := "| " + input + " |"
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		} else {
-			line_1 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			var line_2 string
-			if len(input)%2 == 0 {
-				line_2 = "|" + string(padding[:(22-len(input))/2]) + input + string(padding[:(22-len(input))/2]) + "|"
-			} else {
-				line_2 = "|" + string(padding[:(22-len(input))/2+1]) + input + string(padding[:(22-len(input))/2]) + "|"
-			}
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
+	// finding where the message belongs
+	for index, current := range chat_strand {
+		if current.Message_id == packet.Message_id {
+			return false
 		}
-		line_4 := "         " + RED + string(error) + RESET
-		fmt.Printf("%*s\n", ((terminal_width-len(line_4))/2)+len(line_4), line_4)
-		if terminal_height%2 == 0 {
-			fmt.Print(string(vertical_space[:terminal_height/2-3]))
-		} else {
-			fmt.Print(string(vertical_space[:terminal_height/2-2]))
+		if current.Message_id > packet.Message_id {
+			chat_strand = slices.Insert(chat_strand, index, packet)
+			return true
 		}
 	}
+	chat_strand = append(chat_strand, packet)
+	return true
 }
 
 /*
- * This function handles logging in the client
+ * This function replaces a message in the chat strand with the edited or deleted version sent by the server
  */
-func login() {
-	var packet Data_packet
-	// creating byte array to hold input
-	var input []byte
-
-	// clearing terminal
-	clear_terminal()
-
-	// prompting user
-	print_login_username(string(input), nil)
-
-	// looping until user enters a valid username or exits
-	for {
-		if err := keyboard.Open(); err != nil {
-			panic(err)
-		}
-
-		// getting user input
-		for {
-			// getting key press
-			char, key, err := keyboard.GetSingleKey()
-			if err != nil {
-				panic(err)
-			}
-
-			// checking if enter key was pressed
-			if key == keyboard.KeyEnter {
-				break
-			} else if key == keyboard.KeyBackspace || key == keyboard.KeyBackspace2 {
-				if len(input) > 0 {
-					input = input[:len(input)-1]
-				}
-			} else if key == keyboard.KeyCtrlC {
-				var cpack Command_packet
-				cpack.Type = EXIT
-				cpack.Username = ""
-				cpack.Arguments = []byte("client disconnecting")
-				client_status = QUITTING
-				exit_command(cpack)
-			} else if key == keyboard.KeySpace {
-				input = append(input, ' ')
-			} else if key == keyboard.KeyTab || key == keyboard.KeyArrowLeft || key == keyboard.KeyArrowRight || key == keyboard.KeyArrowDown || key == keyboard.KeyArrowUp {
-				continue
-			} else if key == keyboard.KeyEsc {
-				client_status = CHOOSING_SIGN_IN_OPT
-				username = ""
-				var dpack Data_packet
-				dpack.Type = ESC
-				dpack.Data = []byte("User hit ESC")
-				send_data_packet(dpack)
-				return
-			} else {
-				input = append(input, byte(char))
-			}
-			// clearing the terminal
-			clear_terminal()
-
-			// printing login screen
-			print_login_username(string(input), nil)
+func update_chat_strand(packet Data_packet) bool {
+	mutex_chat.Lock()
+	defer mutex_chat.Unlock()
+
+	for index, current := range chat_strand {
+		if current.Message_id == packet.Message_id && current.Type == MESSAGE {
+			packet.Type = MESSAGE
+			chat_strand[index] = packet
+			return true
 		}
+	}
+	return false
+}
 
-		// checking if the user entered a command
-		if is_comand(string(input)) {
-			msg := handle_command(string(input))
-			input = nil
-			clear_terminal()
-			print_login_username(string(input), msg)
-			continue
+/*
+ * This function replaces the reactions to a message in the chat strand
+ */
+func set_reactions(packet Data_packet) bool {
+	mutex_chat.Lock()
+	defer mutex_chat.Unlock()
+
+	for index, current := range chat_strand {
+		if current.Message_id == packet.Message_id && current.Type == MESSAGE {
+			chat_strand[index].Reactions = packet.Reactions
+			return true
 		}
+	}
+	return false
+}
 
-		// setting this clients username
-		username = string(input)
-
-		// initializing packet
-		packet.Type = LOGIN
-		packet.Data = []byte(username)
-		send_data_packet(packet)
-
-		// reading packet from server
-		packet = read_data_packet()
+/*
+ * This function gives the id the server assigned to the oldest message the client sent that has no id yet
+ */
+func set_sent_message_id(message_id int) {
+	mutex_chat.Lock()
+	defer mutex_chat.Unlock()
 
-		// checking if username was accepted
-		if packet.Type == ACCEPT {
-			break
-		} else {
-			go play_sound("error.mp3")
-			print_login_username(string(input), packet.Data)
+	for index, packet := range chat_strand {
+		if packet.Type == MESSAGE && packet.Username == username && packet.Message_id == 0 {
+			chat_strand[index].Message_id = message_id
+			return
 		}
 	}
+}
 
-	// clearing terminal
-	clear_terminal()
+/*
+ * This function sends a packet to the data socket
+ */
+func send_data_packet(packet Data_packet) {
+	json_data := marshal_data_packet(packet)
+	_, data := get_sockets()
+	write_to_connection(json_data, data)
+}
 
-	// prompting user
-	fmt.Println("Enter your password below:")
-	fmt.Println(string(horizontal_line))
+/*
+ * This function reads a packet from the data socket.
+ * If the connection is lost it reconnects and returns a RESUME packet
+ * so the caller knows to start over from the current client status
+ */
+func read_data_packet() Data_packet {
+	connection_mutex.Lock()
+	generation := connection_generation
+	reader := data_reader
+	connection_mutex.Unlock()
+
+	packet, ok := read_data_packet_from(reader)
+	if !ok {
+		connection_lost(generation)
+		return Data_packet{Type: RESUME}
+	}
+	return packet
+}
 
-	// resetting input
-	input = nil
+/*
+ * This function reads a packet from the given reader and reports if the read succeeded
+ */
+func read_data_packet_from(reader *bufio.Reader) (Data_packet, bool) {
+	json_data, amount_read := read_from_connection(reader)
+	if amount_read < 0 {
+		return Data_packet{}, false
+	}
+	return unmarshal_data_packet(json_data[:amount_read]), true
+}
 
-	var password_mask []byte
+/*
+ * This function writes to a specified socket
+ */
+func write_to_connection(data []byte, connection net.Conn) {
+	_, err := connection.Write(data)
+	if err != nil {
+		fmt.Println("system: Failed to write to socket")
+	}
+}
 
-	// clearing terminal
-	clear_terminal()
+/*
+ * This function reads a single packet from the reader of a socket
+ */
+func read_from_connection(reader *bufio.Reader) ([]byte, int) {
+	data, err := reader.ReadBytes(PACKET_DELIMITER)
+	if err != nil {
+		return nil, -1
+	}
+	return data, len(data)
+}
 
-	// printing prompt
-	print_login_password(string(password_mask), nil)
+/*
+ * This function marshals a packet into a json file
+ */
+func marshal_data_packet(packet Data_packet) []byte {
+	json_data, err := json.Marshal(packet)
+	if err != nil {
+		fmt.Println("server: Error marshaling data-", err.Error())
+	}
 
-	// looping until user enters valid password or exits
-	for {
-		if err := keyboard.Open(); err != nil {
-			panic(err)
-		}
+	// adding delimiter so the server can tell packets apart
+	return append(json_data, PACKET_DELIMITER)
+}
+
+/*
+ * This function unmarshals json data into a packetand handles the possible errors
+ */
+func unmarshal_data_packet(json_data []byte) Data_packet {
+	var packet Data_packet
+	err := json.Unmarshal(json_data, &packet)
+	if err != nil {
+		error_exit(err)
+	}
+	return packet
+}
+
+/*
+ * This function marshals a packet into a json file
+ */
+func marshal_command_packet(packet Command_packet) []byte {
+	json_data, err := json.Marshal(packet)
+	if err != nil {
+		fmt.Println("server: Error marshaling data-", err.Error())
+	}
+
+	// adding delimiter so the server can tell packets apart
+	return append(json_data, PACKET_DELIMITER)
+}
+
+/*
+ * This function unmarshals json data into a packetand handles the possible errors
+ */
+func unmarshal_command_packet(json_data []byte) Command_packet {
+	var packet Command_packet
+	err := json.Unmarshal(json_data, &packet)
+	if err != nil {
+		error_exit(err)
+	}
+	return packet
+}
+
+/*
+ * this function closes the sockets and exits the program
+ */
+func shutdown() {
+	close_screen()
+	fmt.Println("system: Shutting down...")
+	command, data := get_sockets()
+	command.Close()
+	data.Close()
+	os.Exit(0)
+}
+
+/*
+ * This function handles the client choosing to either sign in or register
+ */
+func choose_sign_in_opt() {
+	var packet Data_packet
+	current_choice := 0
+
+	// creating channel to send client choice
+	choice_channel := make(chan int)
+
+	// creating routine to handle printing the sign in menu
+	go display_sign_in_menu(choice_channel)
+
+	// creating a keyboard reader
+	if err := keyboard.Open(); err != nil {
+		panic(err)
+	}
+	defer keyboard.Close()
+
+	// looping until enter is pressed
+	for {
+		// getting key press
+		_, key, err := keyboard.GetSingleKey()
+		if err != nil {
+			panic(err)
+		}
+
+		// Check if the pressed key is the up or down arrow
+		if key == keyboard.KeyArrowUp {
+			current_choice += 2
+			current_choice = current_choice % 3
+			choice_channel <- current_choice
+		} else if key == keyboard.KeyArrowDown {
+			current_choice++
+			current_choice = current_choice % 3
+			choice_channel <- current_choice
+		}
+
+		// Break the loop if Enter key is pressed
+		if key == keyboard.KeyEnter {
+			// send signal to display_sign_in_menu
+			choice_channel <- 3
+
+			// setting state based on choice and preparing
+			// packet to be sent to the server with the choice
+			if current_choice == 0 {
+				packet.Type = MENU_OPTION
+				packet.Data = []byte("LOGIN")
+				client_status = LOGGING_IN
+				send_data_packet(packet)
+			} else if current_choice == 1 {
+				packet.Type = MENU_OPTION
+				packet.Data = []byte("REGISTER")
+				client_status = REGISTERING
+				send_data_packet(packet)
+			} else if current_choice == 2 {
+				var cpack Command_packet
+				cpack.Type = EXIT
+				cpack.Username = ""
+				cpack.Arguments = []byte("client disconnecting")
+				client_status = QUITTING
+				exit_command(cpack)
+			}
+			break
+		}
+	}
+}
+
+/*
+ * This function is called as a go routine to display the sign in options
+ */
+func display_sign_in_menu(choice chan int) {
+	currently_selected := 0
+	opt_1 := 0
+	opt_2 := 0
+	opt_3 := 0
+
+	for {
+		select {
+		case c := <-choice:
+			switch c {
+			case 3:
+				return
+			case 0:
+				currently_selected = 0
+			case 1:
+				currently_selected = 1
+			case 2:
+				currently_selected = 2
+			}
+		default:
+			switch currently_selected {
+			case 0:
+				if opt_1 == 16 {
+					opt_1 = 0
+				} else if opt_1 < 8 {
+					draw(func(screen *strings.Builder) { display_opt_0_selected(screen) })
+					opt_1++
+				} else if opt_1 < 16 {
+					draw(func(screen *strings.Builder) { display_opt(screen) })
+					opt_1++
+				}
+				time.Sleep(30 * time.Millisecond)
+
+			case 1:
+				if opt_2 == 16 {
+					opt_2 = 0
+				} else if opt_2 < 8 {
+					draw(func(screen *strings.Builder) { display_opt_1_selected(screen) })
+					opt_2++
+				} else if opt_2 < 16 {
+					draw(func(screen *strings.Builder) { display_opt(screen) })
+					opt_2++
+				}
+				time.Sleep(30 * time.Millisecond)
+			case 2:
+				if opt_3 == 16 {
+					opt_3 = 0
+				} else if opt_3 < 8 {
+					draw(func(screen *strings.Builder) { display_opt_2_selected(screen) })
+					opt_3++
+				} else if opt_3 < 16 {
+					draw(func(screen *strings.Builder) { display_opt(screen) })
+					opt_3++
+				}
+				time.Sleep(30 * time.Millisecond)
+			}
+		}
+	}
+}
+
+/*
+ * This function prints a screen with the login option selected
+ */
+func display_opt_0_selected(screen *strings.Builder) {
+	fmt.Fprintln(screen, string(horizontal_line))
+	line_1 := "Select an option below"
+	fmt.Fprintln(screen, center_text(line_1))
+	line_2 := "Use the up and down arrows to change selection"
+	fmt.Fprintln(screen, center_text(line_2))
+	fmt.Fprintln(screen, string(horizontal_line))
+	fmt.Fprint(screen, blank_lines((terminal_height)/2-8))
+	line_3 := "--> LOGIN <--"
+	fmt.Fprintln(screen, center_text(line_3))
+	fmt.Fprint(screen, "\n")
+	line_4 := "CREATE ACCOUNT"
+	fmt.Fprintln(screen, center_text(line_4))
+	fmt.Fprint(screen, "\n")
+	line_5 := "QUIT"
+	fmt.Fprintln(screen, center_text(line_5))
+	fmt.Fprint(screen, blank_lines(terminal_height-(terminal_height)/2-3))
+}
+
+/*
+ * This function prints a screen with the register option selected
+ */
+func display_opt_1_selected(screen *strings.Builder) {
+	fmt.Fprintln(screen, string(horizontal_line))
+	line_1 := "Select an option below"
+	fmt.Fprintln(screen, center_text(line_1))
+	line_2 := "Use the up and down arrows to change selection"
+	fmt.Fprintln(screen, center_text(line_2))
+	fmt.Fprintln(screen, string(horizontal_line))
+	fmt.Fprint(screen, blank_lines((terminal_height)/2-8))
+	line_3 := "LOGIN"
+	fmt.Fprintln(screen, center_text(line_3))
+	fmt.Fprint(screen, "\n")
+	line_4 := "--> CREATE ACCOUNT <--"
+	fmt.Fprintln(screen, center_text(line_4))
+	fmt.Fprint(screen, "\n")
+	line_5 := "QUIT"
+	fmt.Fprintln(screen, center_text(line_5))
+	fmt.Fprint(screen, blank_lines(terminal_height-(terminal_height)/2-3))
+}
+
+/*
+ * This function prints a screen with the quit option selected
+ */
+func display_opt_2_selected(screen *strings.Builder) {
+	fmt.Fprintln(screen, string(horizontal_line))
+	line_1 := "Select an option below"
+	fmt.Fprintln(screen, center_text(line_1))
+	line_2 := "Use the up and down arrows to change selection"
+	fmt.Fprintln(screen, center_text(line_2))
+	fmt.Fprintln(screen, string(horizontal_line))
+	fmt.Fprint(screen, blank_lines((terminal_height)/2-8))
+	line_3 := "LOGIN"
+	fmt.Fprintln(screen, center_text(line_3))
+	fmt.Fprint(screen, "\n")
+	line_4 := "CREATE ACCOUNT"
+	fmt.Fprintln(screen, center_text(line_4))
+	fmt.Fprint(screen, "\n")
+	line_5 := "--> QUIT <--"
+	fmt.Fprintln(screen, center_text(line_5))
+	fmt.Fprint(screen, blank_lines(terminal_height-(terminal_height)/2-3))
+}
+
+/*
+ * This function displays all options of the sign in menu with none selected
+ */
+func display_opt(screen *strings.Builder) {
+	fmt.Fprintln(screen, string(horizontal_line))
+	line_1 := "Select an option below"
+	fmt.Fprintln(screen, center_text(line_1))
+	line_2 := "Use the up and down arrows to change selection"
+	fmt.Fprintln(screen, center_text(line_2))
+	fmt.Fprintln(screen, string(horizontal_line))
+	fmt.Fprint(screen, blank_lines((terminal_height)/2-8))
+	line_3 := "LOGIN"
+	fmt.Fprintln(screen, center_text(line_3))
+	fmt.Fprint(screen, "\n")
+	line_4 := "CREATE ACCOUNT"
+	fmt.Fprintln(screen, center_text(line_4))
+	fmt.Fprint(screen, "\n")
+	line_5 := "QUIT"
+	fmt.Fprintln(screen, center_text(line_5))
+	fmt.Fprint(screen, blank_lines(terminal_height-(terminal_height)/2-3))
+}
+
+/*
+ * This function is responcible for registering the client with the server.
+ * This includes getting a username and password to create an account
+ */
+func register_user() {
+	if !get_username_for_registration() {
+		return
+	}
+
+	if !get_password_for_registration() {
+		return
+	}
+}
+
+/*
+ * This function loops until it gets a valid username from the user
+ */
+func get_username_for_registration() bool {
+	var packet Data_packet
+	var input []byte
+
+	draw(func(screen *strings.Builder) { print_registration_username(screen, string(input), nil) })
+
+	for {
+		if err := keyboard.Open(); err != nil {
+			panic(err)
+		}
 
 		// getting user input
 		for {
@@ -1242,8 +1905,7 @@ func login() {
 				break
 			} else if key == keyboard.KeyBackspace || key == keyboard.KeyBackspace2 {
 				if len(input) > 0 {
-					input = input[:len(input)-1]
-					password_mask = password_mask[:len(password_mask)-1]
+					input = remove_last_rune(input)
 				}
 			} else if key == keyboard.KeyCtrlC {
 				var cpack Command_packet
@@ -1252,11 +1914,10 @@ func login() {
 				cpack.Arguments = []byte("client disconnecting")
 				client_status = QUITTING
 				exit_command(cpack)
-			} else if key == keyboard.KeyTab || key == keyboard.KeyArrowLeft || key == keyboard.KeyArrowRight || key == keyboard.KeyArrowDown || key == keyboard.KeyArrowUp {
-				continue
 			} else if key == keyboard.KeySpace {
 				input = append(input, ' ')
-				password_mask = append(password_mask, '*')
+			} else if key == keyboard.KeyTab || key == keyboard.KeyArrowLeft || key == keyboard.KeyArrowRight || key == keyboard.KeyArrowDown || key == keyboard.KeyArrowUp {
+				continue
 			} else if key == keyboard.KeyEsc {
 				client_status = CHOOSING_SIGN_IN_OPT
 				username = ""
@@ -1264,1011 +1925,4242 @@ func login() {
 				dpack.Type = ESC
 				dpack.Data = []byte("User hit ESC")
 				send_data_packet(dpack)
-				return
+				return false
 			} else {
-				input = append(input, byte(char))
-				password_mask = append(password_mask, '*')
+				input = utf8.AppendRune(input, char)
 			}
+			// printing login screen
+			draw(func(screen *strings.Builder) { print_registration_username(screen, string(input), nil) })
+		}
+
+		// checking if the user entered a command
+		if is_comand(string(input)) {
+			msg := handle_command(string(input))
+			input = nil
+			draw(func(screen *strings.Builder) { print_registration_username(screen, string(input), msg) })
+			continue
+		}
+
+		// declaring and initializing packet
+		packet = Data_packet{Type: REGISTRATION, Data: []byte(input)}
+
+		// sending packet containging username
+		send_data_packet(packet)
+
+		// waiting for response
+		packet = read_data_packet()
+
+		// starting over if the connection was lost and resumed
+		if packet.Type == RESUME {
+			return false
+		}
+
+		// checking response from server
+		// checking if username was accepted
+		if packet.Type == ACCEPT {
+			break
+		} else {
+			go play_sound("error.mp3")
+			draw(func(screen *strings.Builder) { print_registration_username(screen, string(input), packet.Data) })
+		}
+	}
+	return true
+}
+
+/*
+ * This function loops until it gets a valid password from the user
+ */
+func get_password_for_registration() bool {
+	var packet Data_packet
+	var input []byte
+
+	draw(func(screen *strings.Builder) { print_registration_password(screen, string(input), nil) })
+
+	for {
+		if err := keyboard.Open(); err != nil {
+			panic(err)
+		}
+
+		// getting user input
+		for {
+			// getting key press
+			char, key, err := keyboard.GetSingleKey()
+			if err != nil {
+				panic(err)
+			}
+
+			// checking if enter key was pressed
+			if key == keyboard.KeyEnter {
+				break
+			} else if key == keyboard.KeyBackspace || key == keyboard.KeyBackspace2 {
+				if len(input) > 0 {
+					input = remove_last_rune(input)
+				}
+			} else if key == keyboard.KeyCtrlC {
+				var cpack Command_packet
+				cpack.Type = EXIT
+				cpack.Username = ""
+				cpack.Arguments = []byte("client disconnecting")
+				client_status = QUITTING
+				exit_command(cpack)
+			} else if key == keyboard.KeySpace {
+				input = append(input, ' ')
+			} else if key == keyboard.KeyTab || key == keyboard.KeyArrowLeft || key == keyboard.KeyArrowRight || key == keyboard.KeyArrowDown || key == keyboard.KeyArrowUp {
+				continue
+			} else if key == keyboard.KeyEsc {
+				client_status = CHOOSING_SIGN_IN_OPT
+				username = ""
+				var dpack Data_packet
+				dpack.Type = ESC
+				dpack.Data = []byte("User hit ESC")
+				send_data_packet(dpack)
+				return false
+			} else {
+				input = utf8.AppendRune(input, char)
+			}
+			// printing login screen
+			draw(func(screen *strings.Builder) { print_registration_password(screen, string(input), nil) })
+		}
+
+		// checking if the user entered a command
+		if is_comand(string(input)) {
+			msg := handle_command(string(input))
+			input = nil
+			draw(func(screen *strings.Builder) { print_registration_password(screen, string(input), msg) })
+			continue
+		}
+
+		// declaring and initializing packet
+		packet = Data_packet{Type: REGISTRATION, Data: []byte(input)}
+
+		// sending packet containging username
+		send_data_packet(packet)
+
+		// waiting for response
+		packet = read_data_packet()
+
+		// starting over if the connection was lost and resumed
+		if packet.Type == RESUME {
+			return false
+		}
+
+		// checking response from server
+		// checking if username was accepted
+		if packet.Type == ACCEPT {
+			// keeping the token for resuming the session if the connection is lost
+			packet = read_data_packet()
+			if packet.Type == RESUME {
+				return false
+			}
+			session_token = string(packet.Data)
+			save_remembered_session()
+			username = packet.Username
+
+			client_status = IN_MAIN_MENU
+			break
+		} else {
+			go play_sound("error.mp3")
+			draw(func(screen *strings.Builder) { print_registration_password(screen, string(input), packet.Data) })
+		}
+	}
+
+	return true
+}
+
+/*
+ * prints login screen with username prompt
+ */
+func print_registration_username(screen *strings.Builder, input string, error []byte) {
+	if error == nil {
+		// printing prompt
+		fmt.Fprintln(screen, string(horizontal_line))
+		fmt.Fprintln(screen, "Please enter a username. (NOTE: This will be visible to all other users)")
+		fmt.Fprintln(screen, "Username requirements:")
+		fmt.Fprintln(screen, "- Must start with: a letter, in any alphabet")
+		fmt.Fprintln(screen, "- Must end with: a letter or a digit")
+		fmt.Fprintln(screen, "- May contain: letters, digits, \"-\", \"_\"")
+		fmt.Fprintln(screen, "- Must be 5-20 characters long")
+		fmt.Fprintln(screen, string(horizontal_line))
+
+		// printing top space
+		fmt.Fprint(screen, blank_lines(terminal_height/2-10))
+
+		// printing text box
+		if text_width(input) > 20 {
+			line_1 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_1))
+			line_2 := "| " + input + " |"
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_3))
+		} else {
+			line_1 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_1))
+			var line_2 string
+			if text_width(input)%2 == 0 {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			} else {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2+1) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			}
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_3))
+		}
+		if terminal_height%2 == 0 {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-2))
+		} else {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-1))
+		}
+	} else {
+		fmt.Fprintln(screen, string(horizontal_line))
+		fmt.Fprintln(screen, "Please enter a username. (NOTE: This will be visible to all other users)")
+		fmt.Fprintln(screen, "Username requirements:")
+		fmt.Fprintln(screen, "- Must start with: a letter, in any alphabet")
+		fmt.Fprintln(screen, "- Must end with: a letter or a digit")
+		fmt.Fprintln(screen, "- May contain: letters, digits, \"-\", \"_\"")
+		fmt.Fprintln(screen, "- Must be 5-20 characters long")
+		fmt.Fprintln(screen, string(horizontal_line))
+		fmt.Fprint(screen, blank_lines(terminal_height/2-10))
+		if text_width(input) > 20 {
+			line_1 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_1))
+			line_2 := "| " + input + " |"
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_3))
+		} else {
+			line_1 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_1))
+			var line_2 string
+			if text_width(input)%2 == 0 {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			} else {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2+1) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			}
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_3))
+		}
+		line_4 := "         " + RED + string(error) + RESET
+		fmt.Fprintln(screen, center_text(line_4))
+		if terminal_height%2 == 0 {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-3))
+		} else {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-2))
+		}
+	}
+}
+
+/*
+ * prints login screen with password prompt
+ */
+func print_registration_password(screen *strings.Builder, input string, error []byte) {
+	if error == nil {
+		// prompting user
+		fmt.Fprintln(screen, string(horizontal_line))
+		fmt.Fprintln(screen, "Please enter a passowrd")
+		fmt.Fprintln(screen, "Password requirements:")
+		fmt.Fprintln(screen, "- Must contain at least one captial letter")
+		fmt.Fprintln(screen, "- Must contain at least one number")
+		fmt.Fprintln(screen, "- Must contain at least one special character (!, @, #, $, %, ?)")
+		fmt.Fprintln(screen, "- Must be at least 7 characters long")
+		fmt.Fprintln(screen, string(horizontal_line))
+		fmt.Fprint(screen, blank_lines(terminal_height/2-10))
+
+		if text_width(input) > 20 {
+			line_1 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_1))
+			line_2 := "| " + input + " |"
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_3))
+		} else {
+			line_1 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_1))
+			var line_2 string
+			if text_width(input)%2 == 0 {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			} else {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2+1) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			}
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_3))
+		}
+		if terminal_height%2 == 0 {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-2))
+		} else {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-1))
+		}
+	} else {
+		fmt.Fprintln(screen, string(horizontal_line))
+		fmt.Fprintln(screen, "Please enter a passowrd")
+		fmt.Fprintln(screen, "Password requirements:")
+		fmt.Fprintln(screen, "- Must contain at least one captial letter")
+		fmt.Fprintln(screen, "- Must contain at least one number")
+		fmt.Fprintln(screen, "- Must contain at least one special character (!, @, #, $, %, ?)")
+		fmt.Fprintln(screen, "- Must be at least 7 characters long")
+		fmt.Fprintln(screen, string(horizontal_line))
+		fmt.Fprint(screen, blank_lines(terminal_height/2-10))
+
+		if text_width(input) > 20 {
+			line_1 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_1))
+			line_2 := "| " + input + " |"
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_3))
+		} else {
+			line_1 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_1))
+			var line_2 string
+			if text_width(input)%2 == 0 {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			} else {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2+1) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			}
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_3))
+		}
+		line_4 := "         " + RED + string(error) + RESET
+		fmt.Fprintln(screen, center_text(line_4))
+		if terminal_height%2 == 0 {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-3))
+		} else {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-2))
+		}
+	}
+}
+
+/*
+ * This function handles logging in the client
+ */
+func login() {
+	var packet Data_packet
+	// creating byte array to hold input
+	var input []byte
+
+	// prompting user
+	draw(func(screen *strings.Builder) { print_login_username(screen, string(input), nil) })
+
+	// looping until user enters a valid username or exits
+	for {
+		if err := keyboard.Open(); err != nil {
+			panic(err)
+		}
+
+		// getting user input
+		for {
+			// getting key press
+			char, key, err := keyboard.GetSingleKey()
+			if err != nil {
+				panic(err)
+			}
+
+			// checking if enter key was pressed
+			if key == keyboard.KeyEnter {
+				break
+			} else if key == keyboard.KeyBackspace || key == keyboard.KeyBackspace2 {
+				if len(input) > 0 {
+					input = remove_last_rune(input)
+				}
+			} else if key == keyboard.KeyCtrlC {
+				var cpack Command_packet
+				cpack.Type = EXIT
+				cpack.Username = ""
+				cpack.Arguments = []byte("client disconnecting")
+				client_status = QUITTING
+				exit_command(cpack)
+			} else if key == keyboard.KeySpace {
+				input = append(input, ' ')
+			} else if key == keyboard.KeyTab || key == keyboard.KeyArrowLeft || key == keyboard.KeyArrowRight || key == keyboard.KeyArrowDown || key == keyboard.KeyArrowUp {
+				continue
+			} else if key == keyboard.KeyEsc {
+				client_status = CHOOSING_SIGN_IN_OPT
+				username = ""
+				var dpack Data_packet
+				dpack.Type = ESC
+				dpack.Data = []byte("User hit ESC")
+				send_data_packet(dpack)
+				return
+			} else {
+				input = utf8.AppendRune(input, char)
+			}
+			// printing login screen
+			draw(func(screen *strings.Builder) { print_login_username(screen, string(input), nil) })
+		}
+
+		// checking if the user entered a command
+		if is_comand(string(input)) {
+			msg := handle_command(string(input))
+			input = nil
+			draw(func(screen *strings.Builder) { print_login_username(screen, string(input), msg) })
+			continue
+		}
+
+		// setting this clients username
+		username = string(input)
+
+		// initializing packet
+		packet.Type = LOGIN
+		packet.Data = []byte(username)
+		send_data_packet(packet)
+
+		// reading packet from server
+		packet = read_data_packet()
+
+		// starting over if the connection was lost and resumed
+		if packet.Type == RESUME {
+			return
+		}
+
+		// checking if username was accepted
+		if packet.Type == ACCEPT {
+			break
+		} else {
+			go play_sound("error.mp3")
+			draw(func(screen *strings.Builder) { print_login_username(screen, string(input), packet.Data) })
+		}
+	}
+
+	// resetting input
+	input = nil
+
+	var password_mask []byte
+
+	// printing prompt
+	draw(func(screen *strings.Builder) { print_login_password(screen, string(password_mask), nil) })
+
+	// looping until user enters valid password or exits
+	for {
+		if err := keyboard.Open(); err != nil {
+			panic(err)
+		}
+
+		// getting user input
+		for {
+			// getting key press
+			char, key, err := keyboard.GetSingleKey()
+			if err != nil {
+				panic(err)
+			}
+
+			// checking if enter key was pressed
+			if key == keyboard.KeyEnter {
+				break
+			} else if key == keyboard.KeyBackspace || key == keyboard.KeyBackspace2 {
+				if len(input) > 0 {
+					input = remove_last_rune(input)
+					password_mask = password_mask[:len(password_mask)-1]
+				}
+			} else if key == keyboard.KeyCtrlC {
+				var cpack Command_packet
+				cpack.Type = EXIT
+				cpack.Username = ""
+				cpack.Arguments = []byte("client disconnecting")
+				client_status = QUITTING
+				exit_command(cpack)
+			} else if key == keyboard.KeyTab || key == keyboard.KeyArrowLeft || key == keyboard.KeyArrowRight || key == keyboard.KeyArrowDown || key == keyboard.KeyArrowUp {
+				continue
+			} else if key == keyboard.KeySpace {
+				input = append(input, ' ')
+				password_mask = append(password_mask, '*')
+			} else if key == keyboard.KeyEsc {
+				client_status = CHOOSING_SIGN_IN_OPT
+				username = ""
+				var dpack Data_packet
+				dpack.Type = ESC
+				dpack.Data = []byte("User hit ESC")
+				send_data_packet(dpack)
+				return
+			} else {
+				input = utf8.AppendRune(input, char)
+				password_mask = append(password_mask, '*')
+			}
+
+			// print login screen
+			draw(func(screen *strings.Builder) { print_login_password(screen, string(password_mask), nil) })
+		}
+
+		// checking if command was entered
+		if is_comand(string(input)) {
+			msg := handle_command(string(input))
+			input = nil
+			draw(func(screen *strings.Builder) { print_login_username(screen, string(input), msg) })
+			continue
+		}
+
+		// initializing packet
+		packet.Type = LOGIN
+		packet.Data = input
+		send_data_packet(packet)
+
+		// reading packet from server
+		packet = read_data_packet()
+
+		// starting over if the connection was lost and resumed
+		if packet.Type == RESUME {
+			return
+		}
+
+		// determining if password was accepted
+		if packet.Type == ACCEPT {
+			// keeping the token for resuming the session if the connection is lost
+			token_packet := read_data_packet()
+			if token_packet.Type == RESUME {
+				return
+			}
+			session_token = string(token_packet.Data)
+			save_remembered_session()
+
+			msg := "         " + GREEN + string(packet.Data) + RESET
+			draw(func(screen *strings.Builder) {
+				fmt.Fprint(screen, blank_lines(terminal_height/2-1))
+				fmt.Fprintln(screen, center_text(msg))
+				fmt.Fprint(screen, blank_lines(terminal_height/2))
+			})
+			client_status = IN_MAIN_MENU
+			break
+		} else {
+			go play_sound("error.mp3")
+			draw(func(screen *strings.Builder) { print_login_password(screen, string(password_mask), packet.Data) })
+		}
+	}
+
+	// sleeping to give user time to see message
+	time.Sleep(2 * time.Second)
+}
+
+/*
+ * prints login screen with username prompt
+ */
+func print_login_username(screen *strings.Builder, input string, error []byte) {
+	if error == nil {
+		// printing space above text box
+		fmt.Fprint(screen, blank_lines(terminal_height/2-2))
+
+		// printing prompt
+		line_1 := "Enter your username below:"
+		fmt.Fprintln(screen, center_text(line_1))
+
+		// printing text box
+		if text_width(input) > 20 {
+			line_1 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_1))
+			line_2 := "| " + input + " |"
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_3))
+		} else {
+			line_1 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_1))
+			var line_2 string
+			if text_width(input)%2 == 0 {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			} else {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2+1) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			}
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_3))
+		}
+		if terminal_height%2 == 0 {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-2))
+		} else {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-1))
+		}
+	} else {
+		fmt.Fprint(screen, blank_lines(terminal_height/2-2))
+		line_1 := "Enter your username below:"
+		fmt.Fprintln(screen, center_text(line_1))
+		if text_width(input) > 20 {
+			line_1 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_1))
+			line_2 := "| " + input + " |"
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_3))
+		} else {
+			line_1 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_1))
+			var line_2 string
+			if text_width(input)%2 == 0 {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			} else {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2+1) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			}
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_3))
+		}
+		line_4 := "         " + RED + string(error) + RESET
+		fmt.Fprintln(screen, center_text(line_4))
+
+		if terminal_height%2 == 0 {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-3))
+		} else {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-2))
+		}
+	}
+}
+
+/*
+ * prints login screen with password prompt
+ */
+func print_login_password(screen *strings.Builder, input string, error []byte) {
+	if error == nil {
+		fmt.Fprint(screen, blank_lines(terminal_height/2-2))
+		line_1 := "Enter your password below:"
+		fmt.Fprintln(screen, center_text(line_1))
+		if text_width(input) > 20 {
+			line_1 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_1))
+			line_2 := "| " + input + " |"
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_3))
+		} else {
+			line_1 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_1))
+			var line_2 string
+			if text_width(input)%2 == 0 {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			} else {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2+1) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			}
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_3))
+		}
+		if terminal_height%2 == 0 {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-2))
+		} else {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-1))
+		}
+	} else {
+		// printing top space
+		fmt.Fprint(screen, blank_lines(terminal_height/2-2))
+
+		// printing prompt
+		line_1 := "Enter your password below:"
+		fmt.Fprintln(screen, center_text(line_1))
+
+		// printing text box
+		if text_width(input) > 20 {
+			line_1 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_1))
+			line_2 := "| " + input + " |"
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", text_width(input)+4)
+			fmt.Fprintln(screen, center_text(line_3))
+		} else {
+			line_1 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_1))
+			var line_2 string
+			if text_width(input)%2 == 0 {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			} else {
+				line_2 = "|" + strings.Repeat(" ", (22-text_width(input))/2+1) + input + strings.Repeat(" ", (22-text_width(input))/2) + "|"
+			}
+			fmt.Fprintln(screen, center_text(line_2))
+			line_3 := strings.Repeat("-", 24)
+			fmt.Fprintln(screen, center_text(line_3))
+		}
+		line_4 := "         " + RED + string(error) + RESET
+		fmt.Fprintln(screen, center_text(line_4))
+
+		// printing bottom space
+		if terminal_height%2 == 0 {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-3))
+		} else {
+			fmt.Fprint(screen, blank_lines(terminal_height/2-2))
+		}
+	}
+}
+
+/*
+ * This function is responcible for handling messaging
+ */
+func message() {
+	// var input string
+	var input []byte
+	var err_msg []byte
+	var packet Data_packet
+
+	// starting in the channel rather than a thread or its pins
+	update_chat_view(func(view *Chat_view) { *view = Chat_view{} })
+	mutex_channel_info.Lock()
+	channel_info = Channel_info{Name: string(current_channel)}
+	mutex_channel_info.Unlock()
+
+	// waiting for the history of the channel before showing where the user stopped reading
+	loading_history = true
+	first_unread_id = 0
+	if history_timer != nil {
+		history_timer.Stop()
+	}
+	history_timer = time.AfterFunc(HISTORY_TIMEOUT, history_timed_out)
+	jump_to_newest()
+
+	// older messages are fetched again as the user scrolls back
+	screen_mutex.Lock()
+	older_history_id = 0
+	reached_beginning = false
+	screen_mutex.Unlock()
+
+	// forgetting who was typing in the last channel
+	mutex_typing.Lock()
+	clear(typing_users)
+	mutex_typing.Unlock()
+
+	// when the channel was last told the user is typing, zero while the user is not typing
+	var typing_sent time.Time
+
+	// drawing the chat strand with a copy of what is being typed, inbound messages draw it again as they arrive
+	draw_chat := func() {
+		update_chat_view(func(view *Chat_view) { view.Input, view.Err_msg = slices.Clone(input), err_msg })
+		draw(print_chat_strand)
+	}
+
+	// starting a go routine to handle inbound messages
+	go handle_inbound_msg()
+
+	// scanning user inputs and sending messages
+	if err := keyboard.Open(); err != nil {
+		panic(err)
+	}
+
+	for {
+
+		input = nil
+		// printing the chat strand unless the server has already turned the user away from the channel
+		update_chat_view(func(view *Chat_view) { view.Input, view.Err_msg = nil, err_msg })
+		draw_in_state(MESSAGING, print_chat_strand)
+
+		// getting user input
+		for {
+			// getting key press
+			char, key, err := keyboard.GetSingleKey()
+			if err != nil {
+				panic(err)
+			}
+
+			// checking if the channel was lost while reconnecting
+			if client_status != MESSAGING {
+				return
+			}
+
+			// checking if enter key was pressed, in paste mode it adds a line and ctrl+d sends instead
+			if (key == keyboard.KeyEnter && !chat_view.Paste_mode) || (key == keyboard.KeyCtrlD && chat_view.Paste_mode) {
+				err_msg = nil
+				typing_sent = update_typing(nil, typing_sent)
+				break
+			} else if key == keyboard.KeyEnter || key == keyboard.KeyCtrlJ {
+				// starting a new line of the message
+				input = append(input, '\n')
+			} else if key == keyboard.KeyEsc && chat_view.Paste_mode {
+				// leaving paste mode without sending anything
+				update_chat_view(func(view *Chat_view) { view.Paste_mode = false })
+				input = nil
+			} else if key == keyboard.KeyBackspace || key == keyboard.KeyBackspace2 {
+				if len(input) > 0 {
+					input = remove_last_rune(input)
+				}
+			} else if key == keyboard.KeyCtrlC {
+				var cpack Command_packet
+				cpack.Type = EXIT
+				cpack.Username = ""
+				cpack.Arguments = []byte("client disconnecting")
+				client_status = QUITTING
+				exit_command(cpack)
+			} else if key == keyboard.KeyArrowUp || key == keyboard.KeyArrowDown || key == keyboard.KeyPgup || key == keyboard.KeyPgdn || key == keyboard.KeyEnd {
+				// scrolling through the chat strand, which does not count as typing
+				scroll_with_key(key)
+				draw_chat()
+				continue
+			} else if key == keyboard.KeyTab && chat_view.Paste_mode {
+				// keeping the indentation of pasted code
+				input = append(input, '\t')
+			} else if key == keyboard.KeyTab || key == keyboard.KeyArrowLeft || key == keyboard.KeyArrowRight {
+				continue
+			} else if key == keyboard.KeyEsc && chat_view.Showing_pins {
+				// going from the pinned messages back to the whole channel
+				update_chat_view(func(view *Chat_view) { view.Showing_pins = false })
+			} else if key == keyboard.KeyEsc && chat_view.Thread_id != 0 {
+				// going from the thread back to the whole channel
+				update_chat_view(func(view *Chat_view) { view.Thread_id = 0 })
+			} else if key == keyboard.KeyEsc {
+				update_typing(nil, typing_sent)
+
+				var cpack Command_packet
+				cpack.Type = MAIN
+				cpack.Username = username
+				main_command(cpack)
+				return
+			} else if key == keyboard.KeySpace {
+				input = append(input, ' ')
+			} else {
+				input = utf8.AppendRune(input, char)
+			}
+
+			// letting the channel know whether the user is typing
+			typing_sent = update_typing(input, typing_sent)
+
+			// printing login screen
+			draw_chat()
+		}
+
+		// messages written in paste mode are sent as they are, even if they start like a command
+		pasted := chat_view.Paste_mode
+		update_chat_view(func(view *Chat_view) { view.Paste_mode = false })
+
+		if strings.TrimSpace(string(input)) == "" {
+			continue
+		}
+
+		// checks if a command was entered and executes it if it was
+		if !pasted && is_comand(string(input)) {
+			err_msg = handle_command(string(input))
+			if client_status != MESSAGING {
+				return
+			}
+			draw_chat()
+			continue
+		}
+
+		// declaring and initializing packet
+		packet.Type = MESSAGE
+		packet.Data = []byte(input)
+		packet.Username = username
+
+		// messages sent from a thread reply to the message the thread was opened on
+		packet.Parent_id = chat_view.Thread_id
+
+		// showing the message the user sends
+		jump_to_newest()
+
+		// adding new message to chat strand
+		add_to_chat_strand(packet)
+
+		// sending message to server
+		send_data_packet(packet)
+
+		// reprinting chat strand
+		if client_status == MESSAGING {
+			draw_chat()
+		}
+	}
+}
+
+/*
+ * This function is responcible for handling inbound messages
+ */
+func handle_inbound_msg() {
+
+	// reading inbound messages
+	for {
+
+		// reading packet
+		packet := read_data_packet()
+
+		if packet.Type == CLOSE {
+			return
+		}
+
+		// going back to the main menu if the server turned the user away from the channel
+		if packet.Type == DENY {
+			removed_from_channel(Command_packet{Type: DENY, Username: username, Arguments: packet.Data})
+			return
+		}
+
+		// checking if the connection was lost and resumed
+		if packet.Type == RESUME {
+			// stopping if the client could not get back into the channel
+			if client_status != MESSAGING {
+				return
+			}
+			redraw()
+			continue
+		}
+
+		// adding a page of older messages above the ones the chat strand has
+		if packet.Type == OLDER_HISTORY {
+			// skipping pages asked for in a channel the user has since left
+			if !is_awaited_page(packet.Message_id) {
+				continue
+			}
+
+			var older []Data_packet
+			if err := json.Unmarshal(packet.Data, &older); err != nil {
+				custom_error_exit(UNEXPECTED_DATA)
+			}
+			for _, message := range older {
+				add_to_chat_strand(message)
+			}
+			screen_mutex.Lock()
+			reached_beginning = len(older) == 0
+			older_history_id = 0
+			screen_mutex.Unlock()
+			scroll_to_jump()
+
+			if client_status == MESSAGING {
+				redraw()
+			}
+			continue
+		}
+
+		// adding the history of the channel, or the messages missed while reconnecting
+		if packet.Type == HISTORY {
+			var history []Data_packet
+			if err := json.Unmarshal(packet.Data, &history); err != nil {
+				custom_error_exit(UNEXPECTED_DATA)
+			}
+			for _, message := range history {
+				if add_to_chat_strand(message) && !loading_history && message.Username != username {
+					count_unseen_message()
+				}
+			}
+
+			if client_status == MESSAGING && !loading_history {
+				redraw()
+			}
+			continue
+		}
+
+		// scrolling to the first unread message once the history of the channel has arrived
+		if packet.Type == READ_MARKER {
+			set_read_marker(packet.Message_id)
+			scroll_to_jump()
+			if client_status == MESSAGING {
+				redraw()
+			}
+			continue
+		}
+
+		// showing who is typing
+		if packet.Type == TYPING || packet.Type == NOT_TYPING {
+			set_typing(packet.Username, packet.Type == TYPING)
+			if client_status == MESSAGING && !loading_history {
+				redraw()
+			}
+			continue
+		}
+
+		// recording the id the server gave to a message this client sent
+		if packet.Type == MESSAGE_SENT {
+			set_sent_message_id(packet.Message_id)
+			continue
+		}
+
+		// updating the reactions to a message
+		if packet.Type == REACTED {
+			if set_reactions(packet) && client_status == MESSAGING {
+				redraw()
+			}
+			continue
+		}
+
+		// changing a message that was edited or deleted
+		if packet.Type == EDITED || packet.Type == DELETED {
+			if update_chat_strand(packet) && client_status == MESSAGING {
+				redraw()
+			}
+			continue
+		}
+
+		// updating the banner and pins of the channel
+		if packet.Type == CHANNEL_INFO {
+			var info Channel_info
+			if err := json.Unmarshal(packet.Data, &info); err != nil {
+				custom_error_exit(UNEXPECTED_DATA)
+			}
+			mutex_channel_info.Lock()
+			channel_info = info
+			mutex_channel_info.Unlock()
+
+			if client_status == MESSAGING && !loading_history {
+				redraw()
+			}
+			continue
+		}
+
+		// letting the user scroll back again when the server turned down a page of older messages
+		if packet.Type == RATE_LIMITED && packet.Message_id != 0 {
+			if is_awaited_page(packet.Message_id) {
+				screen_mutex.Lock()
+				older_history_id = 0
+				screen_mutex.Unlock()
+				if client_status == MESSAGING {
+					flash_status_notice("Older messages not loaded: " + string(packet.Data))
+				}
+			}
+			continue
+		}
+
+		// checking if the server rejected a message for being sent too quickly
+		if packet.Type == RATE_LIMITED {
+			// adding notice to the chat strand
+			mutex_chat.Lock()
+			chat_strand = append(chat_strand, packet)
+			mutex_chat.Unlock()
+
+			if client_status == MESSAGING {
+				go play_sound("error.mp3")
+				redraw()
+			}
+		}
+
+		// checking packet type
+		if packet.Type == MESSAGE || packet.Type == JOIN_MSG || packet.Type == LEAVE_MSG {
+			// adding new message to chat strand, skipping messages caught up on that were already there
+			if !add_to_chat_strand(packet) {
+				continue
+			}
+
+			// the history of the channel is shown all at once when it has arrived
+			if loading_history {
+				continue
+			}
+
+			// a user who sent a message is done typing it
+			if packet.Type == MESSAGE {
+				set_typing(packet.Username, false)
+				count_unseen_message()
+			}
+
+			if client_status == MESSAGING {
+
+				if packet.Type == JOIN_MSG {
+					go play_sound("joining.mp3")
+				} else if packet.Type == LEAVE_MSG {
+					go play_sound("leaving.mp3")
+				} else if packet.Username != username && slices.Contains(packet.Mentions, username) {
+					go play_pitched_sound("receive.mp3", MENTION_SOUND_RATIO)
+				} else if packet.Username != username {
+					// messages sent from the user's other devices arrive silently
+					go play_sound("receive.mp3")
+				}
+
+				// reprinting updated chat strand
+				redraw()
+			}
+		}
+	}
+}
+
+/*
+ * This function handles when the user presses ctrl-c
+ */
+func handle_ctrl_c(sigChan chan os.Signal) {
+	// Wait for a SIGINT signal
+	<-sigChan
+
+	// informing client that the signal was recieved
+	close_screen()
+	fmt.Println("\nExiting CHAT 429")
+
+	var cpack Command_packet
+	cpack.Type = EXIT
+	cpack.Username = ""
+	cpack.Arguments = []byte("client disconnecting")
+	client_status = QUITTING
+	exit_command(cpack)
+}
+
+/*
+ * This function gets passed a type of error and closes the
+ * client while informing the server that the client is disconnecting
+ */
+func error_exit(err error) {
+	close_screen()
+	fmt.Println("system: ERROR -", err)
+	var cpack Command_packet
+	cpack.Type = EXIT
+	cpack.Username = ""
+	cpack.Arguments = []byte("Error, disconnecting")
+	client_status = QUITTING
+	exit_command(cpack)
+}
+
+/*
+ * This function writes the banner at the start of the chat strand with the name, topic and description of the channel,
+ * or what is shown instead of the whole channel
+ */
+func write_channel_banner(strand *strings.Builder, info Channel_info, width int) {
+	var lines []string
+
+	// the name and topic of the channel
+	title := "#" + string(current_channel)
+	if info.Topic != "" {
+		title += " - " + info.Topic
+	}
+
+	if chat_view.Showing_pins {
+		lines = append(lines, "Pinned messages in #"+string(current_channel)+" - press esc to see the whole channel")
+		if len(info.Pinned) == 0 {
+			lines = append(lines, "Nothing is pinned yet")
+		}
+	} else if chat_view.Thread_id != 0 {
+		lines = append(lines, "Thread of message #"+strconv.Itoa(chat_view.Thread_id)+" in #"+string(current_channel)+" - press esc to see the whole channel")
+	} else {
+		lines = append(lines, title)
+
+		// wrapping the description to the width of the chat strand
+		if info.Description == "" {
+			lines = append(lines, "This is the beginning of the #"+string(current_channel)+" group chat")
+		} else {
+			lines = append(lines, wrap_text(strings.Join(strings.Fields(info.Description), " "), max(width-4, 1))...)
+		}
+
+		// pointing to the pinned messages
+		if len(info.Pinned) == 1 {
+			lines = append(lines, "1 pinned message - /pins to see it")
+		} else if len(info.Pinned) > 1 {
+			lines = append(lines, strconv.Itoa(len(info.Pinned))+" pinned messages - /pins to see them")
+		}
+	}
+
+	// centering every line of the banner
+	for _, line := range lines {
+		fmt.Fprintln(strand, center_in(line, width))
+	}
+}
+
+/*
+ * This function draws the chat screen. The channel list runs down the left when the terminal is wide enough,
+ * the chat strand fills the rest of the screen above the status bar and what is being typed is at the bottom.
+ * The screen mutex must be held by the caller
+ */
+func print_chat_strand(screen *strings.Builder) {
+	input, err_msg := chat_view.Input, chat_view.Err_msg
+
+	// lines of what is being typed, lines after the first are lined up under the first one
+	var input_rows []string
+	if chat_view.Paste_mode {
+		input_rows = append(input_rows, FAINT+PASTE_MODE_PROMPT+RESET)
+	}
+	for _, line := range strings.Split(GREEN+"-> "+RESET+strings.ReplaceAll(string(input), "\n", "\n   "), "\n") {
+		input_rows = append(input_rows, wrap_row(line, terminal_width)...)
+	}
+	if len(input_rows) > terminal_height-2 {
+		input_rows = input_rows[len(input_rows)-(terminal_height-2):]
+	}
+
+	// splitting the rest of the screen between the channel list and the chat strand
+	sidebar_width := 0
+	if terminal_width >= SIDEBAR_MIN_WIDTH {
+		sidebar_width = SIDEBAR_WIDTH
+	}
+	strand_width := terminal_width - sidebar_width
+	strand_height := terminal_height - len(input_rows) - 1
+
+	// building the chat strand and cutting it into rows that fit beside the channel list
+	var strand strings.Builder
+	unread_line, message_lines := write_chat_strand(&strand, strand_width)
+	var rows []string
+	unread_row := -1
+	first_rows := make(map[int]int)
+	for i, line := range strings.Split(strings.TrimSuffix(strand.String(), "\n"), "\n") {
+		if i == unread_line {
+			unread_row = len(rows)
+		}
+		if id, starts := message_lines[i]; starts {
+			first_rows[id] = len(rows)
+		}
+		rows = append(rows, wrap_row(line, strand_width)...)
+	}
+
+	// keeping the first unread message near the top of the screen, or the message scrolled back to where
+	// it was as messages are added above and below it, or else the newest message at the bottom
+	is_pinned := pinned_to_unread && unread_row != -1
+	bottom := max(len(rows)-strand_height, 0)
+	top := bottom
+	if is_pinned {
+		top = min(max(unread_row-1, 0), bottom)
+	} else if row, found := first_rows[scroll_id]; found && scroll_id != 0 {
+		top = min(max(row+scroll_row, 0), bottom)
+	}
+
+	// nothing is left unseen once the newest message is on the screen
+	if top == bottom {
+		unseen_messages = 0
+	}
+	strand_top, strand_bottom, strand_page, message_rows = top, bottom, strand_height, first_rows
+	rows = rows[top:min(top+strand_height, len(rows))]
+
+	// lining the chat strand up with the bottom of its region
+	for len(rows) < strand_height {
+		rows = append([]string{""}, rows...)
+	}
+
+	// writing the channel list and the chat strand side by side
+	sidebar := get_channel_list_rows(sidebar_width, strand_height)
+	for i, row := range rows {
+		if sidebar_width > 0 {
+			fmt.Fprint(screen, sidebar[i])
+		}
+		fmt.Fprintln(screen, row+RESET)
+	}
+
+	// showing errors, who is typing or how to get back to the newest message in the status bar
+	notice := get_typing_notice()
+	if err_msg != nil {
+		notice = YELLOW + string(err_msg) + RESET + INVERSE
+	} else if older_history_id != 0 {
+		notice = "Loading older messages..."
+	} else if unseen_messages == 1 {
+		notice = BOLD + "1 new message below" + RESET + INVERSE + " - press end to jump to it"
+	} else if unseen_messages > 1 {
+		notice = BOLD + strconv.Itoa(unseen_messages) + " new messages below" + RESET + INVERSE + " - press end to jump to them"
+	} else if top < bottom {
+		notice = "Press end to jump to the newest message"
+	}
+	fmt.Fprintln(screen, get_status_bar(notice))
+
+	fmt.Fprint(screen, strings.Join(input_rows, "\n"))
+}
+
+/*
+ * This function scrolls the chat strand for a key the user pressed, the arrow keys move a few rows,
+ * page up and page down move a screen and end goes back to the newest message
+ */
+func scroll_with_key(key keyboard.Key) {
+	switch key {
+	case keyboard.KeyArrowUp:
+		scroll_chat_strand(-SCROLL_STEP, false)
+	case keyboard.KeyArrowDown:
+		scroll_chat_strand(SCROLL_STEP, false)
+	case keyboard.KeyPgup:
+		scroll_chat_strand(-1, true)
+	case keyboard.KeyPgdn:
+		scroll_chat_strand(1, true)
+	case keyboard.KeyEnd:
+		jump_to_newest()
+	}
+}
+
+/*
+ * This function scrolls the chat strand by an amount of rows, or of screens when by_page is set, back when the
+ * amount is negative. The screen is kept on the message at its top, and scrolling back to the oldest message
+ * the client has asks the server for older ones
+ */
+func scroll_chat_strand(amount int, by_page bool) {
+	screen_mutex.Lock()
+
+	// leaving a row of the last screen on the next one when going a screen at a time
+	if by_page {
+		amount *= max(strand_page-1, 1)
+	}
+	top := min(max(strand_top+amount, 0), strand_bottom)
+	pinned_to_unread = false
+
+	if top == strand_bottom {
+		// following new messages again
+		scroll_id, scroll_row, unseen_messages = 0, 0, 0
+	} else {
+		// finding the message at the top of the screen, or the first message when the banner is at the top
+		scroll_id = 0
+		first := 0
+		for id, row := range message_rows {
+			if row <= top && (scroll_id == 0 || row > message_rows[scroll_id]) {
+				scroll_id = id
+			}
+			if first == 0 || row < message_rows[first] {
+				first = id
+			}
+		}
+		if scroll_id == 0 {
+			scroll_id = first
+		}
+		scroll_row = top - message_rows[scroll_id]
+	}
+
+	// only the whole channel has older messages to fetch
+	fetch := amount < 0 && top == 0 && chat_view.Thread_id == 0 && !chat_view.Showing_pins && older_history_id == 0 && !reached_beginning
+	screen_mutex.Unlock()
+
+	if fetch {
+		request_older_history()
+	}
+}
+
+/*
+ * This function goes back to the newest message of the chat strand and follows new messages again
+ */
+func jump_to_newest() {
+	screen_mutex.Lock()
+	defer screen_mutex.Unlock()
+
+	scroll_id, scroll_row, unseen_messages = 0, 0, 0
+	pinned_to_unread = false
+}
+
+/*
+ * This function counts a message that arrived below the screen while the chat strand is scrolled back
+ */
+func count_unseen_message() {
+	screen_mutex.Lock()
+	defer screen_mutex.Unlock()
+
+	if strand_top < strand_bottom {
+		unseen_messages++
+	}
+}
+
+/*
+ * This function asks the server for a page of the messages sent before the oldest one in the chat strand,
+ * or for the newest messages when none in the chat strand have ids
+ */
+func request_older_history() {
+	oldest := first_message_id()
+	if oldest == 0 {
+		oldest = math.MaxInt
+	}
+
+	screen_mutex.Lock()
+	older_history_id = oldest
+	jump := jump_id
+	screen_mutex.Unlock()
+	request := strconv.Itoa(oldest)
+
+	// asking for every message down to the one a search result opened the channel at
+	if jump != 0 {
+		request += " " + strconv.Itoa(jump)
+	}
+	send_data_packet(Data_packet{Type: OLDER_HISTORY, Username: username, Data: []byte(request)})
+}
+
+/*
+ * This function scrolls the chat strand to the message a search result opened the channel at.
+ * Older messages are asked for first if it is not in the chat strand yet, and it is given up on
+ * once the chat strand goes back past it without it
+ */
+func scroll_to_jump() {
+	screen_mutex.Lock()
+	jump, reached, fetching := jump_id, reached_beginning, older_history_id != 0
+	screen_mutex.Unlock()
+	if jump == 0 {
+		return
+	}
+
+	mutex_chat.Lock()
+	found := slices.ContainsFunc(chat_strand, func(packet Data_packet) bool { return packet.Message_id == jump })
+	mutex_chat.Unlock()
+
+	if !found {
+		if first := first_message_id(); reached || (first != 0 && first < jump) {
+			set_jump(0)
+		} else if !fetching {
+			request_older_history()
+		}
+		return
+	}
+
+	screen_mutex.Lock()
+	scroll_id, scroll_row, unseen_messages = jump, 0, 0
+	pinned_to_unread = false
+	jump_id = 0
+	screen_mutex.Unlock()
+}
+
+/*
+ * This function sets the message the chat strand is scrolled to once it is in the chat strand, 0 for none
+ */
+func set_jump(message_id int) {
+	screen_mutex.Lock()
+	defer screen_mutex.Unlock()
+
+	jump_id = message_id
+}
+
+/*
+ * This function checks if a page of older messages is the one the client is waiting for
+ */
+func is_awaited_page(before_id int) bool {
+	screen_mutex.Lock()
+	defer screen_mutex.Unlock()
+
+	return older_history_id != 0 && before_id == older_history_id
+}
+
+/*
+ * This function writes the banner and messages of the channel, or of the open thread or the pinned messages,
+ * to fit the given width. It returns the line the first unread message starts on, or -1 if there is none,
+ * and the id of the message starting on each line that one does
+ */
+func write_chat_strand(strand *strings.Builder, width int) (int, map[int]int) {
+	unread_line := -1
+	message_lines := make(map[int]int)
+	fmt.Fprintln(strand, strings.Repeat("-", width))
+	mutex_channel_info.Lock()
+	info := channel_info
+	mutex_channel_info.Unlock()
+	write_channel_banner(strand, info, width)
+	fmt.Fprint(strand, "\n\n")
+
+	// keeping inbound messages from changing the chat strand while it is written
+	mutex_chat.Lock()
+	defer mutex_chat.Unlock()
+
+	// showing the pinned messages in place of the chat strand
+	messages := chat_strand
+	if chat_view.Showing_pins {
+		messages = info.Pinned
+	}
+
+	// finding the messages in the open thread
+	var thread map[int]bool
+	if chat_view.Thread_id != 0 {
+		thread = get_thread(chat_view.Thread_id)
+	}
+
+	// looping over the chat strand to print all messages
+	for _, packet := range messages {
+		// checking if the chat strand is empty
+		if chat_strand != nil {
+			// only showing messages in the open thread
+			if thread != nil && !thread[packet.Message_id] && !(packet.Message_id == 0 && packet.Type == MESSAGE && thread[packet.Parent_id]) {
+				continue
+			}
+
+			// remembering where the message starts so scrolling can keep it in place
+			if packet.Message_id != 0 {
+				message_lines[strings.Count(strand.String(), "\n")] = packet.Message_id
+			}
+
+			// marking where the messages the user has not read start
+			if packet.Message_id != 0 && packet.Message_id == first_unread_id && thread == nil && !chat_view.Showing_pins {
+				divider := RED + "----- New messages -----" + RESET
+				fmt.Fprintf(strand, "\n%s\n", center_in(divider, width))
+				unread_line = strings.Count(strand.String(), "\n") - 1
+			}
+
+			if packet.Type == JOIN_MSG || packet.Type == LEAVE_MSG {
+				status_message := YELLOW + string(packet.Data) + RESET
+				fmt.Fprintln(strand, status_message)
+				continue
+			}
+
+			// printing notice that the user was mentioned in another channel
+			if packet.Type == MENTIONED {
+				notice := BOLD + YELLOW + packet.Username + " mentioned you in " + string(packet.Data) + RESET
+				fmt.Fprintln(strand, notice)
+				continue
+			}
+
+			// printing how a file transfer went
+			if packet.Type == TRANSFER {
+				notice := CYAN + string(packet.Data) + RESET
+				fmt.Fprintln(strand, notice)
+				continue
+			}
+
+			// printing notice that the previous message was not delivered
+			if packet.Type == RATE_LIMITED {
+				notice := RED + "Message not delivered: " + string(packet.Data) + RESET
+				fmt.Fprintln(strand, strings.Repeat(" ", max(width-text_width(notice), 0))+notice)
+				continue
+			}
+
+			// showing a marker in place of a deleted message
+			if packet.Deleted {
+				packet.Data = []byte("[deleted]")
+			}
+
+			// quoting the message this one replies to
+			bubble_width := get_bubble_width(width)
+			indent := strings.Repeat(" ", max(width-bubble_width-1, 0))
+			if packet.Parent_id != 0 {
+				quote := FAINT + get_quote(packet.Parent_id) + RESET
+				fmt.Fprint(strand, "\n")
+				if packet.Username == username {
+					fmt.Fprint(strand, indent+quote)
+				} else {
+					fmt.Fprint(strand, quote)
+				}
+			}
+
+			// splitting the message into the lines of its bubble, long messages are cut short
+			body, hidden := get_message_lines(string(packet.Data), bubble_width-2, MAX_PREVIEW_LINES)
+			code_width := get_code_width(body, width)
+
+			// checking if its a message the client sent
+			if packet.Username == username {
+				// printing header for message, lined up with the left of the bubble
+				fmt.Fprint(strand, "\n")
+				fmt.Fprintln(strand, indent+GREEN+"You"+RESET+": ")
+
+				// printing top of message bubble
+				fmt.Fprintln(strand, indent+" "+strings.Repeat("_", bubble_width))
+				fmt.Fprintln(strand, indent+"|"+strings.Repeat(" ", bubble_width))
+
+				// printing the lines of the message, code blocks stick out of the bubble to the left
+				for _, line := range body {
+					if line.Code {
+						code := format_code_line(line.Text, code_width)
+						fmt.Fprintln(strand, strings.Repeat(" ", max(width-code_width-3, 0))+code)
+						continue
+					}
+					output := "| " + line.Text + strings.Repeat(" ", max(bubble_width-2-text_width(line.Text), 0))
+					fmt.Fprintln(strand, indent+highlight_mentions(output, packet.Mentions))
+				}
+
+				// printing bottom of bubble
+				fmt.Fprintln(strand, indent+"|"+strings.Repeat("_", bubble_width))
+
+				// pointing to the rest of a message that was cut short
+				if hidden > 0 {
+					fmt.Fprintln(strand, indent+FAINT+get_more_lines_notice(hidden, packet.Message_id)+RESET)
+				}
+
+				// printing id of message so it can be edited or deleted
+				if packet.Message_id != 0 {
+					fmt.Fprintln(strand, indent+FAINT+message_details(packet)+RESET)
+				}
+			} else {
+				// creating header for message, making it stand out if the message mentions the user
+				username_header := GREEN + "\n" + packet.Username + RESET + ": "
+				if slices.Contains(packet.Mentions, username) {
+					username_header = BOLD + YELLOW + "\n" + packet.Username + RESET + ": " + YELLOW + "mentioned you" + RESET
+				}
+
+				// printing header
+				fmt.Fprintln(strand, username_header)
+
+				// printing top half of bubble
+				fmt.Fprintln(strand, strings.Repeat("_", bubble_width)+" ")
+				fmt.Fprintln(strand, strings.Repeat(" ", bubble_width)+"|")
+
+				// printing the lines of the message, code blocks stick out of the bubble to the right
+				for _, line := range body {
+					if line.Code {
+						fmt.Fprintln(strand, "  "+format_code_line(line.Text, code_width))
+						continue
+					}
+					output := line.Text + strings.Repeat(" ", max(bubble_width-text_width(line.Text), 0)) + "|"
+					fmt.Fprintln(strand, highlight_mentions(output, packet.Mentions))
+				}
+				fmt.Fprintln(strand, strings.Repeat("_", bubble_width)+"|")
+
+				// pointing to the rest of a message that was cut short
+				if hidden > 0 {
+					fmt.Fprintln(strand, FAINT+get_more_lines_notice(hidden, packet.Message_id)+RESET)
+				}
+
+				// printing id of message so it can be edited or deleted
+				if packet.Message_id != 0 {
+					fmt.Fprintln(strand, FAINT+message_details(packet)+RESET)
+				}
+			}
+		}
+	}
+
+	return unread_line, message_lines
+}
+
+/*
+ * This function gets the rows of the channel list shown beside the chat strand, the channel the user is in
+ * stands out and the others show how much has not been read in them
+ */
+func get_channel_list_rows(width int, height int) []string {
+	rows := []string{BOLD + " CHANNELS" + RESET, ""}
+	for i := range channels {
+		// leaving out the option to quit
+		if i == len(channels)-1 {
+			break
+		}
+
+		// setting the inbox options apart from the channels
+		if i == len(channels)-3 {
+			rows = append(rows, "")
+		}
+
+		label := " " + menu_label(channels, i)
+		if i < len(channels)-3 && channels[i] == string(current_channel) {
+			label = GREEN + BOLD + ">" + menu_label(channels, i) + RESET
+		}
+		rows = append(rows, label)
+	}
+
+	// padding every row to the width of the list and drawing its border
+	for len(rows) < height {
+		rows = append(rows, "")
+	}
+	for i, row := range rows {
+		row = wrap_row(row, width-2)[0] + RESET
+		rows[i] = row + strings.Repeat(" ", max(width-2-text_width(row), 0)) + " |"
+	}
+	return rows[:height]
+}
+
+/*
+ * This function gets the status bar shown between the chat strand and the input line, with the channel
+ * on the left, the notice in the middle and the user on the right
+ */
+func get_status_bar(notice string) string {
+	left := " #" + string(current_channel)
+	if chat_view.Showing_pins {
+		left += " (pinned)"
+	} else if chat_view.Thread_id != 0 {
+		left += " (thread #" + strconv.Itoa(chat_view.Thread_id) + ")"
+	}
+	if notice != "" {
+		left += " | " + notice
+	}
+	right := username + " "
+
+	// cutting the notice short when the terminal is too narrow for all of it
+	left = wrap_row(left, max(terminal_width-text_width(right)-1, 1))[0]
+	gap := strings.Repeat(" ", max(terminal_width-text_width(left)-text_width(right), 1))
+	return INVERSE + left + INVERSE + gap + right + RESET
+}
+
+/*
+ * This function tells the channel the user is typing, at most once every TYPING_INTERVAL,
+ * and that the user stopped once the input is empty or a command.
+ * It returns when the channel was last told the user is typing
+ */
+func update_typing(input []byte, typing_sent time.Time) time.Time {
+	// commands are not shown as typing
+	if len(input) == 0 || input[0] == '/' {
+		if !typing_sent.IsZero() {
+			send_data_packet(Data_packet{Type: NOT_TYPING, Username: username})
+		}
+		return time.Time{}
+	}
+
+	if time.Since(typing_sent) >= TYPING_INTERVAL {
+		send_data_packet(Data_packet{Type: TYPING, Username: username})
+		return time.Now()
+	}
+	return typing_sent
+}
+
+/*
+ * This function records that a user started or stopped typing.
+ * Users who are not heard from again within TYPING_TIMEOUT stop being shown as typing
+ */
+func set_typing(name string, is_typing bool) {
+	mutex_typing.Lock()
+	defer mutex_typing.Unlock()
+
+	if !is_typing {
+		delete(typing_users, name)
+		return
+	}
+	typing_users[name] = time.Now()
+
+	// taking the user out of the notice if nothing else is heard from them
+	time.AfterFunc(TYPING_TIMEOUT, func() {
+		mutex_typing.Lock()
+		expired := !typing_users[name].IsZero() && time.Since(typing_users[name]) >= TYPING_TIMEOUT
+		if expired {
+			delete(typing_users, name)
+		}
+		mutex_typing.Unlock()
+
+		if expired && client_status == MESSAGING {
+			redraw()
+		}
+	})
+}
+
+/*
+ * This function builds the notice of who is typing in the channel
+ */
+func get_typing_notice() string {
+	mutex_typing.Lock()
+	names := slices.Sorted(maps.Keys(typing_users))
+	mutex_typing.Unlock()
+
+	// the user's other devices do not count
+	names = slices.DeleteFunc(names, func(name string) bool { return name == username })
+
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return names[0] + " is typing..."
+	case 2:
+		return names[0] + " and " + names[1] + " are typing..."
+	case 3:
+		return names[0] + ", " + names[1] + " and " + names[2] + " are typing..."
+	default:
+		return "Several people are typing..."
+	}
+}
+
+/*
+ * This function builds a short quote of a message to show above a reply to it.
+ * The chat mutex must be held by the caller
+ */
+func get_quote(message_id int) string {
+	for _, packet := range chat_strand {
+		if packet.Message_id != message_id || packet.Type != MESSAGE {
+			continue
+		}
+
+		// shortening long messages to one line
+		text := strings.Join(strings.Fields(strings.ReplaceAll(string(packet.Data), CODE_FENCE, " ")), " ")
+		if packet.Deleted {
+			text = "[deleted]"
+		} else if text_width(text) > QUOTE_LENGTH {
+			text = cut_to_width(text, QUOTE_LENGTH-3) + "..."
+		}
+		author := packet.Username
+		if author == username {
+			author = "You"
+		}
+		return "> " + author + ": " + text
+	}
+
+	// the message is older than the chat strand
+	return "> #" + strconv.Itoa(message_id)
+}
+
+/*
+ * This function finds the messages in the thread of a message: the messages it replies to,
+ * the message itself and every reply under it. The chat mutex must be held by the caller
+ */
+func get_thread(message_id int) map[int]bool {
+	thread := map[int]bool{message_id: true}
+
+	// indexing parents of the messages in the chat strand
+	parents := make(map[int]int)
+	for _, packet := range chat_strand {
+		if packet.Message_id != 0 {
+			parents[packet.Message_id] = packet.Parent_id
+		}
+	}
+
+	// adding the messages above it
+	for parent := parents[message_id]; parent != 0 && !thread[parent]; parent = parents[parent] {
+		thread[parent] = true
+	}
+
+	// adding the replies under it, parents always come before their replies in the chat strand
+	replies := map[int]bool{message_id: true}
+	for _, packet := range chat_strand {
+		if packet.Message_id != 0 && replies[packet.Parent_id] {
+			replies[packet.Message_id] = true
+			thread[packet.Message_id] = true
+		}
+	}
+
+	return thread
+}
+
+/*
+ * This function gets the id of a message, whether it was edited or deleted and its reactions, shown under its bubble
+ */
+func message_details(packet Data_packet) string {
+	details := "#" + strconv.Itoa(packet.Message_id)
+	if packet.Deleted {
+		details += " [deleted]"
+	} else if packet.Edited {
+		details += " (edited)"
+	}
+
+	// adding how many users gave each reaction
+	reactions := slices.Sorted(maps.Keys(packet.Reactions))
+	for _, reaction := range reactions {
+		details += "  " + reaction + " " + strconv.Itoa(len(packet.Reactions[reaction]))
+	}
+	return details
+}
+
+/*
+ * This function handles exiting the server if a custom error occurs
+ */
+func custom_error_exit(err int) {
+	close_screen()
+	switch err {
+	case OUT_OF_SYNC:
+		fmt.Println("system: ERROR - Server and client out of sync")
+	case UNEXPECTED_DATA:
+		fmt.Println("system: ERROR - Unexpected data found in packet")
+	case UNKNOWN:
+		fmt.Println("system: ERROR - An unknown error occured")
+	}
+
+	var cpack Command_packet
+	cpack.Type = EXIT
+	cpack.Username = ""
+	cpack.Arguments = []byte("Error, disconnecting")
+	client_status = QUITTING
+	exit_command(cpack)
+}
+
+/*
+ * This function handles main menu functionality
+ */
+func main_menu() {
+	// fetching the channel list unless it was already fetched while reconnecting
+	for !menu_prefetched {
+		// informting server that the client is ready
+		data_packet := Data_packet{Type: MAIN, Username: username, Data: []byte("READY")}
+		send_data_packet(data_packet)
+
+		// getting list of channels from the server
+		data_packet = read_data_packet()
+
+		// asking again if the connection was lost and resumed
+		if data_packet.Type == RESUME {
+			if client_status != IN_MAIN_MENU {
+				return
+			}
+			continue
+		}
+
+		// varifying packet
+		if data_packet.Type != MAIN {
+			custom_error_exit(OUT_OF_SYNC)
+		}
+
+		// parsing the choice into an array of strings
+		channels = build_menu(string(data_packet.Data))
+		set_unread_counts(data_packet)
+		break
+	}
+	menu_prefetched = false
+
+	// going straight into the channel of a search result opened from another channel
+	if opening_channel != "" {
+		name := opening_channel
+		opening_channel = ""
+		if slices.Contains(channels[:len(channels)-3], name) {
+			enter_channel(name)
+			return
+		}
+		set_jump(0)
+	}
+	menu_ready = true
+
+	// creating channel to send client choice
+	choice_channel := make(chan int)
+
+	// creating go routine to handle displaying the menu
+	go display_main_menu(choice_channel, channels)
+
+	// creating a keyboard reader
+	if err := keyboard.Open(); err != nil {
+		panic(err)
+	}
+	defer keyboard.Close()
+
+	// holds the users current choice
+	current_choice := 0
+
+	// looping until a user makes a selection
+	for {
+		var input []byte
+		var error []byte
+		// getting key press
+		char, key, err := keyboard.GetSingleKey()
+		if err != nil {
+			panic(err)
+		}
+
+		// checking if the session was lost while reconnecting
+		if client_status != IN_MAIN_MENU {
+			choice_channel <- -1
+			menu_ready = false
+			return
+		}
+
+		// Check if the pressed key is the up or down arrow
+		if key == keyboard.KeyArrowUp {
+			current_choice += len(channels) - 1
+			current_choice = current_choice % len(channels)
+			choice_channel <- current_choice
+		} else if key == keyboard.KeyArrowDown {
+			current_choice++
+			current_choice = current_choice % len(channels)
+			choice_channel <- current_choice
+		} else if char == '/' {
+			choice_channel <- -1
+			input = utf8.AppendRune(input, char)
+
+			draw(func(screen *strings.Builder) { display_main_menu_with_command(screen, channels, input, error) })
+
+			for {
+				// getting key press
+				char, key, err := keyboard.GetSingleKey()
+				if err != nil {
+					panic(err)
+				}
+
+				error = nil
+
+				// checking if enter key was pressed
+				if key == keyboard.KeyEnter {
+					if is_comand(string(input)) {
+						error = handle_command(string(input))
+						input = nil
+
+						// checking if the command signed the user out
+						if client_status != IN_MAIN_MENU {
+							menu_ready = false
+							return
+						}
+					} else {
+						error = []byte("Not a valid command")
+					}
+				} else if key == keyboard.KeyBackspace || key == keyboard.KeyBackspace2 {
+					if len(input) > 0 {
+						input = remove_last_rune(input)
+					} else {
+						// creating go routine to handle displaying the menu
+						go display_main_menu(choice_channel, channels)
+						break
+					}
+				} else if key == keyboard.KeyCtrlC {
+					var cpack Command_packet
+					cpack.Type = EXIT
+					cpack.Username = ""
+					cpack.Arguments = []byte("client disconnecting")
+					client_status = QUITTING
+					exit_command(cpack)
+				} else if key == keyboard.KeySpace {
+					input = append(input, ' ')
+				} else if key == keyboard.KeyTab || key == keyboard.KeyArrowLeft || key == keyboard.KeyArrowRight {
+					continue
+				} else if key == keyboard.KeyEsc {
+					// creating go routine to handle displaying the menu
+					go display_main_menu(choice_channel, channels)
+					break
+				} else if key == keyboard.KeyArrowUp || key == keyboard.KeyArrowDown {
+					// creating go routine to handle displaying the menu
+					go display_main_menu(choice_channel, channels)
+					break
+				} else {
+					input = utf8.AppendRune(input, char)
+				}
+
+				draw(func(screen *strings.Builder) { display_main_menu_with_command(screen, channels, input, error) })
+			}
+		}
+
+		// Break the loop if Enter key is pressed
+		if key == keyboard.KeyEnter {
+			// send signal to display_sign_in_menu
+			choice_channel <- -1
+
+			// opening the mentions inbox without leaving the main menu
+			if current_choice == len(channels)-3 {
+				mentions_view()
+				if client_status != IN_MAIN_MENU {
+					menu_ready = false
+					return
+				}
+				go display_main_menu(choice_channel, channels)
+				choice_channel <- current_choice
+				continue
+			}
+
+			// opening direct messages without leaving the main menu
+			if current_choice == len(channels)-2 {
+				direct_messages_view()
+				if client_status != IN_MAIN_MENU {
+					menu_ready = false
+					return
+				}
+				go display_main_menu(choice_channel, channels)
+				choice_channel <- current_choice
+				continue
+			}
+
+			menu_ready = false
+			if current_choice != len(channels)-1 {
+				set_jump(0)
+				enter_channel(channels[current_choice])
+			} else {
+				var cpack Command_packet
+				cpack.Type = EXIT
+				cpack.Username = ""
+				cpack.Arguments = []byte("client disconnecting")
+				client_status = QUITTING
+				exit_command(cpack)
+			}
+			break
+		}
+	}
+}
+
+/*
+ * This function joins a channel from the main menu, where the server is waiting for the user to choose one
+ */
+func enter_channel(name string) {
+	var packet Data_packet
+	packet.Type = MENU_OPTION
+	packet.Data = []byte(name)
+	current_channel = []byte(name)
+	client_status = MESSAGING
+
+	// the channel is read once the user is in it
+	mutex_unread.Lock()
+	delete(channel_unread, name)
+	delete(channel_mentions, name)
+	mutex_unread.Unlock()
+	send_data_packet(packet)
+}
+
+/*
+ * This function is called as a go routine to display the sign in options
+ */
+func display_main_menu(choice chan int, channels []string) {
+	currently_selected := 0
+	loop_iteration := 0
+	for {
+		select {
+		// getting users input from channel
+		case c := <-choice:
+			currently_selected = c
+
+			// checking if user has pressed enter
+			if currently_selected == -1 {
+				return
+			}
+
+			// alternating between printing a selected option and no selected options
+			if loop_iteration == 16 {
+				loop_iteration = 0
+			} else if loop_iteration < 8 {
+				draw(func(screen *strings.Builder) { print_main_menu_with_choice(screen, currently_selected, channels) })
+				loop_iteration++
+			} else if loop_iteration < 16 {
+				draw(func(screen *strings.Builder) { print_main_menu_without_choice(screen, channels) })
+				loop_iteration++
+			}
+			time.Sleep(30 * time.Millisecond)
+		default:
+			// checking if user has pressed enter
+			if currently_selected == -1 {
+				return
+			}
+
+			// alternating between printing a selected option and no selected options
+			if loop_iteration == 16 {
+				loop_iteration = 0
+			} else if loop_iteration < 8 {
+				draw(func(screen *strings.Builder) { print_main_menu_with_choice(screen, currently_selected, channels) })
+				loop_iteration++
+			} else if loop_iteration < 16 {
+				draw(func(screen *strings.Builder) { print_main_menu_without_choice(screen, channels) })
+				loop_iteration++
+			}
+			time.Sleep(30 * time.Millisecond)
+		}
+	}
+}
+
+func display_main_menu_with_command(screen *strings.Builder, channels []string, input []byte, err []byte) {
+	print_main_menu_without_choice(screen, channels)
+	if text_width(string(input)) > 20 {
+		line_1 := strings.Repeat("-", text_width(string(input))+4)
+		fmt.Fprintln(screen, center_text(line_1))
+		line_2 := "| " + string(input) + " |"
+		fmt.Fprintln(screen, center_text(line_2))
+		line_3 := strings.Repeat("-", text_width(string(input))+4)
+		fmt.Fprintln(screen, center_text(line_3))
+	} else {
+		line_1 := strings.Repeat("-", 24)
+		fmt.Fprintln(screen, center_text(line_1))
+		var line_2 string
+		if text_width(string(input))%2 == 0 {
+			line_2 = "|" + strings.Repeat(" ", (22-text_width(string(input)))/2) + string(input) + strings.Repeat(" ", (22-text_width(string(input)))/2) + "|"
+		} else {
+			line_2 = "|" + strings.Repeat(" ", (22-text_width(string(input)))/2+1) + string(input) + strings.Repeat(" ", (22-text_width(string(input)))/2) + "|"
+		}
+		fmt.Fprintln(screen, center_text(line_2))
+		line_3 := strings.Repeat("-", 24)
+		fmt.Fprintln(screen, center_text(line_3))
+	}
+
+	line_4 := YELLOW + "         " + string(err) + RESET
+	fmt.Fprintln(screen, center_text(line_4))
+}
+
+/*
+ * This function prints a screen with the login option selected
+ */
+func print_main_menu_with_choice(screen *strings.Builder, choice int, channels []string) {
+
+	// printing prompt
+	fmt.Fprintln(screen, string(horizontal_line))
+	line_1 := "Select a channel from the list below to join"
+	fmt.Fprintln(screen, center_text(line_1))
+	line_2 := "Use the up and down arrows to change selection"
+	fmt.Fprintln(screen, center_text(line_2))
+	fmt.Fprintln(screen, string(horizontal_line))
+	fmt.Fprint(screen, "\n\n\n\n\n\n")
+
+	// printing options
+	for i := 0; i < len(channels); i++ {
+		msg := menu_label(channels, i)
+		if i == choice {
+			msg = "--> " + msg + " <--"
+		}
+		fmt.Fprintln(screen, center_text(msg))
+		fmt.Fprint(screen, "\n")
+	}
+}
+
+/*
+ * This function prints a screen with the login option selected
+ */
+func print_main_menu_without_choice(screen *strings.Builder, channels []string) {
+
+	// printing prompt
+	fmt.Fprintln(screen, string(horizontal_line))
+	line_1 := "Select a channel from the list below to join"
+	fmt.Fprintln(screen, center_text(line_1))
+	line_2 := "Use the up and down arrows to change selection"
+	fmt.Fprintln(screen, center_text(line_2))
+	fmt.Fprintln(screen, string(horizontal_line))
+	fmt.Fprint(screen, "\n\n\n\n\n\n")
+
+	// printing prompt
+	for i := 0; i < len(channels); i++ {
+		msg := menu_label(channels, i)
+		fmt.Fprintln(screen, center_text(msg))
+		fmt.Fprint(screen, "\n")
+	}
+}
+
+// -----------------------------------------------------------------------------------------------------------------------
+// COMMANDS
+// -----------------------------------------------------------------------------------------------------------------------
+
+/*
+ * This function builds the main menu from the list of channels sent by the server
+ */
+func build_menu(channel_list string) []string {
+	menu := strings.Split(channel_list, " ")
+
+	// appending mentions, direct messages and QUIT options to menu
+	return append(menu, MENTIONS_OPTION, DIRECT_MESSAGES_OPTION, "QUIT")
+}
+
+/*
+ * This function gets the text shown for an option of the main menu
+ */
+func menu_label(channels []string, index int) string {
+	if index < len(channels)-3 {
+		// showing how many messages and mentions have not been read
+		mutex_unread.Lock()
+		unread := channel_unread[channels[index]]
+		mentions := channel_mentions[channels[index]]
+		mutex_unread.Unlock()
+		if mentions > 0 {
+			return "#" + channels[index] + " (" + strconv.Itoa(unread) + " unread, " + strconv.Itoa(mentions) + " @)"
+		} else if unread > 0 {
+			return "#" + channels[index] + " (" + strconv.Itoa(unread) + " unread)"
+		}
+		return "#" + channels[index]
+	}
+
+	// showing how many mentions arrived since the inbox was opened
+	if index == len(channels)-3 {
+		mutex_mentions.Lock()
+		unread := unread_mentions
+		mutex_mentions.Unlock()
+		if unread > 0 {
+			return channels[index] + " (" + strconv.Itoa(unread) + " new)"
+		}
+	}
+
+	// showing how many direct messages have not been read
+	if index == len(channels)-2 {
+		unread := count_unread_direct_messages()
+		if unread > 0 {
+			return channels[index] + " (" + strconv.Itoa(unread) + " unread)"
+		}
+	}
+	return channels[index]
+}
+
+/*
+ * This function handles the server telling the client the user was mentioned in a message
+ */
+func receive_mention(packet Command_packet) {
+	var mention Mention
+	if err := json.Unmarshal(packet.Message, &mention); err != nil {
+		return
+	}
+
+	mutex_mentions.Lock()
+	unread_mentions++
+	mutex_mentions.Unlock()
+
+	// the message itself plays the sound when the user is in the channel it was sent in
+	if client_status == MESSAGING && string(current_channel) == mention.Channel {
+		return
+	}
+
+	// counting the mention in the main menu
+	mutex_unread.Lock()
+	channel_unread[mention.Channel]++
+	channel_mentions[mention.Channel]++
+	mutex_unread.Unlock()
+	go play_pitched_sound("receive.mp3", MENTION_SOUND_RATIO)
+
+	// showing a notice in the channel the user is in
+	if client_status == MESSAGING {
+		notice := Data_packet{Type: MENTIONED, Username: mention.From, Data: []byte("#" + mention.Channel + ": " + string(mention.Text))}
+		add_to_chat_strand(notice)
+		redraw()
+	}
+}
+
+/*
+ * This function colors the mentions in a line of a message, the user's own name stands out the most
+ */
+func highlight_mentions(line string, mentions []string) string {
+	if len(mentions) == 0 {
+		return line
+	}
+	return mention_regex.ReplaceAllStringFunc(line, func(match string) string {
+		if match[1:] == username {
+			return BOLD + YELLOW + match + RESET
+		} else if slices.Contains(mentions, match[1:]) {
+			return CYAN + match + RESET
+		}
+		return match
+	})
+}
+
+/*
+ * This function shows the messages the user was mentioned in, newest first
+ */
+func mentions_view() {
+	// getting the inbox from the server so mentions from while the user was offline are included
+	send_command_packet(Command_packet{Type: MENTIONS, Username: username})
+	cpack := read_command_packet()
+	if cpack.Type != MENTIONS {
+		custom_error_exit(OUT_OF_SYNC)
+	}
+	var mentions []Mention
+	if err := json.Unmarshal(cpack.Message, &mentions); err != nil {
+		custom_error_exit(UNEXPECTED_DATA)
+	}
+	slices.Reverse(mentions)
+
+	// the inbox has been read
+	mutex_mentions.Lock()
+	unread_mentions = 0
+	mutex_mentions.Unlock()
+
+	draw(func(screen *strings.Builder) { print_mentions(screen, mentions) })
+
+	for {
+		// getting key press
+		_, key, err := keyboard.GetSingleKey()
+		if err != nil {
+			panic(err)
+		}
+
+		if key == keyboard.KeyEsc || key == keyboard.KeyEnter {
+			return
+		} else if key == keyboard.KeyCtrlC {
+			var cpack Command_packet
+			cpack.Type = EXIT
+			cpack.Username = ""
+			cpack.Arguments = []byte("client disconnecting")
+			client_status = QUITTING
+			exit_command(cpack)
+		}
+	}
+}
+
+/*
+ * This function handles the search command by showing the results in a view that pages through them.
+ * The search is taken from the raw input since its filters have colons in them
+ */
+func search_command(input string) []byte {
+	// checking if user is signed in
+	if client_status == CHOOSING_SIGN_IN_OPT || client_status == REGISTERING || client_status == LOGGING_IN {
+		return []byte("Command not availbale. Must sign in first.")
+	}
+
+	// splitting input into command and search
+	tokens := strings.SplitN(input, " ", 2)
+	if len(tokens) < 2 || strings.TrimSpace(tokens[1]) == "" {
+		return []byte("Usage: /search [in:<channel>] [from:<user>] [since:<yyyy-mm-dd>] [until:<yyyy-mm-dd>] <words>")
+	}
+	query := strings.TrimSpace(tokens[1])
+
+	// getting the first page of results
+	search_page, err_msg := fetch_search_page(query, 0)
+	if err_msg != nil {
+		return err_msg
+	}
+
+	selected := 0
+	for {
+		draw(func(screen *strings.Builder) { print_search_results(screen, query, search_page, selected, err_msg) })
+		err_msg = nil
+
+		// getting key press
+		_, key, err := keyboard.GetSingleKey()
+		if err != nil {
+			panic(err)
+		}
+
+		last_page := (search_page.Total - 1) / max(search_page.Page_size, 1)
+		if key == keyboard.KeyEsc {
+			return nil
+		} else if key == keyboard.KeyCtrlC {
+			var cpack Command_packet
+			cpack.Type = EXIT
+			cpack.Username = ""
+			cpack.Arguments = []byte("client disconnecting")
+			client_status = QUITTING
+			exit_command(cpack)
+		} else if key == keyboard.KeyArrowUp && len(search_page.Results) > 0 {
+			selected = (selected + len(search_page.Results) - 1) % len(search_page.Results)
+		} else if key == keyboard.KeyArrowDown && len(search_page.Results) > 0 {
+			selected = (selected + 1) % len(search_page.Results)
+		} else if (key == keyboard.KeyArrowLeft && search_page.Page > 0) || (key == keyboard.KeyArrowRight && search_page.Page < last_page) {
+			// changing page
+			page := search_page.Page + 1
+			if key == keyboard.KeyArrowLeft {
+				page = search_page.Page - 1
+			}
+			if next_page, problem := fetch_search_page(query, page); problem != nil {
+				err_msg = problem
+			} else {
+				search_page = next_page
+				selected = 0
+			}
+		} else if key == keyboard.KeyEnter && len(search_page.Results) > 0 {
+			var opened bool
+			err_msg, opened = search_context_view(search_page.Results[selected])
+			if opened {
+				return err_msg
+			}
+		}
+	}
+}
+
+/*
+ * This function gets a page of the results of a search from the server.
+ * It returns an error message if the server did not accept the search
+ */
+func fetch_search_page(query string, page int) (Search_page, []byte) {
+	// sending search to server
+	cpack := Command_packet{Type: SEARCH, Username: username, Arguments: []byte(strconv.Itoa(page)), Message: []byte(query)}
+	send_command_packet(cpack)
+
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != SEARCH {
+		custom_error_exit(OUT_OF_SYNC)
+	}
+	if !cpack.Successful {
+		return Search_page{}, cpack.Arguments
+	}
+
+	var search_page Search_page
+	if err := json.Unmarshal(cpack.Message, &search_page); err != nil {
+		custom_error_exit(UNEXPECTED_DATA)
+	}
+	return search_page, nil
+}
+
+/*
+ * This function shows a search result with the messages sent around it until the user goes back or opens its channel.
+ * It returns an error message if the messages could not be fetched or the channel could not be opened,
+ * and whether the user went to the channel
+ */
+func search_context_view(result Search_result) ([]byte, bool) {
+	// getting the messages from the server
+	message_id := result.Message_id
+	cpack := Command_packet{Type: SEARCH_CONTEXT, Username: username, Arguments: []byte(strconv.Itoa(message_id))}
+	send_command_packet(cpack)
+	cpack = read_command_packet()
+	if cpack.Type != SEARCH_CONTEXT {
+		custom_error_exit(OUT_OF_SYNC)
+	}
+	if !cpack.Successful {
+		return cpack.Arguments, false
+	}
+	var context Search_page
+	if err := json.Unmarshal(cpack.Message, &context); err != nil {
+		custom_error_exit(UNEXPECTED_DATA)
+	}
+
+	draw(func(screen *strings.Builder) { print_search_context(screen, context.Results, message_id) })
+
+	for {
+		// getting key press
+		_, key, err := keyboard.GetSingleKey()
+		if err != nil {
+			panic(err)
+		}
+
+		if key == keyboard.KeyEsc {
+			return nil, false
+		} else if key == keyboard.KeyEnter {
+			return open_search_result(result), true
+		} else if key == keyboard.KeyCtrlC {
+			var cpack Command_packet
+			cpack.Type = EXIT
+			cpack.Username = ""
+			cpack.Arguments = []byte("client disconnecting")
+			client_status = QUITTING
+			exit_command(cpack)
+		}
+	}
+}
+
+/*
+ * This function opens the channel of a search result scrolled to the result.
+ * It returns an error message if the channel could not be opened
+ */
+func open_search_result(result Search_result) []byte {
+	set_jump(result.Message_id)
+
+	// scrolling to the result when the user is already in its channel
+	if client_status == MESSAGING && result.Channel == string(current_channel) {
+		update_chat_view(func(view *Chat_view) { view.Thread_id, view.Showing_pins = 0, false })
+		scroll_to_jump()
+		return nil
+	}
+
+	// leaving the channel the user is in, the main menu then goes straight into the result's channel
+	if client_status == MESSAGING {
+		opening_channel = result.Channel
+		if msg := main_command(Command_packet{Type: MAIN, Username: username}); string(msg) != "Success" {
+			opening_channel = ""
+			set_jump(0)
+			return msg
+		}
+		return nil
+	}
+
+	// the server is waiting for a channel to be chosen while the main menu is showing
+	if !slices.Contains(channels[:len(channels)-3], result.Channel) {
+		set_jump(0)
+		return []byte("#" + result.Channel + " is not in your channel list")
+	}
+	enter_channel(result.Channel)
+	return nil
+}
+
+/*
+ * This function prints a page of search results
+ */
+func print_search_results(screen *strings.Builder, query string, search_page Search_page, selected int, err_msg []byte) {
+
+	// printing header
+	pages := max((search_page.Total+search_page.Page_size-1)/max(search_page.Page_size, 1), 1)
+	fmt.Fprintln(screen, string(horizontal_line))
+	line_1 := "Search results for \"" + query + "\""
+	fmt.Fprintln(screen, center_text(line_1))
+	line_2 := strconv.Itoa(search_page.Total) + " messages, page " + strconv.Itoa(search_page.Page+1) + " of " + strconv.Itoa(pages)
+	fmt.Fprintln(screen, center_text(line_2))
+	line_3 := "Arrows to select and change page, enter to see a message in context, esc to go back"
+	fmt.Fprintln(screen, center_text(line_3))
+	fmt.Fprintln(screen, string(horizontal_line))
+	fmt.Fprint(screen, "\n")
+
+	// printing results
+	if len(search_page.Results) == 0 {
+		msg := FAINT + "No messages found" + RESET
+		fmt.Fprintln(screen, center_text(msg))
+	}
+	for index, result := range search_page.Results {
+		marker := "    "
+		if index == selected {
+			marker = GREEN + "--> " + RESET
+		}
+		fmt.Fprintln(screen, marker+format_search_result(result))
+	}
+
+	if err_msg != nil {
+		fmt.Fprint(screen, "\n")
+		msg := YELLOW + string(err_msg) + RESET
+		fmt.Fprintln(screen, center_text(msg))
+	}
+
+	fmt.Fprint(screen, "\n")
+	fmt.Fprintln(screen, string(horizontal_line))
+}
+
+/*
+ * This function prints a search result and the messages sent around it
+ */
+func print_search_context(screen *strings.Builder, context []Search_result, message_id int) {
+
+	// printing header
+	fmt.Fprintln(screen, string(horizontal_line))
+	line_1 := "Message #" + strconv.Itoa(message_id) + " in context"
+	fmt.Fprintln(screen, center_text(line_1))
+	line_2 := "Press enter to open the channel at this message, esc to go back to the results"
+	fmt.Fprintln(screen, center_text(line_2))
+	fmt.Fprintln(screen, string(horizontal_line))
+	fmt.Fprint(screen, "\n")
+
+	// printing messages, the result stands out
+	for _, result := range context {
+		if result.Message_id == message_id {
+			fmt.Fprintln(screen, BOLD+YELLOW+"--> "+RESET+format_search_result(result))
+		} else {
+			fmt.Fprintln(screen, "    "+format_search_result(result))
+		}
+	}
+
+	fmt.Fprint(screen, "\n")
+	fmt.Fprintln(screen, string(horizontal_line))
+}
+
+/*
+ * This function formats a search result as one line
+ */
+func format_search_result(result Search_result) string {
+	author := result.Username
+	if author == username {
+		author = "You"
+	}
+	sent := FAINT + result.Sent.Local().Format("Jan 2 3:04 PM") + RESET
+	text := strings.Join(strings.Fields(string(result.Text)), " ")
+	if result.Edited {
+		text += FAINT + " (edited)" + RESET
+	}
+	return CYAN + "#" + result.Channel + RESET + " " + GREEN + author + RESET + " " + sent + ": " + text
+}
+
+/*
+ * This function prints the mentions inbox
+ */
+func print_mentions(screen *strings.Builder, mentions []Mention) {
+
+	// printing header
+	fmt.Fprintln(screen, string(horizontal_line))
+	line_1 := "Mentions"
+	fmt.Fprintln(screen, center_text(line_1))
+	line_2 := "Messages you were mentioned in, press esc to go back"
+	fmt.Fprintln(screen, center_text(line_2))
+	fmt.Fprintln(screen, string(horizontal_line))
+	fmt.Fprint(screen, "\n")
+
+	// printing mentions
+	if len(mentions) == 0 {
+		msg := FAINT + "No one has mentioned you yet" + RESET
+		fmt.Fprintln(screen, center_text(msg))
+	}
+	for _, mention := range mentions {
+		sent := FAINT + mention.Sent.Local().Format("Jan 2 3:04 PM") + RESET
+		fmt.Fprintln(screen, CYAN+mention.From+RESET+" in #"+mention.Channel+" "+sent+": "+highlight_mentions(string(mention.Text), []string{username}))
+	}
+
+	fmt.Fprint(screen, "\n")
+	fmt.Fprintln(screen, string(horizontal_line))
+}
+
+/*
+ * This function records how many unread messages and mentions the server counted in each channel of the main menu
+ */
+func set_unread_counts(packet Data_packet) {
+	mutex_unread.Lock()
+	defer mutex_unread.Unlock()
+
+	clear(channel_unread)
+	clear(channel_mentions)
+	for index, name := range channels[:len(channels)-3] {
+		if index < len(packet.Unread) {
+			channel_unread[name] = packet.Unread[index]
+		}
+		if index < len(packet.Unread_mentions) {
+			channel_mentions[name] = packet.Unread_mentions[index]
+		}
+	}
+}
+
+/*
+ * This function finds the first message after the last one the user read
+ * and keeps the chat strand scrolled to it until the user goes to the newest message
+ */
+func set_read_marker(last_read int) {
+	mutex_chat.Lock()
+	first_unread_id = 0
+	for _, packet := range chat_strand {
+		if packet.Message_id > last_read && packet.Type == MESSAGE && packet.Username != username {
+			first_unread_id = packet.Message_id
+			break
+		}
+	}
+	mutex_chat.Unlock()
+
+	pinned_to_unread = first_unread_id != 0
+	loading_history = false
+	if history_timer != nil {
+		history_timer.Stop()
+	}
+}
+
+/*
+ * This function shows the channel without its history when the history is taking too long to arrive
+ */
+func history_timed_out() {
+	if !loading_history || client_status != MESSAGING {
+		return
+	}
+	loading_history = false
+	flash_status_notice("The history of the channel did not arrive")
+}
+
+/*
+ * This function handles a direct message delivered by the server
+ */
+func receive_direct_message(packet Command_packet) {
+	var direct_message Direct_message
+	if err := json.Unmarshal(packet.Message, &direct_message); err != nil {
+		return
+	}
+
+	// filing message under the other user
+	partner := direct_message.From
+	if direct_message.From == username {
+		partner = direct_message.To
+	}
+
+	mutex_dm.Lock()
+	conversations[partner] = append(conversations[partner], direct_message)
+	is_open := open_conversation == partner
+	if !is_open && direct_message.From != username {
+		unread_direct_messages[partner]++
+	}
+	mutex_dm.Unlock()
+
+	// messages sent from the user's other devices arrive silently
+	if direct_message.From != username {
+		go play_sound("receive.mp3")
+	}
+
+	// redrawing conversation if it is open
+	if is_open {
+		redraw()
+	}
+}
+
+/*
+ * This function counts the direct messages that have not been read
+ */
+func count_unread_direct_messages() int {
+	mutex_dm.Lock()
+	defer mutex_dm.Unlock()
+
+	unread := 0
+	for _, count := range unread_direct_messages {
+		unread += count
+	}
+	return unread
+}
+
+/*
+ * This function sends a direct message and records it in the conversation if the server took it
+ */
+func send_direct_message(partner string, text []byte) []byte {
+	// sending message to server
+	cpack := Command_packet{Type: MSG, Username: username, Arguments: []byte(partner), Message: text}
+	send_command_packet(cpack)
+
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != MSG {
+		custom_error_exit(OUT_OF_SYNC)
+	}
+
+	// adding message to conversation
+	if cpack.Successful {
+		mutex_dm.Lock()
+		conversations[partner] = append(conversations[partner], Direct_message{From: username, To: partner, Text: text, Sent: time.Now()})
+		mutex_dm.Unlock()
+	}
+
+	return cpack.Arguments
+}
+
+/*
+ * This function handles the direct messages screen where the user picks a conversation
+ */
+func direct_messages_view() {
+	var input []byte
+	var err_msg []byte
+	selected := 0
+
+	for {
+		// listing conversations
+		mutex_dm.Lock()
+		var partners []string
+		for partner := range conversations {
+			partners = append(partners, partner)
+		}
+		mutex_dm.Unlock()
+		slices.Sort(partners)
+
+		draw(func(screen *strings.Builder) { print_conversation_list(screen, partners, selected, input, err_msg) })
+
+		// getting key press
+		char, key, err := keyboard.GetSingleKey()
+		if err != nil {
+			panic(err)
+		}
+		err_msg = nil
+
+		if key == keyboard.KeyEsc {
+			return
+		} else if key == keyboard.KeyCtrlC {
+			var cpack Command_packet
+			cpack.Type = EXIT
+			cpack.Username = ""
+			cpack.Arguments = []byte("client disconnecting")
+			client_status = QUITTING
+			exit_command(cpack)
+		} else if key == keyboard.KeyArrowUp && len(partners) > 0 {
+			selected = (selected + len(partners) - 1) % len(partners)
+		} else if key == keyboard.KeyArrowDown && len(partners) > 0 {
+			selected = (selected + 1) % len(partners)
+		} else if key == keyboard.KeyBackspace || key == keyboard.KeyBackspace2 {
+			if len(input) > 0 {
+				input = remove_last_rune(input)
+			}
+		} else if key == keyboard.KeyEnter {
+			// checking if the user entered a command
+			if is_comand(string(input)) {
+				err_msg = handle_command(string(input))
+				input = nil
+				if client_status != IN_MAIN_MENU {
+					return
+				}
+				continue
+			}
+
+			// opening the typed name or the selected conversation
+			if len(input) > 0 {
+				conversation_view(string(input))
+			} else if len(partners) > 0 {
+				conversation_view(partners[selected])
+			}
+			input = nil
+			if client_status != IN_MAIN_MENU {
+				return
+			}
+		} else if key == keyboard.KeySpace {
+			input = append(input, ' ')
+		} else if char != 0 {
+			input = utf8.AppendRune(input, char)
+		}
+	}
+}
+
+/*
+ * This function handles a conversation with one user
+ */
+func conversation_view(partner string) {
+	var input []byte
+	var err_msg []byte
+
+	// marking conversation as open so new messages redraw it and are not counted as unread
+	mutex_dm.Lock()
+	open_conversation = partner
+	unread_direct_messages[partner] = 0
+	mutex_dm.Unlock()
+
+	for {
+		draw(func(screen *strings.Builder) { print_conversation(screen, partner, input, err_msg) })
+
+		// getting key press
+		char, key, err := keyboard.GetSingleKey()
+		if err != nil {
+			panic(err)
+		}
+		err_msg = nil
+
+		if key == keyboard.KeyEsc {
+			break
+		} else if key == keyboard.KeyCtrlC {
+			var cpack Command_packet
+			cpack.Type = EXIT
+			cpack.Username = ""
+			cpack.Arguments = []byte("client disconnecting")
+			client_status = QUITTING
+			exit_command(cpack)
+		} else if key == keyboard.KeyBackspace || key == keyboard.KeyBackspace2 {
+			if len(input) > 0 {
+				input = remove_last_rune(input)
+			}
+		} else if key == keyboard.KeyEnter {
+			if len(input) == 0 {
+				continue
+			}
+
+			// checking if the user entered a command
+			if is_comand(string(input)) {
+				err_msg = handle_command(string(input))
+			} else {
+				err_msg = send_direct_message(partner, input)
+			}
+			input = nil
+			if client_status != IN_MAIN_MENU {
+				break
+			}
+		} else if key == keyboard.KeySpace {
+			input = append(input, ' ')
+		} else if key == keyboard.KeyTab || key == keyboard.KeyArrowLeft || key == keyboard.KeyArrowRight || key == keyboard.KeyArrowDown || key == keyboard.KeyArrowUp {
+			continue
+		} else {
+			input = utf8.AppendRune(input, char)
+		}
+	}
+
+	// closing conversation
+	mutex_dm.Lock()
+	open_conversation = ""
+	mutex_dm.Unlock()
+}
+
+/*
+ * This function prints the list of conversations
+ */
+func print_conversation_list(screen *strings.Builder, partners []string, selected int, input []byte, err_msg []byte) {
+
+	// printing prompt
+	fmt.Fprintln(screen, string(horizontal_line))
+	line_1 := "Direct messages"
+	fmt.Fprintln(screen, center_text(line_1))
+	line_2 := "Select a conversation or type a username and press enter, esc to go back"
+	fmt.Fprintln(screen, center_text(line_2))
+	fmt.Fprintln(screen, string(horizontal_line))
+	fmt.Fprint(screen, "\n\n")
+
+	// printing conversations
+	if len(partners) == 0 {
+		msg := FAINT + "No conversations yet" + RESET
+		fmt.Fprintln(screen, center_text(msg))
+	}
+	mutex_dm.Lock()
+	for index, partner := range partners {
+		msg := partner
+		if unread_direct_messages[partner] > 0 {
+			msg += " (" + strconv.Itoa(unread_direct_messages[partner]) + " unread)"
+		}
+		if index == selected {
+			msg = "--> " + msg + " <--"
+		}
+		fmt.Fprintln(screen, center_text(msg))
+		fmt.Fprint(screen, "\n")
+	}
+	mutex_dm.Unlock()
+
+	if err_msg != nil {
+		fmt.Fprint(screen, "\n")
+		msg := YELLOW + string(err_msg) + RESET
+		fmt.Fprintln(screen, center_text(msg))
+	}
+
+	fmt.Fprint(screen, "\n\n")
+	fmt.Fprintln(screen, string(horizontal_line))
+	arrow := GREEN + "-> " + RESET + string(input)
+	fmt.Fprint(screen, arrow)
+}
+
+/*
+ * This function prints a conversation with one user
+ */
+func print_conversation(screen *strings.Builder, partner string, input []byte, err_msg []byte) {
+
+	// printing header
+	fmt.Fprintln(screen, string(horizontal_line))
+	line_1 := "Direct messages with " + partner
+	fmt.Fprintln(screen, center_text(line_1))
+	fmt.Fprintln(screen, string(horizontal_line))
+	fmt.Fprint(screen, "\n")
+
+	// printing messages
+	mutex_dm.Lock()
+	for _, direct_message := range conversations[partner] {
+		sent := FAINT + direct_message.Sent.Local().Format("Jan 2 3:04 PM") + RESET
+		if direct_message.From == username {
+			fmt.Fprintln(screen, GREEN+"You"+RESET+" "+sent+": "+string(direct_message.Text))
+		} else {
+			fmt.Fprintln(screen, CYAN+direct_message.From+RESET+" "+sent+": "+string(direct_message.Text))
+		}
+	}
+	mutex_dm.Unlock()
+
+	if err_msg != nil {
+		fmt.Fprint(screen, "\n")
+		msg := YELLOW + "         " + string(err_msg) + RESET
+		fmt.Fprintln(screen, center_text(msg))
+	}
+
+	fmt.Fprint(screen, "\n\n")
+	fmt.Fprintln(screen, string(horizontal_line))
+	arrow := GREEN + "-> " + RESET + string(input)
+	fmt.Fprint(screen, arrow)
+}
+
+/*
+ * This function checks if a string is a command
+ */
+func is_comand(input string) bool {
+	tokens := strings.Split(input, " ")
+	return command_to_int[tokens[0]] > 0
+}
+
+/*
+ * This function executes the command entered
+ */
+func handle_command(input string) []byte {
+	// sending the command to the server
+	packet := parse_command(input)
+
+	switch packet.Type {
+	case HELP:
+		return help_command(packet)
+	case EXIT:
+		return exit_command(packet)
+	case CREATE:
+		return create_command(packet)
+	case MAIN:
+		return main_command(packet)
+	case CHANGE_TOPIC:
+		return change_topic_command(input)
+	case ADD_MOD:
+		return add_mod_command(packet)
+	case RM_MOD:
+		return rm_mod_command(packet)
+	case BAN_S:
+		return ban_s_command(packet)
+	case LOCKOUTS:
+		return lockouts_command(packet)
+	case UNLOCK:
+		return unlock_command(packet)
+	case MSG:
+		return msg_command(input)
+	case INVITE:
+		return invite_command(packet)
+	case JOIN:
+		return join_command(packet)
+	case KICK:
+		return kick_command(packet)
+	case ADD_CHANNEL_MOD, RM_CHANNEL_MOD:
+		return channel_mod_command(packet)
+	case ROLES, CREATE_ROLE, DELETE_ROLE, GRANT_ROLE, REVOKE_ROLE:
+		return role_command(packet)
+	case EDIT_MSG:
+		return edit_command(input)
+	case REPLY:
+		return reply_command(input)
+	case REACT:
+		return react_command(input)
+	case SEARCH:
+		return search_command(input)
+	case THREAD:
+		return thread_command(packet)
+	case PIN, UNPIN:
+		return pin_command(packet)
+	case SET_DESCRIPTION:
+		return set_description_command(input)
+	case PINS:
+		return pins_command(packet)
+	case UPLOAD:
+		return upload_command(input)
+	case DOWNLOAD:
+		return download_command(packet)
+	case VIEW:
+		return view_command(packet)
+	case PASTE:
+		return paste_command(packet)
+	case DELETE_MSG:
+		return delete_message_command(packet)
+	case LIST_C:
+		return list_c_command(packet)
+	case LIST_S:
+		return list_s_command(packet)
+	case LOG_OUT:
+		return log_out_command(packet)
+	case LOG_OUT_ALL:
+		return log_out_all_command(packet)
+	case DEVICES:
+		return devices_command(packet)
+	case REVOKE:
+		return revoke_command(packet)
+	default:
+		return nil
+	}
+}
 
-			// clearing terminal
-			clear_terminal()
+/*
+ * This function parses commands
+ */
+func parse_command(input string) Command_packet {
+	var packet Command_packet
+	var args strings.Builder
 
-			// print login screen
-			print_login_password(string(password_mask), nil)
-		}
+	// splitting input string into tokens
+	tokens := strings.Split(input, " ")
 
-		// checking if command was entered
-		if is_comand(string(input)) {
-			msg := handle_command(string(input))
-			input = nil
-			clear_terminal()
-			print_login_username(string(input), msg)
-			continue
+	// looping over tokens to build agument string
+	for i := 1; i < len(tokens); i++ {
+		if i != 1 {
+			args.WriteString(":")
 		}
 
-		// initializing packet
-		packet.Type = LOGIN
-		packet.Data = input
-		send_data_packet(packet)
+		args.WriteString(tokens[i])
+	}
 
-		// reading packet from server
-		fmt.Println("reading")
-		packet = read_data_packet()
+	// initializing packet
+	packet.Type = command_to_int[tokens[0]]
+	packet.Username = username
+	packet.Arguments = []byte(args.String())
 
-		// determining if password was accepted
-		if packet.Type == ACCEPT {
-			fmt.Print(string(vertical_space[:terminal_height/2-1]))
-			msg := "         " + GREEN + string(packet.Data) + RESET
-			fmt.Printf("%*s\n", ((terminal_width-len(msg))/2)+len(msg), msg)
-			fmt.Print(string(vertical_space[:terminal_height/2]))
-			client_status = IN_MAIN_MENU
-			break
-		} else {
-			go play_sound("error.mp3")
-			print_login_password(string(password_mask), packet.Data)
-		}
+	return packet
+}
+
+/*
+ * This function handles the client exiting the application
+ */
+func exit_command(cpack Command_packet) []byte {
+	// send command to server
+	send_command_packet(cpack)
+
+	// checking if the server changed states
+	cpack = read_command_packet()
+
+	// closing without telling the server if it can not be reached
+	if cpack.Type != EXIT || string(cpack.Arguments) != "READY" {
+		shutdown()
 	}
 
-	// sleeping to give user time to see message
-	time.Sleep(2 * time.Second)
+	// closing function on server side that is using data_socket
+	dpack := Data_packet{Type: CLOSE, Username: "", Data: []byte("Client disconnecting")}
+	send_data_packet(dpack)
+
+	// creating exit packet
+	cpack.Type = EXIT
+	cpack.Username = username
+	cpack.Arguments = []byte("CLOSE_SENT")
+
+	// sending packet to server
+	send_command_packet(cpack)
+
+	// shutting down client
+	shutdown()
+	return nil
 }
 
 /*
- * prints login screen with username prompt
+ * This function handles the msg command.
+ * The text is taken from the raw input so it is sent exactly as typed
  */
-func print_login_username(input string, error []byte) {
-	var bar []byte
-	var padding []byte
-
-	for i := 0; i < terminal_width; i++ {
-		bar = append(horizontal_line, '-')
+func msg_command(input string) []byte {
+	// checking if user is signed in
+	if client_status == CHOOSING_SIGN_IN_OPT || client_status == REGISTERING || client_status == LOGGING_IN {
+		return []byte("Command not availbale. Must sign in first.")
 	}
 
-	for i := 0; i < terminal_width; i++ {
-		padding = append(padding, ' ')
+	// splitting input into command, recipient and text
+	tokens := strings.SplitN(input, " ", 3)
+	if len(tokens) < 3 || tokens[1] == "" || tokens[2] == "" {
+		return []byte("Usage: /msg <user> <text>")
 	}
 
-	if error == nil {
-		// printing space above text box
-		fmt.Print(string(vertical_space[:terminal_height/2-2]))
+	return send_direct_message(tokens[1], []byte(tokens[2]))
+}
 
-		// printing prompt
-		line_1 := "Enter your username below:"
-		fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
+/*
+ * This function handles the invite command
+ */
+func invite_command(cpack Command_packet) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	}
 
-		// printing text box
-		if len(input) > 20 {
-			line_1 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			line_2 := "| " + input + " |"
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		} else {
-			line_1 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			var line_2 string
-			if len(input)%2 == 0 {
-				line_2 = "|" + string(padding[:(22-len(input))/2]) + input + string(padding[:(22-len(input))/2]) + "|"
-			} else {
-				line_2 = "|" + string(padding[:(22-len(input))/2+1]) + input + string(padding[:(22-len(input))/2]) + "|"
-			}
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		}
-		if terminal_height%2 == 0 {
-			fmt.Print(string(vertical_space[:terminal_height/2-2]))
-		} else {
-			fmt.Print(string(vertical_space[:terminal_height/2-1]))
-		}
-	} else {
-		fmt.Print(string(vertical_space[:terminal_height/2-2]))
-		line_1 := "Enter your username below:"
-		fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-		if len(input) > 20 {
-			line_1 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			line_2 := "| " + input + " |"
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		} else {
-			line_1 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			var line_2 string
-			if len(input)%2 == 0 {
-				line_2 = "|" + string(padding[:(22-len(input))/2]) + input + string(padding[:(22-len(input))/2]) + "|"
-			} else {
-				line_2 = "|" + string(padding[:(22-len(input))/2+1]) + input + string(padding[:(22-len(input))/2]) + "|"
-			}
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		}
-		line_4 := "         " + RED + string(error) + RESET
-		fmt.Printf("%*s\n", ((terminal_width-len(line_4))/2)+len(line_4), line_4)
+	// send command to server
+	send_command_packet(cpack)
 
-		if terminal_height%2 == 0 {
-			fmt.Print(string(vertical_space[:terminal_height/2-3]))
-		} else {
-			fmt.Print(string(vertical_space[:terminal_height/2-2]))
-		}
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != INVITE {
+		custom_error_exit(OUT_OF_SYNC)
 	}
+
+	return cpack.Arguments
 }
 
 /*
- * prints login screen with password prompt
+ * This function handles the join command
  */
-func print_login_password(input string, error []byte) {
-	var bar []byte
-	var padding []byte
+func join_command(cpack Command_packet) []byte {
+	// checking if user is signed in
+	if client_status == CHOOSING_SIGN_IN_OPT || client_status == REGISTERING || client_status == LOGGING_IN {
+		return []byte("Command not availbale. Must sign in first.")
+	}
 
-	for i := 0; i < terminal_width; i++ {
-		bar = append(horizontal_line, '-')
+	// send command to server
+	send_command_packet(cpack)
+
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != JOIN {
+		custom_error_exit(OUT_OF_SYNC)
 	}
 
-	for i := 0; i < terminal_width; i++ {
-		padding = append(padding, ' ')
+	// adding the channel to the main menu
+	if cpack.Successful {
+		channels = build_menu(string(cpack.Arguments))
 	}
 
-	if error == nil {
-		fmt.Print(string(vertical_space[:terminal_height/2-2]))
-		line_1 := "Enter your password below:"
-		fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-		if len(input) > 20 {
-			line_1 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			line_2 := "| " + input + " |"
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		} else {
-			line_1 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			var line_2 string
-			if len(input)%2 == 0 {
-				line_2 = "|" + string(padding[:(22-len(input))/2]) + input + string(padding[:(22-len(input))/2]) + "|"
-			} else {
-				line_2 = "|" + string(padding[:(22-len(input))/2+1]) + input + string(padding[:(22-len(input))/2]) + "|"
-			}
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		}
-		if terminal_height%2 == 0 {
-			fmt.Print(string(vertical_space[:terminal_height/2-2]))
-		} else {
-			fmt.Print(string(vertical_space[:terminal_height/2-1]))
-		}
-	} else {
-		// printing top space
-		fmt.Print(string(vertical_space[:terminal_height/2-2]))
+	return cpack.Message
+}
 
-		// printing prompt
-		line_1 := "Enter your password below:"
-		fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
+/*
+ * This function handles the kick command
+ */
+func kick_command(cpack Command_packet) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	}
 
-		// printing text box
-		if len(input) > 20 {
-			line_1 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			line_2 := "| " + input + " |"
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:len(input)+4])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		} else {
-			line_1 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-			var line_2 string
-			if len(input)%2 == 0 {
-				line_2 = "|" + string(padding[:(22-len(input))/2]) + input + string(padding[:(22-len(input))/2]) + "|"
-			} else {
-				line_2 = "|" + string(padding[:(22-len(input))/2+1]) + input + string(padding[:(22-len(input))/2]) + "|"
-			}
-			fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-			line_3 := string(bar[:24])
-			fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-		}
-		line_4 := "         " + RED + string(error) + RESET
-		fmt.Printf("%*s\n", ((terminal_width-len(line_4))/2)+len(line_4), line_4)
+	// send command to server
+	send_command_packet(cpack)
 
-		// printing bottom space
-		if terminal_height%2 == 0 {
-			fmt.Print(string(vertical_space[:terminal_height/2-3]))
-		} else {
-			fmt.Print(string(vertical_space[:terminal_height/2-2]))
-		}
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != KICK {
+		custom_error_exit(OUT_OF_SYNC)
 	}
+
+	return cpack.Arguments
 }
 
 /*
- * This function is responcible for handling messaging
+ * This function handles the add-channel-mod and rm-channel-mod commands
  */
-func message() {
-	// var input string
-	var input []byte
-	var err_msg []byte
-	var packet Data_packet
+func channel_mod_command(cpack Command_packet) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	}
 
-	// starting a go routine to handle inbound messages
-	go handle_inbound_msg(&input)
+	// send command to server
+	command_type := cpack.Type
+	send_command_packet(cpack)
 
-	// scanning user inputs and sending messages
-	if err := keyboard.Open(); err != nil {
-		panic(err)
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != command_type {
+		custom_error_exit(OUT_OF_SYNC)
 	}
 
-	for {
+	return cpack.Arguments
+}
 
-		input = nil
-		// clearing the terminal
-		clear_terminal()
+/*
+ * This function handles the roles, create-role, delete-role, grant-role and revoke-role commands
+ */
+func role_command(cpack Command_packet) []byte {
+	// checking if user is signed in
+	if client_status == CHOOSING_SIGN_IN_OPT || client_status == REGISTERING || client_status == LOGGING_IN {
+		return []byte("Command not availbale. Must sign in first.")
+	}
 
-		// printing login screen
-		print_chat_strand(input, err_msg)
+	// send command to server
+	command_type := cpack.Type
+	send_command_packet(cpack)
 
-		// getting user input
-		for {
-			// getting key press
-			char, key, err := keyboard.GetSingleKey()
-			if err != nil {
-				panic(err)
-			}
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != command_type {
+		custom_error_exit(OUT_OF_SYNC)
+	}
 
-			// checking if enter key was pressed
-			if key == keyboard.KeyEnter {
-				err_msg = nil
-				break
-			} else if key == keyboard.KeyBackspace || key == keyboard.KeyBackspace2 {
-				if len(input) > 0 {
-					input = input[:len(input)-1]
-				}
-			} else if key == keyboard.KeyCtrlC {
-				var cpack Command_packet
-				cpack.Type = EXIT
-				cpack.Username = ""
-				cpack.Arguments = []byte("client disconnecting")
-				client_status = QUITTING
-				exit_command(cpack)
-			} else if key == keyboard.KeyTab || key == keyboard.KeyArrowLeft || key == keyboard.KeyArrowRight || key == keyboard.KeyArrowDown || key == keyboard.KeyArrowUp {
-				continue
-			} else if key == keyboard.KeyEsc {
+	return cpack.Arguments
+}
+
+/*
+ * This function handles the edit command.
+ * The text is taken from the raw input so it is sent exactly as typed
+ */
+func edit_command(input string) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	}
+
+	// splitting input into command, message id and text
+	tokens := strings.SplitN(input, " ", 3)
+	if len(tokens) < 3 || tokens[1] == "" || tokens[2] == "" {
+		return []byte("Usage: /edit <id> <text>")
+	}
+
+	// send command to server
+	cpack := Command_packet{Type: EDIT_MSG, Username: username, Arguments: []byte(tokens[1]), Message: []byte(tokens[2])}
+	send_command_packet(cpack)
+
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != EDIT_MSG {
+		custom_error_exit(OUT_OF_SYNC)
+	}
+
+	return cpack.Arguments
+}
+
+/*
+ * This function handles the reply command which sends a message in reply to another message
+ */
+func reply_command(input string) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	}
+
+	// splitting input into command, message id and text
+	tokens := strings.SplitN(input, " ", 3)
+	if len(tokens) < 3 || tokens[2] == "" {
+		return []byte("Usage: /reply <id> <text>")
+	}
+	parent_id, err := strconv.Atoi(strings.TrimPrefix(tokens[1], "#"))
+	if err != nil || parent_id <= 0 {
+		return []byte("Usage: /reply <id> <text>")
+	}
+
+	// sending the reply like any other message
+	packet := Data_packet{Type: MESSAGE, Username: username, Data: []byte(tokens[2]), Parent_id: parent_id}
+	add_to_chat_strand(packet)
+	send_data_packet(packet)
 
-				var cpack Command_packet
-				cpack.Type = MAIN
-				cpack.Username = username
-				main_command(cpack)
-				return
-			} else if key == keyboard.KeySpace {
-				input = append(input, ' ')
-			} else {
-				input = append(input, byte(char))
-			}
+	return nil
+}
 
-			// clearing the terminal
-			clear_terminal()
+/*
+ * This function handles the thread command which shows only the conversation around one message
+ */
+func thread_command(cpack Command_packet) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	}
 
-			// printing login screen
-			print_chat_strand(input, err_msg)
-		}
+	// getting the id of the message
+	message_id, err := strconv.Atoi(strings.TrimPrefix(string(cpack.Arguments), "#"))
+	if err != nil || message_id <= 0 {
+		return []byte("Usage: /thread <id>")
+	}
+
+	// checking the message is in the chat strand
+	mutex_chat.Lock()
+	found := slices.ContainsFunc(chat_strand, func(packet Data_packet) bool { return packet.Message_id == message_id && packet.Type == MESSAGE })
+	mutex_chat.Unlock()
+	if !found {
+		return []byte("No message #" + strconv.Itoa(message_id) + " in this channel")
+	}
+
+	update_chat_view(func(view *Chat_view) { view.Thread_id, view.Showing_pins = message_id, false })
+	return nil
+}
 
-		if input == nil {
+/*
+ * This function splits a message into the lines it is shown as. Text is wrapped to a width
+ * and code blocks between CODE_FENCE lines are kept as they are. It returns at most limit lines,
+ * or every line if limit is 0, and how many lines were left out
+ */
+func get_message_lines(text string, width int, limit int) ([]Message_line, int) {
+	var lines []Message_line
+	in_code := false
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		// a code block on a single line
+		if !in_code && len(trimmed) > 2*len(CODE_FENCE) && strings.HasPrefix(trimmed, CODE_FENCE) && strings.HasSuffix(trimmed, CODE_FENCE) {
+			lines = append(lines, Message_line{Text: expand_tabs(trimmed[len(CODE_FENCE) : len(trimmed)-len(CODE_FENCE)]), Code: true})
 			continue
 		}
 
-		// checks if a command was entered and executes it if it was
-		if is_comand(string(input)) {
-			err_msg = handle_command(string(input))
-			if client_status != MESSAGING {
-				fmt.Println("client state changed")
-				return
-			}
-			clear_terminal()
-			print_chat_strand(input, err_msg)
+		// fences start and end code blocks and are not shown themselves
+		if strings.HasPrefix(trimmed, CODE_FENCE) {
+			in_code = !in_code
 			continue
 		}
 
-		// declaring and initializing packet
-		packet.Type = MESSAGE
-		packet.Data = []byte(input)
-		packet.Username = username
+		if in_code {
+			lines = append(lines, Message_line{Text: expand_tabs(line), Code: true})
+			continue
+		}
+		for _, wrapped := range wrap_text(line, width) {
+			lines = append(lines, Message_line{Text: wrapped})
+		}
+	}
 
-		// adding new message to chat strand
-		chat_strand = append(chat_strand, packet)
+	if limit > 0 && len(lines) > limit {
+		return lines[:limit], len(lines) - limit
+	}
+	return lines, 0
+}
 
-		// sending message to server
-		send_data_packet(packet)
+/*
+ * This function wraps text at spaces so no line is wider than width, cutting words that are wider than a line
+ */
+func wrap_text(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Split(text, " ") {
+		// cutting words that do not fit on a line of their own
+		for text_width(word) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			part := cut_to_width(word, width)
+			if part == "" {
+				// a wide character on a line one column wide still has to go somewhere
+				_, size := utf8.DecodeRuneInString(word)
+				part = word[:size]
+			}
+			lines = append(lines, part)
+			word = word[len(part):]
+		}
 
-		// reprinting chat strand
-		if client_status == MESSAGING {
-			print_chat_strand(input, err_msg)
+		if line != "" && text_width(line)+1+text_width(word) > width {
+			lines = append(lines, line)
+			line = word
+		} else if line != "" {
+			line += " " + word
+		} else {
+			line = word
 		}
 	}
+
+	// not leaving an empty line behind a word that was cut into lines that are exactly full
+	if line == "" && len(lines) > 0 {
+		return lines
+	}
+	return append(lines, line)
 }
 
 /*
- * This function is responcible for handling inbound messages
+ * This function gets how many columns text takes up on the terminal
  */
-func handle_inbound_msg(input *[]byte) {
-
-	// reading inbound messages
-	for {
+func text_width(text string) int {
+	width := 0
+	for i := 0; i < len(text); i++ {
+		// skipping colour codes which take up no room
+		if text[i] == '\x1b' {
+			for i < len(text) && text[i] != 'm' {
+				i++
+			}
+			continue
+		}
 
-		// reading packet
-		packet := read_data_packet()
+		r, size := utf8.DecodeRuneInString(text[i:])
+		width += rune_width(r)
+		i += size - 1
+	}
+	return width
+}
 
-		if packet.Type == CLOSE {
-			return
+/*
+ * This function gets how many columns a character takes up on the terminal. Combining marks and joiners
+ * sit on the character before them, East Asian wide characters and emoji take up two columns
+ */
+func rune_width(r rune) int {
+	if r < 0x20 || r == 0x7f || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, wide := range wide_runes {
+		if r < wide[0] {
+			break
+		}
+		if r <= wide[1] {
+			return 2
 		}
+	}
+	return 1
+}
 
-		if packet.Type == REFRESH {
-			current_channel = packet.Data
-			// reprinting updated chat strand
-			print_chat_strand(*input, nil)
+/*
+ * This function cuts text down to the characters that fit in width columns
+ */
+func cut_to_width(text string, width int) string {
+	used := 0
+	for i, r := range text {
+		used += rune_width(r)
+		if used > width {
+			return text[:i]
 		}
+	}
+	return text
+}
 
-		// checking packet type
-		if packet.Type == MESSAGE || packet.Type == JOIN_MSG || packet.Type == LEAVE_MSG {
-			// appending new message to chat strand
-			mutex_chat.Lock()
-			chat_strand = append(chat_strand, packet)
-			mutex_chat.Unlock()
+/*
+ * This function pads text with spaces so it is centered on the terminal
+ */
+func center_text(text string) string {
+	return center_in(text, terminal_width)
+}
 
-			if client_status == MESSAGING {
+/*
+ * This function pads text with spaces so it is centered in the given width
+ */
+func center_in(text string, width int) string {
+	return strings.Repeat(" ", max((width-text_width(text))/2, 0)) + text
+}
 
-				if packet.Type == JOIN_MSG {
-					go play_sound("joining.mp3")
-				} else if packet.Type == LEAVE_MSG {
-					go play_sound("leaving.mp3")
-				} else {
-					go play_sound("receive.mp3")
-				}
+/*
+ * This function removes the last character typed from the input, which can be more than one byte long
+ */
+func remove_last_rune(input []byte) []byte {
+	_, size := utf8.DecodeLastRune(input)
+	return input[:len(input)-size]
+}
 
-				// reprinting updated chat strand
-				print_chat_strand(*input, nil)
-			}
+/*
+ * This function turns the tabs in a line of code into spaces so the line takes up a known width
+ */
+func expand_tabs(line string) string {
+	return strings.ReplaceAll(line, "\t", "    ")
+}
+
+/*
+ * This function gets the columns inside a message bubble, which grow with the chat strand so wide
+ * terminals are not left with a thin column of text, up to a width that is still easy to read
+ */
+func get_bubble_width(strand_width int) int {
+	width := min(strand_width*2/3, BUBBLE_MAX_WIDTH)
+	return max(width, min(BUBBLE_MIN_WIDTH, strand_width-2), 4)
+}
+
+/*
+ * This function gets the width the code blocks of a message are drawn at, which is their longest line
+ * unless that does not fit in the width of the chat strand
+ */
+func get_code_width(lines []Message_line, strand_width int) int {
+	width := 0
+	for _, line := range lines {
+		if line.Code {
+			width = max(width, text_width(line.Text))
 		}
 	}
+	return min(width, max(strand_width-6, 1))
 }
 
 /*
- * This function handles when the user presses ctrl-c
+ * This function formats a line of code with the code background, padded to the width of its block.
+ * Lines wider than the block are cut and end with a marker, /view shows them in full
  */
-func handle_ctrl_c(sigChan chan os.Signal) {
-	// Wait for a SIGINT signal
-	<-sigChan
-
-	// informing client that the signal was recieved
-	fmt.Println("\nExiting CHAT 429")
+func format_code_line(line string, width int) string {
+	if text_width(line) > width {
+		line = cut_to_width(line, width-1) + "»"
+	}
+	return CODE_BACKGROUND + " " + line + strings.Repeat(" ", width-text_width(line)) + " " + RESET
+}
 
-	var cpack Command_packet
-	cpack.Type = EXIT
-	cpack.Username = ""
-	cpack.Arguments = []byte("client disconnecting")
-	client_status = QUITTING
-	exit_command(cpack)
+/*
+ * This function gets the notice shown under a message that was cut short
+ */
+func get_more_lines_notice(hidden int, message_id int) string {
+	notice := strconv.Itoa(hidden) + " more lines"
+	if hidden == 1 {
+		notice = "1 more line"
+	}
+	if message_id != 0 {
+		notice += " - /view " + strconv.Itoa(message_id) + " to see it all"
+	}
+	return notice
 }
 
 /*
- * This function gets passed a type of error and closes the
- * client while informing the server that the client is disconnecting
+ * This function handles the view command which shows a whole message, with code blocks at their full width
  */
-func error_exit(err error) {
-	clear_terminal()
-	fmt.Println("system: ERROR -", err)
-	var cpack Command_packet
-	cpack.Type = EXIT
-	cpack.Username = ""
-	cpack.Arguments = []byte("Error, disconnecting")
-	client_status = QUITTING
-	exit_command(cpack)
+func view_command(cpack Command_packet) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	}
+
+	// getting the id of the message
+	message_id, err := strconv.Atoi(strings.TrimPrefix(string(cpack.Arguments), "#"))
+	if err != nil || message_id <= 0 {
+		return []byte("Usage: /view <id>")
+	}
+
+	// finding the message in the chat strand or the pins of the channel
+	mutex_chat.Lock()
+	index := slices.IndexFunc(chat_strand, func(packet Data_packet) bool { return packet.Message_id == message_id && packet.Type == MESSAGE })
+	var message Data_packet
+	if index != -1 {
+		message = chat_strand[index]
+	}
+	mutex_chat.Unlock()
+	if index == -1 {
+		mutex_channel_info.Lock()
+		index = slices.IndexFunc(channel_info.Pinned, func(packet Data_packet) bool { return packet.Message_id == message_id })
+		if index != -1 {
+			message = channel_info.Pinned[index]
+		}
+		mutex_channel_info.Unlock()
+	}
+	if index == -1 || message.Deleted {
+		return []byte("No message #" + strconv.Itoa(message_id) + " in this channel")
+	}
+
+	lines := get_full_message_lines(message)
+	top := 0
+	for {
+		draw(func(screen *strings.Builder) { print_full_message(screen, message, lines, top) })
+
+		// getting key press
+		_, key, err := keyboard.GetSingleKey()
+		if err != nil {
+			panic(err)
+		}
+
+		// scrolling through messages that do not fit on the screen
+		page := max(terminal_height-6, 1)
+		if key == keyboard.KeyEsc || key == keyboard.KeyEnter {
+			return nil
+		} else if key == keyboard.KeyCtrlC {
+			var cpack Command_packet
+			cpack.Type = EXIT
+			cpack.Username = ""
+			cpack.Arguments = []byte("client disconnecting")
+			client_status = QUITTING
+			exit_command(cpack)
+		} else if key == keyboard.KeyArrowUp {
+			top = max(top-1, 0)
+		} else if key == keyboard.KeyArrowDown {
+			top = max(min(top+1, len(lines)-page), 0)
+		}
+	}
 }
 
 /*
- * This function formats and prints the chat strand
+ * This function gets the lines of a whole message, text is wrapped to the terminal and code is not wrapped at all
  */
-func print_chat_strand(input []byte, err_msg []byte) {
-	var padding []byte
+func get_full_message_lines(message Data_packet) []string {
+	var lines []string
+	body, _ := get_message_lines(string(message.Data), max(terminal_width-2, 1), 0)
+
+	// code blocks are as wide as their longest line, even if that is wider than the terminal
+	code_width := 0
+	for _, line := range body {
+		if line.Code {
+			code_width = max(code_width, text_width(line.Text))
+		}
+	}
 
-	for i := 0; i < terminal_width; i++ {
-		padding = append(padding, ' ')
+	for _, line := range body {
+		if line.Code {
+			lines = append(lines, format_code_line(line.Text, code_width))
+		} else {
+			lines = append(lines, highlight_mentions(line.Text, message.Mentions))
+		}
 	}
+	return lines
+}
 
-	// clearing terminal
-	clear_terminal()
+/*
+ * This function prints a whole message starting at a line
+ */
+func print_full_message(screen *strings.Builder, message Data_packet, lines []string, top int) {
 
-	// forcing text box to bottom of screen
-	fmt.Print(string(vertical_space))
-	fmt.Println(string(horizontal_line))
-	start_of_chat_banner := "This is the beginning of the #" + string(current_channel) + " group chat"
-	fmt.Printf("%*s\n", ((terminal_width-len(start_of_chat_banner))/2)+len(start_of_chat_banner), start_of_chat_banner)
-	fmt.Print("\n\n")
+	// printing header
+	author := message.Username
+	if author == username {
+		author = "You"
+	}
+	fmt.Fprintln(screen, string(horizontal_line))
+	header := author + " - " + message_details(message)
+	fmt.Fprintln(screen, center_text(header))
+	hint := "Press esc to go back to the chat, up and down to scroll"
+	fmt.Fprintln(screen, center_text(hint))
+	fmt.Fprintln(screen, string(horizontal_line))
+
+	// printing the lines that fit on the screen
+	page := max(terminal_height-6, 1)
+	for _, line := range lines[top:min(top+page, len(lines))] {
+		fmt.Fprintln(screen, line)
+	}
+	fmt.Fprintln(screen, string(horizontal_line))
+}
 
-	// looping over the chat strand to print all messages
-	for _, packet := range chat_strand {
-		// checking if the chat strand is empty
-		if chat_strand != nil {
+/*
+ * This function handles the paste command which lets the next message have more than one line.
+ * Enter adds a line until ctrl+d sends the message
+ */
+func paste_command(cpack Command_packet) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	} else if len(cpack.Arguments) > 0 {
+		return []byte("Usage: /paste")
+	}
 
-			if packet.Type == JOIN_MSG || packet.Type == LEAVE_MSG {
-				status_message := YELLOW + string(packet.Data) + RESET
-				fmt.Println(status_message)
-				continue
-			}
+	update_chat_view(func(view *Chat_view) { view.Paste_mode = true })
+	return nil
+}
 
-			// checking if its a message the client sent
-			if packet.Username == username {
-				// creating header for message
-				username := GREEN + "You" + RESET + ": "
+/*
+ * This function handles the pins command which shows only the pinned messages of the channel, or the whole channel again
+ */
+func pins_command(cpack Command_packet) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	} else if len(cpack.Arguments) > 0 {
+		return []byte("Usage: /pins")
+	}
 
-				// creating buffer with padding
-				username_buffer := make([]byte, 27)
-				for i := range username_buffer {
-					username_buffer[i] = ' '
-				}
-				copy(username_buffer, []byte(username))
-				fmt.Print("\n")
-				fmt.Printf("%*s\n", terminal_width, string(username_buffer))
+	// the pins are shown instead of the channel, not inside a thread
+	update_chat_view(func(view *Chat_view) { view.Showing_pins, view.Thread_id = !view.Showing_pins, 0 })
+	return nil
+}
 
-				// printing top of message bubble
-				fmt.Printf("%*s\n", terminal_width, " __________________________")
-				fmt.Printf("%*s\n", terminal_width, "|                          ")
-
-				// formatting messages to fit in bubble
-				var last_space int
-				var temp_string string
-				index := 0
-				closest_space := 0
-				message := []byte(packet.Data)
-				output := make([]byte, 27)
-
-				// looping over characters in message
-				for _, character := range packet.Data {
-					// marking space as the closest space to the current character
-					if character == ' ' {
-						closest_space = index
-					}
+/*
+ * This function handles the pin and unpin commands
+ */
+func pin_command(cpack Command_packet) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	}
 
-					// finding the closest space and print up to that point when reaching the end of the line
-					if index%24 == 0 && index != 0 {
-						// adding spaces to create pading
-						for i := range output {
-							output[i] = ' '
-						}
+	// send command to server
+	expected := cpack.Type
+	send_command_packet(cpack)
 
-						// differentiating between single word and multi-word messages
-						if last_space > 0 {
-							temp_string = "| " + string(message[last_space+1:closest_space])
-						} else {
-							temp_string = "| " + string(message[last_space:closest_space])
-						}
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != expected {
+		custom_error_exit(OUT_OF_SYNC)
+	}
 
-						// copying current line to output buffer
-						copy(output, []byte(temp_string))
+	return cpack.Arguments
+}
 
-						// printing output buffer
-						fmt.Printf("%*s\n", terminal_width, string(output))
+/*
+ * This function handles the set-description command.
+ * The description is taken from the raw input so it is sent exactly as typed
+ */
+func set_description_command(input string) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	}
 
-						// updating last space
-						last_space = closest_space
-					}
-					index++
-				}
+	// everything after the command is the description, nothing clears it
+	description := ""
+	if tokens := strings.SplitN(input, " ", 2); len(tokens) == 2 {
+		description = tokens[1]
+	}
 
-				// creating buffer with padding
-				for i := range output {
-					output[i] = ' '
-				}
+	// send command to server
+	cpack := Command_packet{Type: SET_DESCRIPTION, Username: username, Message: []byte(description)}
+	send_command_packet(cpack)
 
-				// creating last line of message
-				if last_space > 0 {
-					temp_string = "| " + string(message[last_space+1:])
-				} else {
-					temp_string = "| " + string(message[last_space:])
-				}
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != SET_DESCRIPTION {
+		custom_error_exit(OUT_OF_SYNC)
+	}
 
-				// coping last line to output buffer
-				copy(output, []byte(temp_string))
+	return cpack.Arguments
+}
 
-				// printing output buffer
-				fmt.Printf("%*s\n", terminal_width, string(output))
+/*
+ * This function handles the upload command which shares a file in the current channel.
+ * The path is taken from the raw input since it may have spaces in it, running it again resumes an upload that stopped
+ */
+func upload_command(input string) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	}
 
-				// printing bottom of bubble
-				fmt.Printf("%*s\n", terminal_width, "|__________________________")
-			} else {
-				// creating header for message
-				username_header := GREEN + "\n" + packet.Username + RESET + ": "
+	// everything after the command is the path
+	tokens := strings.SplitN(input, " ", 2)
+	if len(tokens) < 2 || strings.TrimSpace(tokens[1]) == "" {
+		return []byte("Usage: /upload <path>")
+	}
+	path := strings.TrimSpace(tokens[1])
+
+	// checking the file before asking the server
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return []byte("No file at " + path)
+	} else if info.Size() > MAX_FILE_SIZE {
+		return []byte("Files can be at most " + format_size(MAX_FILE_SIZE))
+	}
+	checksum, err := get_file_checksum(path)
+	if err != nil {
+		return []byte("Could not read " + path)
+	}
 
-				// printing header
-				fmt.Println(username_header)
+	// listening for the server to connect for the chunks, on any free port so transfers can run side by side
+	listener, err := net.Listen(CONNECTION_TYPE, CLIENT_HOST+":0")
+	if err != nil {
+		return []byte("Could not start the upload")
+	}
 
-				// printing top half of bubble
-				fmt.Println("__________________________ ")
-				fmt.Println("                          |")
-
-				// formatting messages to fit in bubble
-				var last_space int
-				var temp_string string
-				index := 0
-				closest_space := 0
-				message := []byte(packet.Data)
-				output := make([]byte, 27)
-
-				// looping over characters in message
-				for _, character := range packet.Data {
-					// marking space as the closest space to the current character
-					if character == ' ' {
-						closest_space = index
-					}
+	// send command to server
+	request := Transfer_request{Name: filepath.Base(path), Size: info.Size(), Checksum: checksum, Address: listener.Addr().String()}
+	json_data, err := json.Marshal(request)
+	if err != nil {
+		error_exit(err)
+	}
+	cpack := Command_packet{Type: UPLOAD, Username: username, Message: json_data}
+	send_command_packet(cpack)
 
-					// finding the closest space and print up to that point when reaching the end of the line
-					if index%24 == 0 && index != 0 {
-						for i := range output {
-							output[i] = ' '
-						}
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != UPLOAD {
+		custom_error_exit(OUT_OF_SYNC)
+	}
 
-						// differentiating between single word and multi-word messages
-						if last_space > 0 {
-							temp_string = string(message[last_space+1 : closest_space])
-						} else {
-							temp_string = string(message[last_space:closest_space])
-						}
+	var start Transfer_start
+	if !cpack.Successful || json.Unmarshal(cpack.Message, &start) != nil {
+		listener.Close()
+		return cpack.Arguments
+	}
 
-						// coping last line to output buffer
-						copy(output, []byte(temp_string))
-						output[26] = '|'
-						fmt.Println(string(output))
-						last_space = closest_space
-					}
-					index++
-				}
+	go send_upload(listener, path, start)
+	return cpack.Arguments
+}
 
-				// creating buffer with padding
-				for i := range output {
-					output[i] = ' '
-				}
+/*
+ * This function sends the chunks of a file to the server once it connects, starting where the server says the upload stopped
+ */
+func send_upload(listener net.Listener, path string, start Transfer_start) {
+	connection, err := accept_transfer(listener)
+	if err != nil {
+		add_transfer_notice("Upload of "+start.Name+" failed: the server never connected", true)
+		return
+	}
+	defer connection.Close()
+	reader := bufio.NewReaderSize(connection, MAX_PACKET_SIZE)
 
-				// differentiating between single word and multi-word messages
-				if last_space > 0 {
-					temp_string = string(message[last_space+1:])
-				} else {
-					temp_string = string(message[last_space:])
-				}
+	file, err := os.Open(path)
+	if err != nil {
+		add_transfer_notice("Upload of "+start.Name+" failed: could not read "+path, true)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Seek(start.Offset, io.SeekStart); err != nil {
+		add_transfer_notice("Upload of "+start.Name+" failed: could not read "+path, true)
+		return
+	}
 
-				// coping last line to output buffer
-				copy(output, []byte(temp_string))
-				output[26] = '|'
-				fmt.Println(string(output))
-				fmt.Println("__________________________|")
-			}
+	// sending the file a chunk at a time
+	offset := start.Offset
+	buffer := make([]byte, TRANSFER_CHUNK_SIZE)
+	for offset < start.Size {
+		amount_read, err := file.Read(buffer[:min(int64(len(buffer)), start.Size-offset)])
+		if amount_read == 0 || (err != nil && err != io.EOF) {
+			add_transfer_notice("Upload of "+start.Name+" failed: "+path+" changed while it was uploading", true)
+			return
 		}
+		if write_transfer_chunk(connection, Transfer_chunk{Offset: offset, Data: buffer[:amount_read]}) != nil {
+			upload_stopped(connection, reader, path, start, offset)
+			return
+		}
+		offset += int64(amount_read)
 	}
-
-	if err_msg != nil {
-		fmt.Print("\n\n")
-		msg := YELLOW + "         " + string(err_msg) + RESET
-		fmt.Printf("%*s\n", ((terminal_width-len(msg))/2)+len(msg), msg)
-	} else {
-		fmt.Print("\n\n\n")
+	if write_transfer_chunk(connection, Transfer_chunk{Offset: offset, Done: true}) != nil {
+		upload_stopped(connection, reader, path, start, offset)
+		return
 	}
 
-	fmt.Println(string(horizontal_line))
-	arrow := GREEN + "-> " + RESET + string(input)
-	fmt.Print(arrow)
+	// waiting for the server to check the file, the message sharing it shows it worked
+	connection.SetReadDeadline(time.Now().Add(TRANSFER_TIMEOUT))
+	result, err := read_transfer_chunk(reader)
+	if err != nil {
+		add_transfer_notice("Upload of "+start.Name+" stopped at "+format_size(offset)+", run /upload "+path+" again to resume", true)
+	} else if result.Error != "" {
+		add_transfer_notice("Upload of "+start.Name+" failed: "+result.Error, true)
+	}
 }
 
 /*
- * This function handles exiting the server if a custom error occurs
+ * This function lets the user know an upload stopped, with the reason the server gave if it sent one
+ * before closing the connection
  */
-func custom_error_exit(err int) {
-	switch err {
-	case OUT_OF_SYNC:
-		fmt.Println("system: ERROR - Server and client out of sync")
-	case UNEXPECTED_DATA:
-		fmt.Println("system: ERROR - Unexpected data found in packet")
-	case UNKNOWN:
-		fmt.Println("system: ERROR - An unknown error occured")
+func upload_stopped(connection net.Conn, reader *bufio.Reader, path string, start Transfer_start, offset int64) {
+	connection.SetReadDeadline(time.Now().Add(time.Second))
+	if chunk, err := read_transfer_chunk(reader); err == nil && chunk.Error != "" {
+		add_transfer_notice("Upload of "+start.Name+" failed: "+chunk.Error, true)
+		return
 	}
-
-	var cpack Command_packet
-	cpack.Type = EXIT
-	cpack.Username = ""
-	cpack.Arguments = []byte("Error, disconnecting")
-	client_status = QUITTING
-	exit_command(cpack)
+	add_transfer_notice("Upload of "+start.Name+" stopped at "+format_size(offset)+", run /upload "+path+" again to resume", true)
 }
 
 /*
- * This function handles main menu functionality
+ * This function handles the download command which saves a shared file in the downloads folder.
+ * Running it again resumes a download that stopped
  */
-func main_menu() {
-	// clearing terminal
-	clear_terminal()
+func download_command(cpack Command_packet) []byte {
+	// checking if user is signed in
+	if client_status == CHOOSING_SIGN_IN_OPT || client_status == REGISTERING || client_status == LOGGING_IN {
+		return []byte("Command not availbale. Must sign in first.")
+	}
 
-	// informting server that the client is ready
-	data_packet := Data_packet{Type: MAIN, Username: username, Data: []byte("READY")}
-	send_data_packet(data_packet)
+	// getting the id of the file
+	file_id, err := strconv.Atoi(strings.TrimPrefix(string(cpack.Arguments), "#"))
+	if err != nil || file_id <= 0 {
+		return []byte("Usage: /download <id>")
+	}
 
-	// getting list of channels from the server
-	data_packet = read_data_packet()
+	// finding out how much of the file arrived before
+	var offset int64
+	if info, err := os.Stat(get_part_path(file_id)); err == nil {
+		offset = info.Size()
+	}
 
-	// varifying packet
-	if data_packet.Type != MAIN {
-		custom_error_exit(OUT_OF_SYNC)
+	// listening for the server to connect for the chunks
+	listener, err := net.Listen(CONNECTION_TYPE, CLIENT_HOST+":0")
+	if err != nil {
+		return []byte("Could not start the download")
 	}
 
-	// parsing the choice into an array of strings
-	channels = strings.Split(string(data_packet.Data), " ")
+	// send command to server
+	request := Transfer_request{Address: listener.Addr().String(), Offset: offset}
+	json_data, err := json.Marshal(request)
+	if err != nil {
+		error_exit(err)
+	}
+	cpack.Message = json_data
+	send_command_packet(cpack)
 
-	// appending QUIT option to menu
-	channels = append(channels, "QUIT")
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != DOWNLOAD {
+		custom_error_exit(OUT_OF_SYNC)
+	}
 
-	// creating channel to send client choice
-	choice_channel := make(chan int)
+	var start Transfer_start
+	if !cpack.Successful || json.Unmarshal(cpack.Message, &start) != nil {
+		listener.Close()
+		return cpack.Arguments
+	}
 
-	// creating go routine to handle displaying the menu
-	go display_main_menu(choice_channel, channels)
+	go receive_download(listener, start)
+	return cpack.Arguments
+}
 
-	// creating a keyboard reader
-	if err := keyboard.Open(); err != nil {
-		panic(err)
+/*
+ * This function writes the chunks of a file the server sends into the downloads folder and checks it against its checksum
+ */
+func receive_download(listener net.Listener, start Transfer_start) {
+	connection, err := accept_transfer(listener)
+	if err != nil {
+		add_transfer_notice("Download of "+start.Name+" failed: the server never connected", true)
+		return
 	}
-	defer keyboard.Close()
+	defer connection.Close()
+	reader := bufio.NewReaderSize(connection, MAX_PACKET_SIZE)
 
-	// holds the users current choice
-	current_choice := 0
+	// keeping what arrived before, unless the server starts over
+	if err := os.MkdirAll(DOWNLOADS_DIR, 0700); err != nil {
+		download_failed(start, err)
+		return
+	}
+	part_path := get_part_path(start.Id)
+	part, err := os.OpenFile(part_path, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		download_failed(start, err)
+		return
+	}
+	if err := part.Truncate(start.Offset); err != nil {
+		part.Close()
+		download_failed(start, err)
+		return
+	}
+	if _, err := part.Seek(start.Offset, io.SeekStart); err != nil {
+		part.Close()
+		download_failed(start, err)
+		return
+	}
 
-	// looping until a user makes a selection
+	// writing chunks until the server says it is done
+	offset := start.Offset
 	for {
-		var input []byte
-		var error []byte
-		// getting key press
-		char, key, err := keyboard.GetSingleKey()
-		if err != nil {
-			panic(err)
+		connection.SetReadDeadline(time.Now().Add(TRANSFER_TIMEOUT))
+		chunk, err := read_transfer_chunk(reader)
+		if err != nil || chunk.Offset != offset {
+			part.Close()
+			add_transfer_notice("Download of "+start.Name+" stopped at "+format_size(offset)+", run /download "+strconv.Itoa(start.Id)+" again to resume", true)
+			return
+		} else if chunk.Error != "" {
+			part.Close()
+			add_transfer_notice("Download of "+start.Name+" failed: "+chunk.Error, true)
+			return
+		} else if chunk.Done {
+			break
 		}
 
-		// Check if the pressed key is the up or down arrow
-		if key == keyboard.KeyArrowUp {
-			current_choice += len(channels) - 1
-			current_choice = current_choice % len(channels)
-			choice_channel <- current_choice
-		} else if key == keyboard.KeyArrowDown {
-			current_choice++
-			current_choice = current_choice % len(channels)
-			choice_channel <- current_choice
-		} else if char == '/' {
-			choice_channel <- -1
-			input = append(input, byte(char))
+		if _, err := part.Write(chunk.Data); err != nil {
+			part.Close()
+			download_failed(start, err)
+			return
+		}
+		offset += int64(len(chunk.Data))
+	}
+	part.Close()
 
-			clear_terminal()
-			display_main_menu_with_command(channels, input, error)
+	// making sure the whole file arrived unchanged
+	checksum, err := get_file_checksum(part_path)
+	if err != nil {
+		download_failed(start, err)
+		return
+	}
+	if offset != start.Size || checksum != start.Checksum {
+		os.Remove(part_path)
+		add_transfer_notice("Download of "+start.Name+" failed: the file did not match its checksum, download it again", true)
+		return
+	}
 
-			for {
-				// getting key press
-				char, key, err := keyboard.GetSingleKey()
-				if err != nil {
-					panic(err)
-				}
+	// saving the file under its own name without replacing anything
+	path := get_download_path(start.Name)
+	if err := os.Rename(part_path, path); err != nil {
+		download_failed(start, err)
+		return
+	}
+	add_transfer_notice("Downloaded "+start.Name+" ("+format_size(start.Size)+") to "+path, false)
+}
 
-				error = nil
+/*
+ * This function lets the user know a download could not be saved, such as when the disk is full,
+ * without closing the client since it runs in its own go routine
+ */
+func download_failed(start Transfer_start, err error) {
+	add_transfer_notice("Download of "+start.Name+" failed: could not save it to "+DOWNLOADS_DIR+" - "+err.Error(), true)
+}
 
-				// checking if enter key was pressed
-				if key == keyboard.KeyEnter {
-					if is_comand(string(input)) {
-						error = handle_command(string(input))
-						input = nil
-					} else {
-						error = []byte("Not a valid command")
-					}
-				} else if key == keyboard.KeyBackspace || key == keyboard.KeyBackspace2 {
-					if len(input) > 0 {
-						input = input[:len(input)-1]
-					} else {
-						// creating go routine to handle displaying the menu
-						go display_main_menu(choice_channel, channels)
-						break
-					}
-				} else if key == keyboard.KeyCtrlC {
-					var cpack Command_packet
-					cpack.Type = EXIT
-					cpack.Username = ""
-					cpack.Arguments = []byte("client disconnecting")
-					client_status = QUITTING
-					exit_command(cpack)
-				} else if key == keyboard.KeySpace {
-					input = append(input, ' ')
-				} else if key == keyboard.KeyTab || key == keyboard.KeyArrowLeft || key == keyboard.KeyArrowRight {
-					continue
-				} else if key == keyboard.KeyEsc {
-					// creating go routine to handle displaying the menu
-					go display_main_menu(choice_channel, channels)
-					break
-				} else if key == keyboard.KeyArrowUp || key == keyboard.KeyArrowDown {
-					// creating go routine to handle displaying the menu
-					go display_main_menu(choice_channel, channels)
-					break
-				} else {
-					input = append(input, byte(char))
-				}
+/*
+ * This function waits for the server to open a transfer connection to a listener
+ */
+func accept_transfer(listener net.Listener) (net.Conn, error) {
+	defer listener.Close()
+	listener.(*net.TCPListener).SetDeadline(time.Now().Add(TRANSFER_TIMEOUT))
+	return listener.Accept()
+}
 
-				clear_terminal()
-				display_main_menu_with_command(channels, input, error)
-			}
-		}
+/*
+ * This function writes one chunk of a transfer to its connection
+ */
+func write_transfer_chunk(connection net.Conn, chunk Transfer_chunk) error {
+	json_data, err := json.Marshal(chunk)
+	if err != nil {
+		error_exit(err)
+	}
+	connection.SetWriteDeadline(time.Now().Add(TRANSFER_TIMEOUT))
+	_, err = connection.Write(append(json_data, PACKET_DELIMITER))
+	return err
+}
 
-		// Break the loop if Enter key is pressed
-		if key == keyboard.KeyEnter {
-			// send signal to display_sign_in_menu
-			choice_channel <- -1
-			if current_choice != len(channels)-1 {
-				var packet Data_packet
-				packet.Type = MENU_OPTION
-				packet.Data = []byte(strconv.Itoa(current_choice))
-				current_channel = []byte(channels[current_choice])
-				client_status = MESSAGING
-				send_data_packet(packet)
-			} else {
-				var cpack Command_packet
-				cpack.Type = EXIT
-				cpack.Username = ""
-				cpack.Arguments = []byte("client disconnecting")
-				client_status = QUITTING
-				exit_command(cpack)
-			}
-			break
-		}
+/*
+ * This function reads one chunk of a transfer from its connection
+ */
+func read_transfer_chunk(reader *bufio.Reader) (Transfer_chunk, error) {
+	var chunk Transfer_chunk
+	json_data, amount_read := read_from_connection(reader)
+	if amount_read <= 0 {
+		return chunk, io.ErrUnexpectedEOF
 	}
+	err := json.Unmarshal(json_data, &chunk)
+	return chunk, err
 }
 
 /*
- * This function is called as a go routine to display the sign in options
+ * This function shows how a file transfer went in the chat strand
  */
-func display_main_menu(choice chan int, channels []string) {
-	currently_selected := 0
-	loop_iteration := 0
-	for {
-		select {
-		// getting users input from channel
-		case c := <-choice:
-			currently_selected = c
+func add_transfer_notice(text string, failed bool) {
+	if client_status != MESSAGING {
+		return
+	}
 
-			// checking if user has pressed enter
-			if currently_selected == -1 {
-				return
-			}
+	notice := Data_packet{Type: TRANSFER, Data: []byte(text)}
+	add_to_chat_strand(notice)
+	if failed {
+		go play_sound("error.mp3")
+	}
+	redraw()
+}
 
-			// alternating between printing a selected option and no selected options
-			if loop_iteration == 16 {
-				loop_iteration = 0
-			} else if loop_iteration < 8 {
-				print_main_menu_with_choice(currently_selected, channels)
-				loop_iteration++
-			} else if loop_iteration < 16 {
-				print_main_menu_without_choice(channels)
-				loop_iteration++
-			}
-			time.Sleep(30 * time.Millisecond)
-		default:
-			// checking if user has pressed enter
-			if currently_selected == -1 {
-				return
-			}
+/*
+ * This function gets the sha256 of a file as hex
+ */
+func get_file_checksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
 
-			// alternating between printing a selected option and no selected options
-			if loop_iteration == 16 {
-				loop_iteration = 0
-			} else if loop_iteration < 8 {
-				print_main_menu_with_choice(currently_selected, channels)
-				loop_iteration++
-			} else if loop_iteration < 16 {
-				print_main_menu_without_choice(channels)
-				loop_iteration++
-			}
-			time.Sleep(30 * time.Millisecond)
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+/*
+ * This function gets where an unfinished download of a file is kept
+ */
+func get_part_path(file_id int) string {
+	return filepath.Join(DOWNLOADS_DIR, strconv.Itoa(file_id)+PART_SUFFIX)
+}
+
+/*
+ * This function finds a path in the downloads folder for a file, numbering it if the name is taken
+ */
+func get_download_path(name string) string {
+	path := filepath.Join(DOWNLOADS_DIR, filepath.Base(name))
+	extension := filepath.Ext(path)
+	for number := 1; ; number++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
 		}
+		path = filepath.Join(DOWNLOADS_DIR, strings.TrimSuffix(filepath.Base(name), extension)+" ("+strconv.Itoa(number)+")"+extension)
 	}
 }
 
-func display_main_menu_with_command(channels []string, input []byte, err []byte) {
-	var bar []byte
-	var padding []byte
+/*
+ * This function formats a number of bytes for people to read
+ */
+func format_size(size int64) string {
+	if size < 1024 {
+		return strconv.FormatInt(size, 10) + " B"
+	} else if size < 1024*1024 {
+		return strconv.FormatFloat(float64(size)/1024, 'f', 1, 64) + " KB"
+	}
+	return strconv.FormatFloat(float64(size)/(1024*1024), 'f', 1, 64) + " MB"
+}
 
-	for i := 0; i < terminal_width; i++ {
-		bar = append(horizontal_line, '-')
+/*
+ * This function handles the react command.
+ * The reaction is taken from the raw input since shortcodes have colons in them
+ */
+func react_command(input string) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
 	}
 
-	for i := 0; i < terminal_width; i++ {
-		padding = append(padding, ' ')
+	// splitting input into command, message id and reaction
+	tokens := strings.SplitN(input, " ", 3)
+	if len(tokens) < 3 || tokens[1] == "" || strings.TrimSpace(tokens[2]) == "" {
+		return []byte("Usage: /react <id> <reaction>")
 	}
 
-	print_main_menu_without_choice(channels)
-	if len(input) > 20 {
-		line_1 := string(bar[:len(input)+4])
-		fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-		line_2 := "| " + string(input) + " |"
-		fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-		line_3 := string(bar[:len(input)+4])
-		fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
-	} else {
-		line_1 := string(bar[:24])
-		fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-		var line_2 string
-		if len(input)%2 == 0 {
-			line_2 = "|" + string(padding[:(22-len(input))/2]) + string(input) + string(padding[:(22-len(input))/2]) + "|"
-		} else {
-			line_2 = "|" + string(padding[:(22-len(input))/2+1]) + string(input) + string(padding[:(22-len(input))/2]) + "|"
-		}
-		fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-		line_3 := string(bar[:24])
-		fmt.Printf("%*s\n", ((terminal_width-len(line_3))/2)+len(line_3), line_3)
+	// send command to server
+	cpack := Command_packet{Type: REACT, Username: username, Arguments: []byte(tokens[1]), Message: []byte(strings.TrimSpace(tokens[2]))}
+	send_command_packet(cpack)
+
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != REACT {
+		custom_error_exit(OUT_OF_SYNC)
 	}
 
-	line_4 := YELLOW + "         " + string(err) + RESET
-	fmt.Printf("%*s\n", ((terminal_width-len(line_4))/2)+len(line_4), line_4)
+	// the reactions under the message show that it worked
+	if cpack.Successful {
+		return nil
+	}
+	return cpack.Arguments
 }
 
 /*
- * This function prints a screen with the login option selected
+ * This function handles the delete-msg command
  */
-func print_main_menu_with_choice(choice int, channels []string) {
-	// clearing terminal
-	clear_terminal()
+func delete_message_command(cpack Command_packet) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	}
 
-	// printing prompt
-	fmt.Println(string(horizontal_line))
-	line_1 := "Select a channel from the list below to join"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-	line_2 := "Use the up and down arrows to change selection"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-	fmt.Println(string(horizontal_line))
-	fmt.Print("\n\n\n\n\n\n")
+	// send command to server
+	send_command_packet(cpack)
 
-	// printing options
-	for i := 0; i < len(channels); i++ {
-		var msg string
-		if i == choice {
-			if i != len(channels)-1 {
-				msg = "--> #" + channels[i] + " <--"
-			} else {
-				msg = "--> " + channels[i] + " <--"
-			}
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != DELETE_MSG {
+		custom_error_exit(OUT_OF_SYNC)
+	}
 
-		} else if i != len(channels)-1 {
-			msg = "#" + channels[i]
-		} else {
-			msg = channels[i]
-		}
-		fmt.Printf("%*s\n", ((terminal_width-len(msg))/2)+len(msg), msg)
-		fmt.Print("\n")
+	return cpack.Arguments
+}
+
+/*
+ * This function handles being removed from a channel by sending the client back to the main menu
+ */
+func removed_from_channel(packet Command_packet) {
+	if client_status != MESSAGING {
+		return
 	}
+
+	// going to the main menu the same way the main command does
+	client_status = IN_MAIN_MENU
+	send_data_packet(Data_packet{Type: CLOSE, Username: username, Data: []byte("State changed")})
+	mutex_chat.Lock()
+	chat_strand = nil
+	mutex_chat.Unlock()
+
+	// letting the user know what happened
+	msg := YELLOW + string(packet.Arguments) + RESET
+	draw(func(screen *strings.Builder) {
+		fmt.Fprintln(screen, string(horizontal_line))
+		fmt.Fprintln(screen, center_text(msg))
+		line := "Press any key to go to the main menu"
+		fmt.Fprintln(screen, center_text(line))
+		fmt.Fprintln(screen, string(horizontal_line))
+	})
+	go play_sound("error.mp3")
 }
 
 /*
- * This function prints a screen with the login option selected
+ * This function handles the list-c command
  */
-func print_main_menu_without_choice(channels []string) {
-	// clearing terminal
-	clear_terminal()
+func list_c_command(cpack Command_packet) []byte {
+	// checking if user is in a channel
+	if client_status != MESSAGING {
+		return []byte("Command not availbale. Must be in a channel.")
+	}
 
-	// printing prompt
-	fmt.Println(string(horizontal_line))
-	line_1 := "Select a channel from the list below to join"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_1))/2)+len(line_1), line_1)
-	line_2 := "Use the up and down arrows to change selection"
-	fmt.Printf("%*s\n", ((terminal_width-len(line_2))/2)+len(line_2), line_2)
-	fmt.Println(string(horizontal_line))
-	fmt.Print("\n\n\n\n\n\n")
+	// send command to server
+	send_command_packet(cpack)
 
-	// printing prompt
-	for i := 0; i < len(channels); i++ {
-		var msg string
-		if i != len(channels)-1 {
-			msg = "#" + channels[i]
-		} else {
-			msg = channels[i]
-		}
-		fmt.Printf("%*s\n", ((terminal_width-len(msg))/2)+len(msg), msg)
-		fmt.Print("\n")
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != LIST_C {
+		custom_error_exit(OUT_OF_SYNC)
 	}
-}
 
-// -----------------------------------------------------------------------------------------------------------------------
-// COMMANDS
-// -----------------------------------------------------------------------------------------------------------------------
+	return cpack.Arguments
+}
 
 /*
- * This function checks if a string is a command
+ * This function handles the list-s command
  */
-func is_comand(input string) bool {
-	tokens := strings.Split(input, " ")
-	return command_to_int[tokens[0]] > 0
+func list_s_command(cpack Command_packet) []byte {
+	// checking if user is signed in
+	if client_status == CHOOSING_SIGN_IN_OPT || client_status == REGISTERING || client_status == LOGGING_IN {
+		return []byte("Command not availbale. Must sign in first.")
+	}
+
+	// send command to server
+	send_command_packet(cpack)
+
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != LIST_S {
+		custom_error_exit(OUT_OF_SYNC)
+	}
+
+	return cpack.Arguments
 }
 
 /*
- * This function executes the command entered
+ * This function handles the log_out command
  */
-func handle_command(input string) []byte {
-	// sending the command to the server
-	packet := parse_command(input)
+func log_out_command(cpack Command_packet) []byte {
+	// checking if user is signed in
+	if client_status == CHOOSING_SIGN_IN_OPT || client_status == REGISTERING || client_status == LOGGING_IN {
+		return []byte("Command not availbale. Must sign in first.")
+	}
 
-	switch packet.Type {
-	case HELP:
-		return help_command(packet)
-	case EXIT:
-		return exit_command(packet)
-	case CREATE:
-		return create_command(packet)
-	case MAIN:
-		return main_command(packet)
-	case CHANGE_TOPIC:
-		return change_topic_command(packet)
-	case ADD_MOD:
-		return add_mod_command(packet)
-	case RM_MOD:
-		return rm_mod_command(packet)
-	case BAN_S:
-		return ban_s_command(packet)
-	default:
-		return nil
+	// send command to server
+	send_command_packet(cpack)
+
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != LOG_OUT {
+		custom_error_exit(OUT_OF_SYNC)
+	}
+
+	// checking if command was successful
+	if string(cpack.Arguments) != "Success" {
+		return cpack.Arguments
 	}
+
+	forget_session()
+	return nil
 }
 
 /*
- * This function parses commands
+ * This function handles the log_out_all command
  */
-func parse_command(input string) Command_packet {
-	var packet Command_packet
-	var args strings.Builder
-
-	// splitting input string into tokens
-	tokens := strings.Split(input, " ")
+func log_out_all_command(cpack Command_packet) []byte {
+	// checking if user is signed in
+	if client_status == CHOOSING_SIGN_IN_OPT || client_status == REGISTERING || client_status == LOGGING_IN {
+		return []byte("Command not availbale. Must sign in first.")
+	}
 
-	// looping over tokens to build agument string
-	for i := 1; i < len(tokens); i++ {
-		if i != 1 {
-			args.WriteString(":")
-		}
+	// send command to server
+	send_command_packet(cpack)
 
-		args.WriteString(tokens[i])
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != LOG_OUT_ALL {
+		custom_error_exit(OUT_OF_SYNC)
 	}
 
-	// initializing packet
-	packet.Type = command_to_int[tokens[0]]
-	packet.Username = username
-	packet.Arguments = []byte(args.String())
+	// checking if command was successful
+	if string(cpack.Arguments) != "Success" {
+		return cpack.Arguments
+	}
 
-	return packet
+	forget_session()
+	return nil
 }
 
 /*
- * This function handles the client exiting the application
+ * This function handles the devices command
  */
-func exit_command(cpack Command_packet) []byte {
+func devices_command(cpack Command_packet) []byte {
+	// checking if user is signed in
+	if client_status == CHOOSING_SIGN_IN_OPT || client_status == REGISTERING || client_status == LOGGING_IN {
+		return []byte("Command not availbale. Must sign in first.")
+	}
+
 	// send command to server
 	send_command_packet(cpack)
 
-	// checking if the server changed states
+	// getting response from server
 	cpack = read_command_packet()
-	if cpack.Type != EXIT || string(cpack.Arguments) != "READY" {
-		custom_error_exit(UNKNOWN)
+	if cpack.Type != DEVICES {
+		custom_error_exit(OUT_OF_SYNC)
 	}
 
-	// closing function on server side that is using data_socket
-	dpack := Data_packet{Type: CLOSE, Username: "", Data: []byte("Client disconnecting")}
-	send_data_packet(dpack)
+	return cpack.Arguments
+}
 
-	// creating exit packet
-	cpack.Type = EXIT
-	cpack.Username = username
-	cpack.Arguments = []byte("CLOSE_SENT")
+/*
+ * This function handles the revoke command
+ */
+func revoke_command(cpack Command_packet) []byte {
+	// checking if user is signed in
+	if client_status == CHOOSING_SIGN_IN_OPT || client_status == REGISTERING || client_status == LOGGING_IN {
+		return []byte("Command not availbale. Must sign in first.")
+	}
 
-	// sending packet to server
+	// send command to server
 	send_command_packet(cpack)
 
-	// shutting down client
-	shutdown()
-	return nil
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != REVOKE {
+		custom_error_exit(OUT_OF_SYNC)
+	}
+
+	return cpack.Arguments
 }
 
 /*
@@ -2290,20 +6182,16 @@ func help_command(cpack Command_packet) []byte {
 
 	packet = read_command_packet()
 
-	quit_channel := make(chan int)
-
 	if string(packet.Arguments) == "0" {
-		go display_help_screen(quit_channel, PUBLIC)
+		draw(func(screen *strings.Builder) { display_help_screen(screen, PUBLIC) })
 	} else if string(packet.Arguments) == "1" {
-		go display_help_screen(quit_channel, MODERATOR)
+		draw(func(screen *strings.Builder) { display_help_screen(screen, MODERATOR) })
 	} else if string(packet.Arguments) == "2" {
-		go display_help_screen(quit_channel, ADMIN)
+		draw(func(screen *strings.Builder) { display_help_screen(screen, ADMIN) })
 	} else {
 		custom_error_exit(UNEXPECTED_DATA)
 	}
 
-	quit_channel <- 1
-
 	if err := keyboard.Open(); err != nil {
 		panic(err)
 	}
@@ -2317,7 +6205,6 @@ func help_command(cpack Command_packet) []byte {
 		}
 
 		if char == 'q' || char == 'Q' {
-			quit_channel <- 0
 			break
 		}
 	}
@@ -2352,11 +6239,12 @@ func main_command(cpack Command_packet) []byte {
 	if string(cpack.Arguments) != "Success" {
 		return cpack.Arguments
 	} else {
-		fmt.Println("updating status")
 		client_status = IN_MAIN_MENU
 		dpack := Data_packet{Type: CLOSE, Username: username, Data: []byte("State changed")}
 		send_data_packet(dpack)
+		mutex_chat.Lock()
 		chat_strand = nil
+		mutex_chat.Unlock()
 	}
 
 	return cpack.Arguments
@@ -2381,24 +6269,29 @@ func create_command(cpack Command_packet) []byte {
 
 	if cpack.Successful {
 		// parsing the choice into an array of strings
-		channels = strings.Split(string(cpack.Arguments), " ")
-
-		// appending QUIT option to menu
-		channels = append(channels, "QUIT")
+		channels = build_menu(string(cpack.Arguments))
 	}
 
 	return cpack.Message
 }
 
 /*
- * This function handles the change_topic command
+ * This function handles the change_topic command.
+ * The topic is taken from the raw input so its colons and spacing are kept
  */
-func change_topic_command(cpack Command_packet) []byte {
+func change_topic_command(input string) []byte {
 	if client_status == CHOOSING_SIGN_IN_OPT || client_status == REGISTERING || client_status == LOGGING_IN {
 		return []byte("Command not availbale. Must sign in first.")
 	}
 
+	// splitting input into command, channel and topic
+	tokens := strings.SplitN(input, " ", 3)
+	if len(tokens) < 3 || strings.TrimSpace(tokens[2]) == "" {
+		return []byte("Usage: /change-topic <channel> <topic>")
+	}
+
 	// sending command to server
+	cpack := Command_packet{Type: CHANGE_TOPIC, Username: username, Arguments: []byte(tokens[1]), Message: []byte(tokens[2])}
 	send_command_packet(cpack)
 
 	// getting response from server
@@ -2473,89 +6366,214 @@ func ban_s_command(cpack Command_packet) []byte {
 	return cpack.Arguments
 }
 
+/*
+ * This function handles listing the accounts and addresses with failed logins
+ */
+func lockouts_command(cpack Command_packet) []byte {
+	// checking if user is signed in
+	if client_status == CHOOSING_SIGN_IN_OPT || client_status == REGISTERING || client_status == LOGGING_IN {
+		return []byte("Command not availbale. Must sign in first.")
+	}
+
+	// send command to server
+	send_command_packet(cpack)
+
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != LOCKOUTS {
+		custom_error_exit(OUT_OF_SYNC)
+	}
+
+	return cpack.Arguments
+}
+
+/*
+ * This function handles clearing the lockout of an account or address
+ */
+func unlock_command(cpack Command_packet) []byte {
+	// checking if user is signed in
+	if client_status == CHOOSING_SIGN_IN_OPT || client_status == REGISTERING || client_status == LOGGING_IN {
+		return []byte("Command not availbale. Must sign in first.")
+	}
+
+	// send command to server
+	send_command_packet(cpack)
+
+	// getting response from server
+	cpack = read_command_packet()
+	if cpack.Type != UNLOCK {
+		custom_error_exit(OUT_OF_SYNC)
+	}
+
+	return cpack.Arguments
+}
+
 /*
  * This function handles the displaying the help screen
  */
-func display_help_screen(quit chan int, role int) {
-	for {
-		select {
-		case q := <-quit:
-			switch q {
-			case 0:
-				return
-			case 1:
-				clear_terminal()
-				fmt.Println(string(horizontal_line))
-				fmt.Println("Below is a list of command and descriptions of what they do.  Press 'q' to quit")
-				fmt.Println(string(horizontal_line))
-				display_public_commands()
-				if role > 0 {
-					display_moderator_commands()
-				}
+func display_help_screen(screen *strings.Builder, role int) {
+	fmt.Fprintln(screen, string(horizontal_line))
+	fmt.Fprintln(screen, "Below is a list of command and descriptions of what they do. Press 'q' to quit")
+	fmt.Fprintln(screen, string(horizontal_line))
+	display_public_commands(screen)
+	if role > 0 {
+		display_moderator_commands(screen)
+	}
 
-				if role > 1 {
-					display_admin_commands()
-				}
-			default:
-				return
-			}
-		default:
-			clear_terminal()
-			fmt.Println(string(horizontal_line))
-			fmt.Println("Below is a list of command and descriptions of what they do. Press 'q' to quit")
-			fmt.Println(string(horizontal_line))
-			display_public_commands()
-			if role > 0 {
-				display_moderator_commands()
-			}
+	if role > 1 {
+		display_admin_commands(screen)
+	}
+}
 
-			if role > 1 {
-				display_admin_commands()
-			}
+/*
+ * This function prints a command on the help screen with its description lined up in a column beside it.
+ * The description wraps within the column, and goes under the command when the terminal is too narrow for it
+ */
+func print_help_entry(screen *strings.Builder, usage string, description string) {
+	entry := " - " + usage
+	column := HELP_COLUMN
+	if terminal_width-column < 20 {
+		column = min(6, terminal_width/4)
+	}
+	if text_width(entry) >= column {
+		fmt.Fprintln(screen, entry)
+		entry = ""
+	}
+
+	for i, line := range wrap_text(description, max(terminal_width-column, 1)) {
+		if i > 0 {
+			entry = ""
 		}
-		time.Sleep(30 * time.Millisecond)
+		fmt.Fprintln(screen, entry+strings.Repeat(" ", column-text_width(entry))+line)
 	}
 }
 
 /*
  * This function prints the commands for the public role
  */
-func display_public_commands() {
-	fmt.Println("Public commands:")
-	fmt.Print("\n")
-	fmt.Println(" - /help\t\t\t\tBrings up the help screen which lists all commands")
-	fmt.Print("\n")
-	fmt.Println(" - /main\t\t\t\tDisconnects you from the current channel and takes you to the main menu")
-	fmt.Print("\n")
+func display_public_commands(screen *strings.Builder) {
+	fmt.Fprintln(screen, "Public commands:")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/help", "Brings up the help screen which lists all commands")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/main", "Disconnects you from the current channel and takes you to the main menu")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/msg <user> <text>", "Sends a direct message to a user")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/join <channel> <password>", "Joins a password protected channel")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/invite <username>", "Invites a user to the current channel")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/reply <id> <text>", "Replies to a message")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "ctrl+j", "Starts a new line in the message you are writing")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/paste", "Writes the next message in paste mode, where enter adds a line and ctrl+d sends")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "```<code>```", "Shows code unwrapped on its own background, fences can be on their own lines")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/view <id>", "Shows the whole of a long message, esc to go back")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "page up / page down", "Scrolls the chat a screen at a time, the up and down arrows a few lines, older messages load at the top")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "end", "Jumps back to the newest message")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "@<username>", "Mentions a user in a message, they find it under MENTIONS in the main menu")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/search <words>", "Finds messages, narrow it down with in:<channel> from:<user> since:<yyyy-mm-dd> until:<yyyy-mm-dd>")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/thread <id>", "Shows only the replies around a message, esc to go back")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/pins", "Shows only the pinned messages of the channel, esc to go back")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/upload <path>", "Shares a file of up to 10 MB in the current channel, again to resume")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/download <id>", "Saves a shared file in the downloads folder, again to resume")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/react <id> <reaction>", "Reacts to a message with an emoji or a shortcode like :+1:, again to undo")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/edit <id> <text>", "Changes the text of a message you sent in the last 15 minutes")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/delete-msg <id>", "Deletes a message you sent in the last 15 minutes (any message for moderators)")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/list-c", "Lists the users in the current channel")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/kick <username>", "Removes a user from the current channel (channel moderators)")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/change-topic <channel> <topic>", "Changes the topic of a channel (channel moderators)")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/set-description <text>", "Changes the description of the current channel, no text clears it (channel moderators)")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/pin <id>", "Pins a message to the current channel (channel moderators)")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/unpin <id>", "Takes a message off the pins of the current channel (channel moderators)")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/add-channel-mod <username>", "Makes a user a moderator of the current channel (channel owners)")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/rm-channel-mod <username>", "Removes a moderator of the current channel (channel owners)")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/list-s", "Lists the users on the server")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/log_out", "Signs you out and forgets this device")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/log_out_all", "Signs you out on every device")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/devices", "Lists the devices you are signed in on")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/revoke <device>", "Signs you out on one of your other devices")
+	fmt.Fprint(screen, "\n")
 }
 
 /*
  * This function prints the commands for the moderator role
  */
-func display_moderator_commands() {
-	fmt.Print("\n")
-	fmt.Println("Moderator commands:")
-	fmt.Print("\n")
-	fmt.Println(" - /ban-s <username>\t\t\tBans a user from the server")
-	fmt.Print("\n")
-	fmt.Println(" - /create <topic>\t\t\tCreates a new channel with a given topic")
-	fmt.Print("\n")
-	fmt.Println(" - /change-topic <channel> <topic>\tchanges the topic of a specific channel")
+func display_moderator_commands(screen *strings.Builder) {
+	fmt.Fprint(screen, "\n")
+	fmt.Fprintln(screen, "Moderator commands:")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/ban-s <username>", "Bans a user from the server")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/create <name> [public|invite|password <password>]", "Creates a new channel with a given name and visibility")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/kick <username>", "Removes a user from any channel")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/change-topic <channel> <topic>", "changes the topic of a specific channel")
 }
 
 /*
  * This function prints the commands for the admin role
  */
-func display_admin_commands() {
-	fmt.Print("\n")
-	fmt.Println("Moderator commands:")
-	fmt.Print("\n")
-	fmt.Println(" - /add-mod <username>\t\t\tGives a user the role moderator")
-	fmt.Print("\n")
-	fmt.Println(" - /rm-mod <username>\t\t\tRemoves the moderator role from a user")
+func display_admin_commands(screen *strings.Builder) {
+	fmt.Fprint(screen, "\n")
+	fmt.Fprintln(screen, "Moderator commands:")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/add-mod <username>", "Gives a user the role moderator")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/rm-mod <username>", "Removes the moderator role from a user")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/lockouts", "Lists accounts and addresses with failed logins")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/unlock <username|address>", "Clears the lockout of an account or address")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/roles", "Lists every role and its permissions")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/create-role <name> <permission> ...", "Creates a role from a set of permissions")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/delete-role <name>", "Deletes a role and takes it away from everyone")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/grant-role <username> <role>", "Gives a role to a user")
+	fmt.Fprint(screen, "\n")
+	print_help_entry(screen, "/revoke-role <username> <role>", "Takes a role away from a user")
 }
 
 func play_sound(file_path string) {
+	play_pitched_sound(file_path, 1)
+}
+
+/*
+ * This function plays a sound sped up by a ratio, which also raises its pitch
+ */
+func play_pitched_sound(file_path string, ratio float64) {
 	f, err := os.Open(file_path)
 	if err != nil {
 		panic(err)
@@ -2572,7 +6590,11 @@ func play_sound(file_path string) {
 	speaker.Init(format.SampleRate, format.SampleRate.N(time.Second/8))
 
 	// Play the audio stream
-	speaker.Play(streamer)
+	if ratio != 1 {
+		speaker.Play(beep.ResampleRatio(4, ratio, streamer))
+	} else {
+		speaker.Play(streamer)
+	}
 
 	time.Sleep(2 * time.Second)
 }
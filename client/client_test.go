@@ -0,0 +1,163 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+/*
+ * This function tests how many columns single characters take up on the terminal
+ */
+func Test_rune_width(t *testing.T) {
+	tests := []struct {
+		name string
+		r    rune
+		want int
+	}{
+		{"ascii letter", 'a', 1},
+		{"space", ' ', 1},
+		{"control character", '\t', 0},
+		{"delete", 0x7f, 0},
+		{"latin accented letter", 'é', 1},
+		{"cyrillic letter", 'Ж', 1},
+		{"greek letter", 'λ', 1},
+		{"chinese character", '中', 2},
+		{"hiragana", 'あ', 2},
+		{"hangul syllable", '한', 2},
+		{"full width letter", 'Ａ', 2},
+		{"emoji", '😀', 2},
+		{"combining acute", '́', 0},
+		{"combining enclosing circle", '⃝', 0},
+		{"zero width joiner", '‍', 0},
+		{"variation selector", '️', 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := rune_width(test.r); got != test.want {
+				t.Errorf("rune_width(%q) = %d, want %d", test.r, got, test.want)
+			}
+		})
+	}
+}
+
+/*
+ * This function tests how many columns text takes up on the terminal
+ */
+func Test_text_width(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"empty", "", 0},
+		{"ascii", "hello", 5},
+		{"cyrillic", "привет", 6},
+		{"chinese", "你好世界", 8},
+		{"mixed chinese and ascii", "hi 你好", 7},
+		{"emoji", "ok 😀", 5},
+		{"combining mark", "José", 4},
+		{"several combining marks", "á̂̃", 1},
+		{"emoji joined into one", "👩‍💻", 4},
+		{"colour codes take no room", "\x1b[31mred\x1b[0m", 3},
+		{"colour codes around wide text", "\x1b[1;32m你好\x1b[0m", 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := text_width(test.text); got != test.want {
+				t.Errorf("text_width(%q) = %d, want %d", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+/*
+ * This function tests cutting text down to a width without splitting characters
+ */
+func Test_cut_to_width(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  string
+	}{
+		{"fits", "hello", 10, "hello"},
+		{"exact fit", "hello", 5, "hello"},
+		{"cut ascii", "hello", 3, "hel"},
+		{"zero width", "hello", 0, ""},
+		{"cut cyrillic", "привет", 4, "прив"},
+		{"cut chinese on a boundary", "你好世界", 4, "你好"},
+		{"wide character does not fit in one column", "你好世界", 5, "你好"},
+		{"wide character in one column", "你好", 1, ""},
+		{"cut before emoji", "ab😀", 3, "ab"},
+		{"combining mark stays with its letter", "ééé", 2, "éé"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := cut_to_width(test.text, test.width); got != test.want {
+				t.Errorf("cut_to_width(%q, %d) = %q, want %q", test.text, test.width, got, test.want)
+			}
+		})
+	}
+}
+
+/*
+ * This function tests wrapping text into lines that fit a width
+ */
+func Test_wrap_text(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  []string
+	}{
+		{"fits on one line", "hello world", 20, []string{"hello world"}},
+		{"wraps at a space", "hello world", 8, []string{"hello", "world"}},
+		{"empty", "", 10, []string{""}},
+		{"long word is cut", "abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"long word cut into full lines", "abcdefgh", 4, []string{"abcd", "efgh"}},
+		{"long word after a short one", "hi abcdefgh", 4, []string{"hi", "abcd", "efgh"}},
+		{"cyrillic wraps at a space", "привет мир", 7, []string{"привет", "мир"}},
+		{"chinese is cut by columns", "你好世界再见", 5, []string{"你好", "世界", "再见"}},
+		{"chinese in a one column line", "你好", 1, []string{"你", "好"}},
+		{"emoji", "😀😀😀", 4, []string{"😀😀", "😀"}},
+		{"combining marks take no room", "café café", 9, []string{"café café"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := wrap_text(test.text, test.width); !slices.Equal(got, test.want) {
+				t.Errorf("wrap_text(%q, %d) = %q, want %q", test.text, test.width, got, test.want)
+			}
+		})
+	}
+}
+
+/*
+ * This function tests removing the last character typed from the input
+ */
+func Test_remove_last_rune(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "", ""},
+		{"ascii", "abc", "ab"},
+		{"two byte cyrillic", "при", "пр"},
+		{"three byte chinese", "你好", "你"},
+		{"four byte emoji", "ok😀", "ok"},
+		{"combining mark is removed on its own", "é", "e"},
+		{"single character", "ж", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := string(remove_last_rune([]byte(test.input))); got != test.want {
+				t.Errorf("remove_last_rune(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
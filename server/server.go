@@ -2,19 +2,34 @@ package main
 
 import (
 	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/fs"
+	"math"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // ---------------------------------------------------------------------------------------------------
@@ -30,6 +45,91 @@ const (
 	MAX_CLIENTS     = 20
 	MAX_PACKET_SIZE = 1024
 	MAX_CHANNELS    = 5
+
+	// outbound queues
+	OUTBOUND_QUEUE_SIZE = 64              // packets buffered per client before new packets are dropped
+	WRITE_TIMEOUT       = 5 * time.Second // longest a single write may block a client's writer routine
+	PACKET_DELIMITER    = '\n'            // marks the end of a packet on the wire
+	MAX_PACKET_LENGTH   = 64 * 1024       // longest packet a client may send, a longer one drops its connection
+	MAX_DROPPED_PACKETS = 128             // packets dropped in a row before a client is disconnected as too slow
+
+	// heartbeats, the environment variables take durations like "15s"
+	HEARTBEAT_INTERVAL     = 10 * time.Second             // how often clients are pinged on the command socket
+	HEARTBEAT_TIMEOUT      = 30 * time.Second             // how long a client may stay silent before it is disconnected
+	HEARTBEAT_INTERVAL_ENV = "CHAT429_HEARTBEAT_INTERVAL" // environment variable for changing HEARTBEAT_INTERVAL
+	HEARTBEAT_TIMEOUT_ENV  = "CHAT429_HEARTBEAT_TIMEOUT"  // environment variable for changing HEARTBEAT_TIMEOUT
+
+	// rate limiting
+	IP_LIMIT_MULTIPLIER = 3                     // an ip address may send this many times what a single client may
+	MUTE_THRESHOLD      = 10                    // violations within VIOLATION_WINDOW before a client is muted
+	VIOLATION_WINDOW    = time.Minute           // how long a violation counts towards MUTE_THRESHOLD
+	MUTE_DURATION       = 5 * time.Minute       // how long a flooding client is muted for
+	RATE_LIMITS_ENV     = "CHAT429_RATE_LIMITS" // environment variable for changing limits, like "message=1/5,login=0.2/5"
+	IP_LIMITER_PRUNE    = time.Minute           // least time between looking for ip addresses that no longer need a limiter
+
+	// login protection
+	MAX_FAILED_LOGINS      = 5                // failed passwords before an account or address is locked out
+	FAILED_LOGIN_WINDOW    = 15 * time.Minute // how long a failed password counts towards MAX_FAILED_LOGINS
+	LOCKOUT_DURATION       = 15 * time.Minute // how long an account or address stays locked out
+	FAILED_LOGIN_DELAY     = 500 * time.Millisecond
+	MAX_FAILED_LOGIN_DELAY = 8 * time.Second
+	AUDIT_LOG_PATH         = "./audit.log"
+
+	// sessions
+	SESSION_TOKEN_LIFETIME = 30 * 24 * time.Hour // how long a session token stays valid after it was issued
+	SESSION_ID_SIZE        = 16                  // random bytes in a session id
+	SESSION_SECRET_SIZE    = 32                  // random bytes in the key used to sign session tokens
+	SESSIONS_PATH          = "./sessions.json"   // where sessions are saved between restarts
+	SESSION_SECRET_PATH    = "./session_secret"  // where the key used to sign session tokens is kept
+
+	// direct messages
+	DIRECT_MESSAGES_PATH        = "./direct_messages.json" // where direct messages for offline users are kept
+	MAX_PENDING_DIRECT_MESSAGES = 100                      // direct messages kept for an offline user before the oldest are dropped
+	MAX_HISTORY                 = 200                      // messages kept per channel for clients catching up
+	HISTORY_PAGE_SIZE           = 50                       // older messages sent at a time to a client scrolling back
+	EDIT_WINDOW                 = 15 * time.Minute         // how long after sending a message its author may edit or delete it
+	MAX_REACTIONS               = 20                       // different reactions a single message can have
+	MAX_REACTION_LENGTH         = 32                       // longest reaction in bytes
+
+	// mentions
+	MENTIONS_PATH = "./mentions.json" // where the mentions inbox of every user is kept
+	MAX_MENTIONS  = 100               // mentions kept per user before the oldest are dropped
+	SAVE_INTERVAL = 5 * time.Second   // how often changes to the mentions inboxes and read markers are written to their files
+
+	// read markers
+	READ_MARKERS_PATH = "./read_markers.json" // where the last message each user read in each channel is kept
+
+	// search
+	ARCHIVE_PATH          = "./messages.jsonl" // where every change to a channel message is appended for searching
+	MAX_ARCHIVED_MESSAGES = 100000             // messages kept in the archive before the oldest are dropped
+	ARCHIVE_COMPACT_LINES = 1000               // lines the archive file has before it may be rewritten with only the current messages
+	MAX_HISTORY_JUMP      = 1000               // most older messages sent at once to a client opening a search result
+	SEARCH_PAGE_SIZE      = 10                 // search results sent per page
+	SEARCH_CONTEXT_SIZE   = 3                  // messages shown before and after a search result
+	SEARCH_DATE_FORMAT    = "2006-01-02"       // format of the dates in the since: and until: filters
+
+	// roles
+	ROLES_PATH = "./roles.json" // where custom roles are saved between restarts
+
+	// channels
+	CHANNELS_PATH          = "./channels.json" // where created channels are saved between restarts
+	MAX_PINS               = 25                // messages that can be pinned in a channel
+	MAX_DESCRIPTION_LENGTH = 1000              // longest channel description in bytes
+	MAX_TOPIC_LENGTH       = 200               // longest channel topic in bytes
+
+	// file transfers
+	FILES_DIR            = "./files"           // where shared files are kept unless FILES_DIR_ENV says otherwise
+	FILES_DIR_ENV        = "CHAT429_FILES_DIR" // environment variable for keeping shared files somewhere else
+	FILES_INDEX          = "files.json"        // list of the shared files, kept in the files directory
+	PART_SUFFIX          = ".part"             // added to the path of a file while it is being uploaded
+	MAX_FILE_SIZE        = 10 * 1024 * 1024    // largest file that can be shared
+	MAX_STORAGE          = 1024 * 1024 * 1024  // most space shared files and unfinished uploads may take
+	MAX_USER_STORAGE     = 100 * 1024 * 1024   // most space the shared files and unfinished uploads of one user may take
+	MAX_FILE_NAME_LENGTH = 255                 // longest file name in bytes
+	TRANSFER_CHUNK_SIZE  = 32 * 1024           // bytes of a file sent in each chunk
+	TRANSFER_TIMEOUT     = 30 * time.Second    // how long a transfer may wait for the other side before it is dropped
+	UPLOAD_EXPIRY        = 24 * time.Hour      // how long an unfinished upload can be resumed
+	MAX_ACTIVE_TRANSFERS = 3                   // uploads and downloads a single session can run at once
 )
 
 // ansi text styles
@@ -76,6 +176,79 @@ const (
 
 	// system
 	CONNECT // used to establish data socket connection
+
+	// admin commands
+	LOCKOUTS // lists accounts and addresses with failed logins
+	UNLOCK   // clears the lockout of an account or address
+
+	// system
+	PING // sent by the server to check that a client is still there
+	PONG // sent by the client in response to a ping
+
+	// public commands
+	LOG_OUT_ALL // logs an account out on every device
+	DEVICES     // lists the devices an account is signed in on
+	REVOKE      // signs an account out on one device
+	MSG         // sends a direct message to a user
+
+	// system
+	DIRECT_MSG // delivers a direct message to a client
+
+	// public commands
+	INVITE // adds a user to the members of a channel
+	JOIN   // joins a password protected channel
+
+	// moderator commands
+	KICK // removes a user from a channel
+
+	// system
+	REMOVED // tells a client it was removed from its channel
+
+	// channel owner commands
+	ADD_CHANNEL_MOD // makes a user a moderator of a channel
+	RM_CHANNEL_MOD  // takes the moderator role of a channel away from a user
+
+	// role management commands
+	ROLES       // lists every role and its permissions
+	CREATE_ROLE // creates a custom role from a set of permissions
+	DELETE_ROLE // deletes a custom role
+	GRANT_ROLE  // gives a custom role to a user
+	REVOKE_ROLE // takes a custom role away from a user
+
+	// public commands
+	EDIT_MSG   // changes the text of a message
+	DELETE_MSG // deletes a message
+
+	// client commands, these are handled without the server
+	REPLY  // sends a message in reply to another message
+	THREAD // shows only the replies around one message
+
+	// public commands
+	REACT    // adds a reaction to a message or takes it away
+	MENTIONS // lists the messages the client was mentioned in
+
+	// system
+	MENTION // tells a client it was mentioned in a message
+
+	// public commands
+	SEARCH         // finds messages by their words, channel, author and date
+	SEARCH_CONTEXT // gets the messages around a search result
+
+	// moderator commands
+	PIN             // pins a message to its channel
+	UNPIN           // takes a message off the pins of its channel
+	SET_DESCRIPTION // changes the description of a channel
+
+	// client commands, these are handled without the server
+	PINS // shows only the pinned messages of a channel
+
+	// public commands
+	UPLOAD   // sends a file to share in a channel
+	DOWNLOAD // gets a file that was shared in a channel
+
+	// client commands, these are handled without the server
+	VIEW  // shows the whole of a long message
+	PASTE // lets the next message have more than one line
 )
 
 // client states
@@ -91,17 +264,32 @@ const (
 
 // packet types
 const (
-	ACCEPT       = iota // 0	Used to indicate a name or password was accepted
-	DENY                // 1	Used to indicate a name or password was denied
-	MESSAGE             // 2	Used to send a standard message to a channel
-	JOIN_MSG            // 3 Used to send a joining message to a chat
-	LEAVE_MSG           // 4 Used to send a leaving message to a chat
-	REGISTRATION        // 5	Used to send a username or password for registering a user
-	LOGIN               // 6	Used to send a username or password for loggin in
-	MENU_OPTION         // 7	Used to send menu options
-	CLOSE               // 8 Used to close a function if the state changes of the client
-	ESC                 // 9 used when a user uses escape to go back
-	REFRESH             // 10 used to refresh certain screens
+	ACCEPT        = iota // 0	Used to indicate a name or password was accepted
+	DENY                 // 1	Used to indicate a name or password was denied
+	MESSAGE              // 2	Used to send a standard message to a channel
+	JOIN_MSG             // 3 Used to send a joining message to a chat
+	LEAVE_MSG            // 4 Used to send a leaving message to a chat
+	REGISTRATION         // 5	Used to send a username or password for registering a user
+	LOGIN                // 6	Used to send a username or password for loggin in
+	MENU_OPTION          // 7	Used to send menu options
+	CLOSE                // 8 Used to close a function if the state changes of the client
+	ESC                  // 9 used when a user uses escape to go back
+	REFRESH              // 10 used to refresh certain screens
+	RATE_LIMITED         // 11 used to tell a client it is sending too quickly
+	RESUME               // 12 used to resume a session after reconnecting
+	SESSION_TOKEN        // 13 used to give a client the token for resuming its session
+	HISTORY              // 14 used to request the messages sent after a given message id, and to send them in one packet
+	MESSAGE_SENT         // 15 used to tell a client the id given to its message
+	EDITED               // 16 used to tell clients the text of a message was changed
+	DELETED              // 17 used to tell clients a message was deleted
+	REACTED              // 18 used to tell clients the reactions to a message changed
+	MENTIONED            // 19 used by clients to show a mention from another channel, never sent
+	READ_MARKER          // 20 used to tell a client the last message its user read in a channel
+	TYPING               // 21 used to say a user is typing in a channel
+	NOT_TYPING           // 22 used to say a user stopped typing in a channel
+	CHANNEL_INFO         // 23 used to tell clients the name, topic, description and pins of their channel
+	TRANSFER             // 24 used by clients to show how a file transfer went, never sent
+	OLDER_HISTORY        // 25 used to request a page of the messages sent before a given message id
 )
 
 // user roles
@@ -111,6 +299,49 @@ const (
 	ADMIN            // 2	only one admin exists.....
 )
 
+// channel visibility
+const (
+	CHANNEL_PUBLIC      = iota // 0	listed for and joinable by everyone
+	CHANNEL_INVITE_ONLY        // 1	listed for and joinable by members only
+	CHANNEL_PASSWORD           // 2	members only, anyone with the password can become a member
+)
+
+// channel roles
+const (
+	CHANNEL_MEMBER    = iota // 0	anyone who can get into a channel
+	CHANNEL_MODERATOR        // 1	appointed by the owner to moderate a channel
+	CHANNEL_OWNER            // 2	the user that created a channel
+)
+
+// permissions that roles are made of
+const (
+	PERM_CREATE_CHANNEL      = "create_channel"      // creating channels
+	PERM_BAN                 = "ban"                 // banning users from the server
+	PERM_CHANGE_TOPIC        = "change_topic"        // changing the topic of a channel
+	PERM_KICK                = "kick"                // removing users from a channel
+	PERM_INVITE              = "invite"              // inviting users to a channel without being a member
+	PERM_MANAGE_CHANNEL_MODS = "manage_channel_mods" // appointing and removing the moderators of a channel
+	PERM_ACCESS_CHANNELS     = "access_channels"     // seeing and joining every channel
+	PERM_MANAGE_LOCKOUTS     = "manage_lockouts"     // listing and clearing login lockouts
+	PERM_MANAGE_ROLES        = "manage_roles"        // giving out roles and creating custom ones
+	PERM_DELETE_MESSAGES     = "delete_messages"     // deleting messages sent by other users
+	PERM_PIN_MESSAGES        = "pin_messages"        // pinning messages to a channel
+)
+
+// kinds of rate limits
+const (
+	MESSAGE_LIMIT      = iota // 0	messages relayed to a channel
+	COMMAND_LIMIT             // 1	commands sent on the command socket
+	LOGIN_LIMIT               // 2	usernames and passwords sent while logging in
+	REGISTRATION_LIMIT        // 3	usernames sent while registering an account
+	TYPING_LIMIT              // 4	typing notifications relayed to a channel
+	SEARCH_LIMIT              // 5	searches of the message archive
+	UPLOAD_LIMIT              // 6	uploads started or resumed
+	HISTORY_LIMIT             // 7	pages of older messages read from the archive
+	DOWNLOAD_LIMIT            // 8	downloads started or resumed
+	NUM_OF_LIMITS             // 9	number of kinds of rate limits
+)
+
 // custom errors
 const (
 	OUT_OF_SYNC     = iota // 0		packet type does not match expeceted type
@@ -126,6 +357,7 @@ type Account_info struct {
 	Password string
 	Role     int
 	Banned   bool
+	Roles    []string // custom roles given to the account on top of its built in role
 }
 
 // struct for holding client data
@@ -134,16 +366,48 @@ type Client struct {
 	Id              int
 	data_sock       net.Conn
 	command_sock    net.Conn
+	data_reader     *bufio.Reader
+	command_reader  *bufio.Reader
+	outbound        *Outbound_queue
+	command_queue   *Outbound_queue
+	limiter         *Rate_limiter
+	ip_address      string
+	session_id      int64
+	device          string
+	token_id        string
+	last_heard      time.Time
 	State           int
 	Logged_in       bool
 	Current_channel int
 }
 
+// struct for holding the packets waiting to be written to a client's data socket
+//
+// Every client gets its own queue and writer routine so that a client that stops
+// reading only ever blocks itself. When the queue is full new packets for that client
+// are dropped rather than waiting for space.
+type Outbound_queue struct {
+	packets chan []byte   // marshaled packets waiting to be written
+	done    chan struct{} // closed when the client disconnects
+	dropped atomic.Int64  // number of packets dropped in a row because the queue was full
+}
+
 // struc for holding a data packet
 type Data_packet struct {
-	Type     int
-	Username string
-	Data     []byte
+	Type       int
+	Username   string
+	Data       []byte
+	Message_id int
+	Sent       time.Time
+	Edited     bool
+	Deleted    bool
+	Parent_id  int                 // id of the message this one replies to
+	Reactions  map[string][]string // users who reacted to the message, keyed by reaction
+	Mentions   []string            // users mentioned in the message
+
+	// sent with a channel list
+	Unread          []int // unread messages in each channel of the list
+	Unread_mentions []int // unread messages mentioning the user in each channel of the list
 }
 
 // struct for holding a command packet
@@ -165,9 +429,192 @@ type Parsed_command struct {
 }
 
 type Channel struct {
-	Id    int
-	Topic []byte
-	Users []int
+	Id          int
+	Name        string        // name the channel is found by, it never changes
+	Topic       []byte        // short line shown next to the name, changed with /topic
+	Description string        // longer text shown at the start of the chat
+	Users       []int         `json:"-"`
+	History     []Data_packet `json:"-"`
+	Visibility  int
+	Password    []byte        // sha256 of the password of a password protected channel
+	Members     []string      // usernames allowed into a channel that is not public
+	Owner       string        // username of the user that created the channel
+	Moderators  []string      // usernames appointed by the owner to moderate the channel
+	Pinned      []Data_packet // messages pinned by the moderators of the channel, oldest first
+}
+
+// struct for telling clients the name, topic, description and pinned messages of their channel
+type Channel_info struct {
+	Name        string
+	Topic       string
+	Description string
+	Pinned      []Data_packet
+}
+
+// struct for holding a file shared in a channel
+type Shared_file struct {
+	Id        int
+	Name      string
+	Size      int64
+	Checksum  string // sha256 of the contents as hex
+	Uploader  string
+	Channel   int
+	Uploaded  time.Time // when the upload finished, or when it was last started while it is unfinished
+	Complete  bool      // false while the upload can still be resumed
+	uploading bool      // set while a connection is sending the file
+}
+
+// struct for asking to upload or download a file
+type Transfer_request struct {
+	Name     string
+	Size     int64
+	Checksum string
+	Address  string // where the server connects to for sending the chunks, only its port is used
+	Offset   int64  // bytes of a download the client already has
+}
+
+// struct for telling a client which file a transfer is for and where it starts
+type Transfer_start struct {
+	Id       int
+	Name     string
+	Size     int64
+	Checksum string
+	Offset   int64
+}
+
+// struct for holding one chunk of a file on a transfer connection
+type Transfer_chunk struct {
+	Offset int64
+	Data   []byte
+	Done   bool   // set on the packet that ends a transfer
+	Error  string // why a transfer failed
+}
+
+// struct for holding a session that can be resumed with a session token
+type Session struct {
+	Username string
+	Device   string
+	Expires  time.Time
+}
+
+// struct for holding a direct message
+type Direct_message struct {
+	Id   int
+	From string
+	To   string
+	Text []byte
+	Sent time.Time
+}
+
+// struct for holding a message a user was mentioned in
+type Mention struct {
+	Message_id int
+	Channel    string
+	From       string
+	Text       []byte
+	Sent       time.Time
+}
+
+// struct for holding a channel message kept for searching
+type Archived_message struct {
+	Message_id int
+	Channel    int // id of the channel the message was sent in
+	Username   string
+	Text       []byte
+	Sent       time.Time
+	Edited     bool
+	Deleted    bool
+	Parent_id  int                 // id of the message this one replies to
+	Reactions  map[string][]string // users who reacted to the message, keyed by reaction
+}
+
+// struct for holding a parsed search
+type Search_query struct {
+	Terms   []string
+	Channel int // -1 to search every channel
+	Author  string
+	Since   time.Time
+	Until   time.Time
+}
+
+// struct for holding a message found by a search
+type Search_result struct {
+	Message_id int
+	Channel    string
+	Username   string
+	Text       []byte
+	Sent       time.Time
+	Edited     bool
+}
+
+// struct for holding a page of search results
+type Search_page struct {
+	Results   []Search_result
+	Total     int // results on every page
+	Page      int
+	Page_size int
+}
+
+// struct for holding the signed contents of a session token
+type Token_claims struct {
+	Session_id string
+	Username   string
+	Expires    int64
+}
+
+// struct for holding the settings of a rate limit
+type Rate_limit struct {
+	Rate  float64 // tokens added to the bucket each second
+	Burst float64 // most tokens the bucket can hold
+}
+
+// struct for holding a token bucket
+type Token_bucket struct {
+	tokens      float64
+	last_refill time.Time
+}
+
+// struct for tracking the rate limits of a client or an ip address
+type Rate_limiter struct {
+	mutex       sync.Mutex
+	multiplier  float64
+	buckets     [NUM_OF_LIMITS]Token_bucket
+	violations  []time.Time
+	muted_until time.Time
+}
+
+// struct for tracking failed logins of an account or address
+type Login_failures struct {
+	Count        int
+	Last_failure time.Time
+	Locked_until time.Time
+}
+
+// rate limits for a single client, the limits of an ip address are these times IP_LIMIT_MULTIPLIER.
+// These are the defaults, RATE_LIMITS_ENV can change them
+var rate_limits = [NUM_OF_LIMITS]Rate_limit{
+	MESSAGE_LIMIT:      {Rate: 1, Burst: 5},
+	COMMAND_LIMIT:      {Rate: 0.5, Burst: 5},
+	LOGIN_LIMIT:        {Rate: 0.2, Burst: 5},
+	REGISTRATION_LIMIT: {Rate: 1.0 / 60, Burst: 5},
+	TYPING_LIMIT:       {Rate: 1, Burst: 10},
+	SEARCH_LIMIT:       {Rate: 0.5, Burst: 10},
+	UPLOAD_LIMIT:       {Rate: 0.1, Burst: 5},
+	HISTORY_LIMIT:      {Rate: 1, Burst: 10},
+	DOWNLOAD_LIMIT:     {Rate: 0.2, Burst: 5},
+}
+
+// names of the kinds of rate limits as they are written in RATE_LIMITS_ENV
+var rate_limit_names = [NUM_OF_LIMITS]string{
+	MESSAGE_LIMIT:      "message",
+	COMMAND_LIMIT:      "command",
+	LOGIN_LIMIT:        "login",
+	REGISTRATION_LIMIT: "registration",
+	TYPING_LIMIT:       "typing",
+	SEARCH_LIMIT:       "search",
+	UPLOAD_LIMIT:       "upload",
+	HISTORY_LIMIT:      "history",
+	DOWNLOAD_LIMIT:     "download",
 }
 
 // ---------------------------------------------------------------------------------------------------
@@ -186,10 +633,115 @@ var (
 	num_of_active_clients       int
 	num_of_active_clients_mutex sync.Mutex
 
+	// counter for telling apart clients that used the same slot
+	last_session_id atomic.Int64
+
 	// array that holds registered accounts
 	registered_accounts       []Account_info
 	registered_accounts_mutex sync.Mutex
 
+	// rate limiters for each ip address that has connected
+	ip_limiters        = make(map[string]*Rate_limiter)
+	ip_limiters_pruned time.Time // when ip addresses that no longer need a limiter were last looked for
+	ip_limiters_mutex  sync.Mutex
+
+	// failed logins for each account and address
+	account_failures     = make(map[string]*Login_failures)
+	address_failures     = make(map[string]*Login_failures)
+	login_failures_mutex sync.Mutex
+
+	// serializes writes to the audit log
+	audit_log_mutex sync.Mutex
+
+	// sessions that can be resumed, keyed by session id
+	sessions       = make(map[string]Session)
+	sessions_mutex sync.Mutex
+
+	// key used to sign session tokens
+	session_secret []byte
+
+	// direct messages waiting for their recipients to log in, keyed by recipient
+	pending_direct_messages       = make(map[string][]Direct_message)
+	pending_direct_messages_mutex sync.Mutex
+
+	// messages users were mentioned in, keyed by the mentioned user, and whether they changed since they were saved
+	mentions         = make(map[string][]Mention)
+	mentions_changed bool
+	mentions_mutex   sync.Mutex
+
+	// id of the last message each user read in each channel, keyed by username then channel id,
+	// and whether they changed since they were saved
+	read_markers         = make(map[string]map[int]int)
+	read_markers_changed bool
+	read_markers_mutex   sync.Mutex
+
+	// every channel message that was not deleted keyed by id, the ids of the messages in each channel in order,
+	// the ids of the messages each word is in and every word in order for looking words up by how they start,
+	// which is only kept once the archive is loaded.
+	// The lines in the archive file and the oldest id that may still be archived are kept for compacting
+	// the file and dropping old messages
+	archive           = make(map[int]Archived_message)
+	channel_archive   = make(map[int][]int)
+	search_index      = make(map[string]map[int]bool)
+	indexed_words     []string
+	archive_loaded    bool
+	archive_lines     int
+	oldest_archive_id int
+	archive_mutex     sync.Mutex
+
+	// counter for giving every message an id
+	last_message_id atomic.Int64
+
+	// files shared in channels keyed by id, and where their contents are kept
+	shared_files       = make(map[int]*Shared_file)
+	last_file_id       int
+	shared_files_mutex sync.Mutex
+	files_dir          = FILES_DIR
+
+	// uploads and downloads running in each session, keyed by session id
+	active_transfers       = make(map[int64]int)
+	active_transfers_mutex sync.Mutex
+
+	// how often clients are pinged and how long they may stay silent, HEARTBEAT_INTERVAL_ENV and HEARTBEAT_TIMEOUT_ENV can change them
+	heartbeat_interval = HEARTBEAT_INTERVAL
+	heartbeat_timeout  = HEARTBEAT_TIMEOUT
+
+	// names of the built in roles, indexed by Account_info.Role
+	role_names = []string{"public", "moderator", "admin"}
+
+	// permissions of the built in roles
+	builtin_roles = map[string][]string{
+		"public":    nil,
+		"moderator": {PERM_CREATE_CHANNEL, PERM_BAN, PERM_CHANGE_TOPIC, PERM_KICK, PERM_INVITE, PERM_DELETE_MESSAGES, PERM_PIN_MESSAGES},
+		"admin":     permission_names,
+	}
+
+	// permissions a channel role gives inside its own channel
+	channel_role_permissions = map[int][]string{
+		CHANNEL_MODERATOR: {PERM_CHANGE_TOPIC, PERM_KICK, PERM_INVITE, PERM_DELETE_MESSAGES, PERM_PIN_MESSAGES},
+		CHANNEL_OWNER:     {PERM_CHANGE_TOPIC, PERM_KICK, PERM_INVITE, PERM_DELETE_MESSAGES, PERM_PIN_MESSAGES, PERM_MANAGE_CHANNEL_MODS},
+	}
+
+	// every permission a role can have
+	permission_names = []string{PERM_CREATE_CHANNEL, PERM_BAN, PERM_CHANGE_TOPIC, PERM_KICK, PERM_INVITE, PERM_MANAGE_CHANNEL_MODS, PERM_ACCESS_CHANNELS, PERM_MANAGE_LOCKOUTS, PERM_MANAGE_ROLES, PERM_DELETE_MESSAGES, PERM_PIN_MESSAGES}
+
+	// roles made by users with the manage_roles permission, keyed by name
+	custom_roles       = make(map[string][]string)
+	custom_roles_mutex sync.Mutex
+	role_name_regex    = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+	// shortcodes that can be used as reactions
+	reaction_regex = regexp.MustCompile(`^:[a-z0-9_+-]+:$`)
+
+	// usernames start with a letter, end with a letter or number and are 5 to 20 characters long
+	username_regex = regexp.MustCompile(`^\pL[\pL\pN_-]{3,18}[\pL\pN]$`)
+
+	// scripts that are written together, so a Japanese name can mix kanji and kana
+	script_groups = map[string]string{"Han": "CJK", "Hiragana": "CJK", "Katakana": "CJK", "Hangul": "CJK", "Bopomofo": "CJK"}
+
+	// @username not preceded by part of a word, so email addresses are not mentions
+	mention_regex = regexp.MustCompile(`(?:^|[^\pL\pN_-])@(\pL[\pL\pN_-]*[\pL\pN])`)
+
 	// passive socket for accepting clients
 	accept_socket net.Listener
 )
@@ -227,6 +779,7 @@ func start_server() {
 
 	// initializing channels list
 	init_channels()
+	load_channels()
 
 	// initializing the array to hold active clients
 	init_active_clients()
@@ -234,6 +787,27 @@ func start_server() {
 	// reading in saved accounts
 	load_accounts()
 
+	// reading in sessions that can be resumed
+	load_session_secret()
+	load_sessions()
+	load_roles()
+
+	// reading in direct messages for offline users
+	load_pending_direct_messages()
+	load_mentions()
+	load_read_markers()
+	go save_periodically()
+
+	// reading in the messages that can be searched
+	load_archive()
+
+	// reading in the files shared in channels
+	load_files()
+
+	// reading in changes to the rate limits and heartbeats
+	load_rate_limits()
+	load_heartbeat_settings()
+
 	// creating passive socket
 	create_socket()
 
@@ -274,9 +848,9 @@ func init_channels() {
 	// adding default channel to array and setting the rest of the slot to available
 	for index := 0; index < MAX_CHANNELS; index++ {
 		if index == 0 {
-			channels[index] = &Channel{Id: index, Topic: []byte("nonsense"), Users: nil}
+			channels[index] = &Channel{Id: index, Name: "nonsense", Topic: []byte(""), Users: nil}
 		} else {
-			channels[index] = &Channel{Id: -1, Topic: []byte(""), Users: nil}
+			channels[index] = &Channel{Id: -1, Name: "", Topic: []byte(""), Users: nil}
 		}
 	}
 }
@@ -435,6 +1009,8 @@ func setup_signal_handler() {
 func handle_ctrl_c(signale chan os.Signal) {
 	<-signale
 	save_accounts()
+	save_mentions()
+	save_read_markers()
 	accept_socket.Close()
 
 	//TODO
@@ -595,8 +1171,17 @@ func serve_client_if_space(command_socket net.Conn) {
 	// incrementing the number of clients online
 	increment_active_clients()
 
+	// creating reader for the command socket
+	command_reader := bufio.NewReaderSize(command_socket, MAX_PACKET_SIZE)
+
 	// establishing a connection for data
-	data_socket := establish_data_socket(command_socket)
+	data_socket, device := establish_data_socket(command_reader)
+	if data_socket == nil {
+		fmt.Println("system: Could not open a data socket for the client, disconnecting it")
+		command_socket.Close()
+		decrement_num_of_active_clients()
+		return
+	}
 
 	// finding a free space in the list of clients
 	index := find_free_space_for_client()
@@ -606,6 +1191,15 @@ func serve_client_if_space(command_socket net.Conn) {
 	active_clients[index].Id = index
 	active_clients[index].command_sock = command_socket
 	active_clients[index].data_sock = data_socket
+	active_clients[index].command_reader = command_reader
+	active_clients[index].data_reader = bufio.NewReaderSize(data_socket, MAX_PACKET_SIZE)
+	active_clients[index].outbound = create_outbound_queue(data_socket)
+	active_clients[index].command_queue = create_outbound_queue(command_socket)
+	active_clients[index].limiter = new_rate_limiter(1)
+	active_clients[index].ip_address = get_ip_address(command_socket)
+	active_clients[index].session_id = last_session_id.Add(1)
+	active_clients[index].device = device
+	active_clients[index].last_heard = time.Now()
 	active_clients[index].State = CHOOSING_SIGN_IN_OPT
 	client := active_clients[index]
 	active_clients_mutex.Unlock()
@@ -616,11 +1210,14 @@ func serve_client_if_space(command_socket net.Conn) {
 }
 
 /*
- * This function creates the data socket
+ * This function creates the data socket, it returns nil if the client left or its data socket could not be reached
  */
-func establish_data_socket(command_socket net.Conn) net.Conn {
+func establish_data_socket(command_reader *bufio.Reader) (net.Conn, string) {
 	// address of data socket from client
-	json_data, amount_read := read_from_connection(command_socket)
+	json_data, amount_read := read_from_connection(command_reader)
+	if amount_read <= 0 {
+		return nil, ""
+	}
 
 	// unmarhsal data into packet
 	packet := unmarshal_command_packet(json_data[:amount_read])
@@ -628,12 +1225,14 @@ func establish_data_socket(command_socket net.Conn) net.Conn {
 	// attempt to connect to data_socket
 	data_socket, err := net.Dial(SERVER_TYPE, string(packet.Arguments))
 	if err != nil {
-		error_exit(err)
+		fmt.Println("system: Failed to open data socket -", err)
+		return nil, ""
 	}
 
 	fmt.Println("system: Created data socket")
 
-	return data_socket
+	// the client names its device in the message of the packet
+	return data_socket, string(packet.Message)
 }
 
 /*
@@ -673,10 +1272,20 @@ func serve_client(client Client) {
 	// starting a routine to handle inbound commands
 	go handle_inbound_commands(client)
 
+	// starting a routine to check that the client is still there
+	go send_heartbeats(client)
+
 	// core loop to handle a clients state
 	for {
 		// getting latest state if client
-		client = update_client(client)
+		var same_session bool
+		client, same_session = update_session(client)
+
+		// checking if the client was disconnected
+		if !same_session {
+			fmt.Println("system: Closing client's main routine")
+			return
+		}
 
 		// switching on the state of the client
 		switch client.State {
@@ -704,13 +1313,19 @@ func choose_sign_in_opt(client Client) {
 	// reading packet from client
 	packet := read_data_packet(client)
 	fmt.Printf("system: Packet type %d\n", packet.Type)
-	fmt.Printf("system: Received menu option \"%s\" from client #%d\n", string(packet.Data), client.Id)
 
 	// checking if the client has changed state and this function needs to return
 	if packet.Type == CLOSE {
 		return
 	}
 
+	// checking if the client is resuming a session after reconnecting, the token it sent is never printed
+	if packet.Type == RESUME {
+		resume_session(client, string(packet.Data))
+		return
+	}
+	fmt.Printf("system: Received menu option \"%s\" from client #%d\n", string(packet.Data), client.Id)
+
 	// checking if the packet has the expected type
 	if packet.Type != MENU_OPTION {
 		custom_error_exit(OUT_OF_SYNC)
@@ -759,11 +1374,19 @@ func register_client(client Client) {
 			custom_error_exit(OUT_OF_SYNC)
 		}
 
-		// saving username temporarely
-		username = string(packet.Data)
+		// checking if the client is registering accounts too quickly
+		if allowed, retry_after := take_token(client, REGISTRATION_LIMIT); !allowed {
+			packet.Type = DENY
+			packet.Data = []byte(rate_limit_message(retry_after))
+			send_data_packet(packet, client)
+			continue
+		}
+
+		// saving username temporarely, composed so names typed with combining marks match precomposed ones
+		username = normalize_username(string(packet.Data))
 
 		// validating username
-		is_valid, packet := validate_username(string(packet.Data))
+		is_valid, packet := validate_username(username)
 
 		// sending packet to client
 		send_data_packet(packet, client)
@@ -823,6 +1446,7 @@ func register_client(client Client) {
 			// updating info in active clients list
 			active_clients[client.Id].Account_info.Password = password
 			active_clients[client.Id].State = IN_MAIN_MENU
+			active_clients[client.Id].Logged_in = true
 
 			// adding account to list of accounts
 			tempAccount := Account_info{Username: username, Password: password}
@@ -833,6 +1457,9 @@ func register_client(client Client) {
 
 			save_accounts()
 
+			// giving the client a token so it can resume its session
+			send_session_token(client, username)
+
 			break
 		}
 	}
@@ -852,15 +1479,8 @@ func validate_username(username string) (bool, Data_packet) {
 		return false, packet
 	}
 
-	// creating regex
-	regex_username_pattern := "^[A-Za-z][A-Za-z0-9-_]{3,18}[A-Za-z0-9]$"
-	regex_username, err := regexp.Compile(regex_username_pattern)
-	if err != nil {
-		fmt.Println("Error compiling regex:", err)
-	}
-
 	// checking if username matches the regular expression
-	if !regex_username.MatchString(username) {
+	if !username_regex.MatchString(username) {
 		fmt.Println("server: Username has invalid character or formatting")
 
 		// creating packet
@@ -869,6 +1489,16 @@ func validate_username(username string) (bool, Data_packet) {
 		return false, packet
 	}
 
+	// checking for names that mix alphabets, which can be used to look like someone else
+	if mixes_scripts(username) {
+		fmt.Println("server: Username mixes alphabets")
+
+		// creating packet
+		packet := Data_packet{Type: DENY, Data: []byte("Username can not mix letters from different alphabets")}
+
+		return false, packet
+	}
+
 	// username is valid
 	fmt.Println("server: Username is valid")
 
@@ -881,6 +1511,46 @@ func validate_username(username string) (bool, Data_packet) {
 	return true, packet
 }
 
+/*
+ * This function puts a username in composed form so the same name is stored and looked up the same way
+ * however it was typed
+ */
+func normalize_username(username string) string {
+	return norm.NFC.String(username)
+}
+
+/*
+ * This function checks if the letters in a username come from more than one alphabet. Numbers, dashes
+ * and underscores belong to every alphabet
+ */
+func mixes_scripts(username string) bool {
+	found := ""
+	for _, r := range username {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+
+		// finding the alphabet the letter belongs to
+		script := ""
+		for name, table := range unicode.Scripts {
+			if unicode.Is(table, r) {
+				script = name
+				break
+			}
+		}
+		if group, grouped := script_groups[script]; grouped {
+			script = group
+		}
+
+		if found == "" {
+			found = script
+		} else if script != found {
+			return true
+		}
+	}
+	return false
+}
+
 /*
  * This function validates passwords
  */
@@ -915,6 +1585,7 @@ func validate_password(password string) (bool, Data_packet) {
 func login(client Client) {
 	var index int
 	var exists bool
+	var username string
 
 	// looping until a valid username is entered, the user hits esc, or the user quits
 	for {
@@ -939,6 +1610,17 @@ func login(client Client) {
 			custom_error_exit(OUT_OF_SYNC)
 		}
 
+		// checking if the client is sending login attempts too quickly
+		if allowed, retry_after := take_token(client, LOGIN_LIMIT); !allowed {
+			packet.Type = DENY
+			packet.Data = []byte(rate_limit_message(retry_after))
+			send_data_packet(packet, client)
+			continue
+		}
+
+		// composing the name the same way it was stored when the account was registered
+		packet.Data = []byte(normalize_username(string(packet.Data)))
+
 		// checking if an account exists with the given username
 		index, exists = name_is_exists(string(packet.Data))
 
@@ -950,13 +1632,16 @@ func login(client Client) {
 			continue
 		}
 
-		// checking if the account is already logged in
-		if exists && is_logged_in(string(packet.Data)) {
+		// checking if the account or address is locked out
+		if locked, remaining := is_locked_out(string(packet.Data), client.ip_address); locked {
 			packet.Type = DENY
-			packet.Data = []byte("This account is already logged in somewhere")
+			packet.Data = []byte("Too many failed logins. Try again in " + remaining.Round(time.Second).String())
 			send_data_packet(packet, client)
 			continue
-		} else if exists && is_banned(index) {
+		}
+
+		// checking if the account is banned
+		if exists && is_banned(index) {
 			packet.Type = DENY
 			packet.Data = []byte("This account is banned from the server")
 			send_data_packet(packet, client)
@@ -964,11 +1649,12 @@ func login(client Client) {
 		}
 
 		// adding client's username to active clients
+		username = string(packet.Data)
 		active_clients_mutex.Lock()
-		active_clients[client.Id].Account_info.Username = string(packet.Data)
+		active_clients[client.Id].Account_info.Username = username
 		active_clients_mutex.Unlock()
 
-		fmt.Printf("system: Found account for the name given by client #%s\n", string(client.Id))
+		fmt.Printf("system: Found account for the name given by client #%d\n", client.Id)
 
 		// creating and sending accept packet
 		packet.Type = ACCEPT
@@ -1002,6 +1688,22 @@ func login(client Client) {
 			custom_error_exit(OUT_OF_SYNC)
 		}
 
+		// checking if the client is sending login attempts too quickly
+		if allowed, retry_after := take_token(client, LOGIN_LIMIT); !allowed {
+			packet.Type = DENY
+			packet.Data = []byte(rate_limit_message(retry_after))
+			send_data_packet(packet, client)
+			continue
+		}
+
+		// checking if the account or address got locked out while entering the password
+		if locked, remaining := is_locked_out(username, client.ip_address); locked {
+			packet.Type = DENY
+			packet.Data = []byte("Too many failed logins. Try again in " + remaining.Round(time.Second).String())
+			send_data_packet(packet, client)
+			continue
+		}
+
 		// checking if password matches account password
 		registered_accounts_mutex.Lock()
 		if string(packet.Data) == registered_accounts[index].Password {
@@ -1015,16 +1717,143 @@ func login(client Client) {
 			active_clients[client.Id].Account_info.Role = registered_accounts[index].Role
 			active_clients_mutex.Unlock()
 			registered_accounts_mutex.Unlock()
+
+			// forgetting the failed logins of the account
+			clear_account_failures(username)
+			audit_log("successful login for account " + username + " from " + client.ip_address)
+
+			// giving the client a token so it can resume its session
+			send_session_token(client, username)
+
+			// handing over direct messages sent while the user was offline
+			deliver_pending_direct_messages(client, username)
 			return
 		} else {
+			registered_accounts_mutex.Unlock()
+
+			// recording failure and slowing down repeated guesses
+			time.Sleep(record_failed_login(username, client.ip_address))
+
 			packet.Type = DENY
 			packet.Data = []byte("Incorrect password")
 			send_data_packet(packet, client)
-			registered_accounts_mutex.Unlock()
 		}
 	}
 }
 
+/*
+ * This function checks if an account or an address is locked out and returns how long the lockout lasts
+ */
+func is_locked_out(username string, ip_address string) (bool, time.Duration) {
+	login_failures_mutex.Lock()
+	defer login_failures_mutex.Unlock()
+
+	now := time.Now()
+	remaining := time.Duration(0)
+
+	if failures, exists := account_failures[username]; exists {
+		remaining = max(remaining, failures.Locked_until.Sub(now))
+	}
+	if failures, exists := address_failures[ip_address]; exists {
+		remaining = max(remaining, failures.Locked_until.Sub(now))
+	}
+
+	return remaining > 0, remaining
+}
+
+/*
+ * This function records a failed login for an account and an address and locks either
+ * of them out once they reach MAX_FAILED_LOGINS. It returns how long to wait before
+ * answering the client, which doubles with every failure
+ */
+func record_failed_login(username string, ip_address string) time.Duration {
+	login_failures_mutex.Lock()
+	account, account_locked := add_login_failure(account_failures, username)
+	address, address_locked := add_login_failure(address_failures, ip_address)
+	failures := max(account.Count, address.Count)
+
+	// gathering the events so the audit log is written after the lock is released
+	events := []string{fmt.Sprintf("failed login for account %s from %s (%d for account, %d for address)", username, ip_address, account.Count, address.Count)}
+	if account_locked {
+		events = append(events, "locked out account "+username+" for "+LOCKOUT_DURATION.String())
+	}
+	if address_locked {
+		events = append(events, "locked out address "+ip_address+" for "+LOCKOUT_DURATION.String())
+	}
+	login_failures_mutex.Unlock()
+
+	for _, event := range events {
+		audit_log(event)
+	}
+
+	// calculating progressive delay
+	delay := FAILED_LOGIN_DELAY
+	for i := 1; i < failures && delay < MAX_FAILED_LOGIN_DELAY; i++ {
+		delay *= 2
+	}
+
+	return min(delay, MAX_FAILED_LOGIN_DELAY)
+}
+
+/*
+ * This function adds a failure to the failures tracked for a key and returns the updated failures.
+ * It returns true if this failure locked the key out. The caller must hold login_failures_mutex
+ */
+func add_login_failure(failures_map map[string]*Login_failures, key string) (*Login_failures, bool) {
+	now := time.Now()
+
+	// starting over if the previous failures are old or their lockout has ended
+	failures, exists := failures_map[key]
+	if !exists || now.Sub(failures.Last_failure) > FAILED_LOGIN_WINDOW || (!failures.Locked_until.IsZero() && now.After(failures.Locked_until)) {
+		failures = &Login_failures{}
+		failures_map[key] = failures
+	}
+
+	failures.Count++
+	failures.Last_failure = now
+
+	// locking out once the limit is reached
+	if failures.Count >= MAX_FAILED_LOGINS && failures.Locked_until.IsZero() {
+		failures.Locked_until = now.Add(LOCKOUT_DURATION)
+		return failures, true
+	}
+
+	return failures, false
+}
+
+/*
+ * This function forgets the failed logins of an account
+ */
+func clear_account_failures(username string) {
+	login_failures_mutex.Lock()
+	defer login_failures_mutex.Unlock()
+
+	delete(account_failures, username)
+}
+
+/*
+ * This function appends an event to the audit log and prints it.
+ * A log that cannot be written is reported rather than stopping the server
+ */
+func audit_log(event string) {
+	fmt.Println("audit: " + event)
+
+	audit_log_mutex.Lock()
+	defer audit_log_mutex.Unlock()
+
+	// opening log for appending
+	file, err := os.OpenFile(AUDIT_LOG_PATH, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Println("system: Failed to open audit log -", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(time.Now().Format(time.RFC3339) + " " + event + "\n"); err != nil {
+		fmt.Println("system: Failed to write to audit log -", err)
+	}
+}
+
 /*
  * This function checks if a user is banned from ther server
  */
@@ -1055,735 +1884,4711 @@ func message(client Client) {
 			return
 		}
 
+		// checking if the client is catching up on messages it missed
+		if packet.Type == HISTORY {
+			send_history(client, string(packet.Data))
+			continue
+		}
+
+		// checking if the client is scrolling back past the messages it has
+		if packet.Type == OLDER_HISTORY {
+			send_older_history(client, string(packet.Data))
+			continue
+		}
+
 		// checking if the packet has the expected type
-		if packet.Type != MESSAGE && packet.Type != JOIN_MSG && packet.Type != LEAVE_MSG {
+		if packet.Type != MESSAGE && packet.Type != JOIN_MSG && packet.Type != LEAVE_MSG && packet.Type != TYPING && packet.Type != NOT_TYPING {
 			custom_error_exit(OUT_OF_SYNC)
 		}
 
-		// sending message to everyone in the chat
-		active_clients_mutex.Lock()
-		channels_mutex.Lock()
-		for _, user := range channels[client.Current_channel].Users {
-			if user != client.Id {
-				send_data_packet(packet, *active_clients[user])
-			}
+		// dropping messages sent after the client was removed from the channel
+		if client = update_client(client); client.State != MESSAGING {
+			continue
 		}
-		channels_mutex.Unlock()
-		active_clients_mutex.Unlock()
-	}
-}
 
-/*
- * This function checks to see if a username is already taken
- */
-func name_is_exists(username string) (int, bool) {
-	registered_accounts_mutex.Lock()
-	defer registered_accounts_mutex.Unlock()
+		// passing typing notifications on to the channel without keeping them
+		if packet.Type == TYPING || packet.Type == NOT_TYPING {
+			relay_typing(client, packet.Type)
+			continue
+		}
 
-	// looping over exising accounts
-	for index, current_account := range registered_accounts {
-		// checking if username exists
-		if current_account.Username == username {
-			return index, true
+		// checking if the client has been muted for flooding
+		if muted, retry_after := is_muted(client); muted {
+			packet = Data_packet{Type: RATE_LIMITED, Data: []byte("429 muted for flooding - retry after " + format_retry_after(retry_after))}
+			send_data_packet(packet, client)
+			continue
 		}
-	}
 
-	// looping over active clients
-	for index, current_user := range active_clients {
-		if current_user.Account_info.Username == username {
-			return index, true
+		// checking if the client is sending messages too quickly
+		if allowed, retry_after := take_token(client, MESSAGE_LIMIT); !allowed {
+			packet = Data_packet{Type: RATE_LIMITED, Data: []byte(rate_limit_message(retry_after))}
+			send_data_packet(packet, client)
+			continue
 		}
-	}
 
-	return -1, false
-}
+		// building the message the server keeps from only its type, text and the message it replies to, so clients
+		// cannot send reactions or mark messages edited or deleted. The username comes from the session so clients
+		// cannot post as others and then edit or delete what they posted
+		packet = Data_packet{Type: packet.Type, Username: client.Account_info.Username, Data: packet.Data, Parent_id: packet.Parent_id}
+		packet.Message_id = next_message_id()
+		packet.Sent = time.Now()
+		channels_mutex.Lock()
 
-/*
- * This function handles closing the server is a predefined error occures
- */
-func error_exit(err error) {
-	fmt.Println("system: ERROR -", err)
-	fmt.Println("system: shutting down server...")
+		// replies can only be to messages in the same channel
+		if packet.Parent_id != 0 && find_message(channels[client.Current_channel], packet.Parent_id) == nil {
+			packet.Parent_id = 0
+		}
 
-	// TODO
-	os.Exit(1)
-}
+		// finding the users the message mentions
+		if packet.Type == MESSAGE {
+			packet.Mentions = find_mentions(string(packet.Data), client.Account_info.Username, channels[client.Current_channel])
+		}
+		add_to_history(channels[client.Current_channel], packet)
+		users := append([]int(nil), channels[client.Current_channel].Users...)
+		channel_name := channels[client.Current_channel].Name
+		channels_mutex.Unlock()
 
-/*
- * This function handles exiting the server if a custom error occurs
- */
-func custom_error_exit(err int) {
-	switch err {
-	case OUT_OF_SYNC:
-		fmt.Println("system: ERROR - Server and client out of sync")
-	case UNEXPECTED_DATA:
-		fmt.Println("system: ERROR - Unexpected data found in packet")
-	case UNKNOWN:
-		fmt.Println("system: ERROR - An unknown error occured")
-	}
+		// sending message to everyone in the chat
+		broadcast_data_packet(packet, users, client.Id)
 
-	fmt.Println("system: Shutting down...")
-	// TODO
-	os.Exit(1)
+		// keeping the message so it can be searched
+		if packet.Type == MESSAGE {
+			archive_message(Archived_message{Message_id: packet.Message_id, Channel: client.Current_channel, Username: client.Account_info.Username, Text: packet.Data, Sent: packet.Sent, Parent_id: packet.Parent_id})
+		}
+
+		// letting the mentioned users know even if they are in another channel or offline
+		if len(packet.Mentions) > 0 {
+			mention := Mention{Message_id: packet.Message_id, Channel: channel_name, From: client.Account_info.Username, Text: packet.Data, Sent: packet.Sent}
+			notify_mentions(mention, packet.Mentions)
+		}
+
+		// telling the sender which id its message was given
+		send_data_packet(Data_packet{Type: MESSAGE_SENT, Message_id: packet.Message_id}, client)
+	}
 }
 
 /*
- * This function handles removing a client from the active clients list
+ * This function tells everyone else in a client's channel that its user started or stopped typing.
+ * Muted clients cannot send messages, so their typing is not shown, and notifications sent too quickly
+ * are dropped since every one of them goes out to the whole channel
  */
-func sub_client(client Client) {
-	fmt.Println("system: Disconnecting client...")
-
-	decrement_num_of_active_clients()
-
-	active_clients_mutex.Lock()
-	defer active_clients_mutex.Unlock()
+func relay_typing(client Client, typing_type int) {
+	if muted, _ := is_muted(client); muted {
+		return
+	}
+	if allowed, _ := take_token(client, TYPING_LIMIT); !allowed {
+		return
+	}
 
-	active_clients[client.Id].data_sock = nil
-	active_clients[client.Id].command_sock = nil
-	active_clients[client.Id].Account_info.Username = ""
-	active_clients[client.Id].Account_info.Password = ""
-	active_clients[client.Id].State = CHOOSING_SIGN_IN_OPT
-	active_clients[client.Id].Id = -1
-	active_clients[client.Id].Logged_in = false
-	active_clients[client.Id].Account_info.Role = 0
-	active_clients[client.Id].Current_channel = -1
+	channels_mutex.Lock()
+	users := append([]int(nil), channels[client.Current_channel].Users...)
+	channels_mutex.Unlock()
 
-	client.command_sock.Close()
-	client.data_sock.Close()
+	// the username comes from the session so clients cannot make others appear to type
+	packet := Data_packet{Type: typing_type, Username: client.Account_info.Username}
+	broadcast_data_packet(packet, users, client.Id)
 }
 
 /*
- * This function decrements the number of active clients
+ * This function finds the users mentioned in a message who can see the channel it was sent in.
+ * The channels mutex must be held by the caller
  */
-func decrement_num_of_active_clients() {
-	num_of_active_clients_mutex.Lock()
-	defer num_of_active_clients_mutex.Unlock()
+func find_mentions(text string, sender string, channel *Channel) []string {
+	var mentioned []string
+	for _, match := range mention_regex.FindAllStringSubmatch(text, -1) {
+		username := match[1]
 
-	num_of_active_clients--
+		// skipping the sender, repeated mentions, and names that are not accounts
+		if username == sender || slices.Contains(mentioned, username) || get_user_index(username) == -1 {
+			continue
+		}
+
+		// users who cannot see the channel are not told about its messages
+		if can_access_channel(username, channel) {
+			mentioned = append(mentioned, username)
+		}
+	}
+	return mentioned
 }
 
 /*
- * This function checks if an account is logged in
- * It returns true if an account is indeed logged in
+ * This function adds a mention to the inbox of every mentioned user and tells their sessions about it
  */
-func is_logged_in(username string) bool {
+func notify_mentions(mention Mention, usernames []string) {
+	// adding the mention to the inboxes
+	mentions_mutex.Lock()
+	for _, username := range usernames {
+		inbox := append(mentions[username], mention)
+
+		// dropping the oldest mentions once the inbox is full
+		if len(inbox) > MAX_MENTIONS {
+			inbox = inbox[len(inbox)-MAX_MENTIONS:]
+		}
+		mentions[username] = inbox
+	}
+	mentions_changed = true
+	mentions_mutex.Unlock()
+
+	// gathering sessions
+	var recipients []Client
 	active_clients_mutex.Lock()
-	defer active_clients_mutex.Unlock()
 	for _, user := range active_clients {
-		if user.Account_info.Username == username {
-			return true
+		if user.Logged_in && user.State != QUITTING && slices.Contains(usernames, user.Account_info.Username) {
+			recipients = append(recipients, *user)
 		}
+	}
+	active_clients_mutex.Unlock()
 
+	// mentions go over the command socket so they arrive whatever channel the user is in, through each
+	// session's queue so a session that stopped reading cannot stall the sender
+	json_data, err := json.Marshal(mention)
+	if err != nil {
+		error_exit(err)
+	}
+	for _, recipient := range recipients {
+		queue_command_packet(Command_packet{Type: MENTION, Username: mention.From, Message: json_data}, recipient)
 	}
-	return false
 }
 
 /*
- * This function updates the state of a client
+ * This function takes a deleted message out of every mentions inbox
  */
-func update_client_state(client Client, state int) Client {
-	active_clients_mutex.Lock()
-	active_clients[client.Id].State = state
-	active_clients_mutex.Unlock()
-	client.State = state
-	return client
+func remove_mentions(message_id int) {
+	mentions_mutex.Lock()
+	defer mentions_mutex.Unlock()
+
+	for username, inbox := range mentions {
+		kept := slices.DeleteFunc(inbox, func(mention Mention) bool { return mention.Message_id == message_id })
+		if len(kept) != len(inbox) {
+			mentions[username] = kept
+			mentions_changed = true
+		}
+	}
 }
 
 /*
- * This function sends a packet to a specified client
+ * This function finds a message in the history of a channel.
+ * The channels mutex must be held by the caller
  */
-func send_data_packet(packet Data_packet, client Client) {
-	// marshaling data
-	json_data := marshal_packet(packet)
-
-	// sending packet
-	write_to_connection(client.data_sock, json_data)
+func find_message(channel *Channel, message_id int) *Data_packet {
+	for index := range channel.History {
+		if channel.History[index].Message_id == message_id {
+			return &channel.History[index]
+		}
+	}
+	return nil
 }
 
 /*
- * This function reads a packet from a client
+ * This function handles the edit command which changes the text of a message the client sent recently
  */
-func read_data_packet(client Client) Data_packet {
-	json_data, amount_read := read_from_connection(client.data_sock)
-	var packet Data_packet
+func edit_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
 
-	// checking if the packet is empty
-	if amount_read > 0 {
-		// unmarshaling json packet
-		packet = unmarshal_packet(json_data[:amount_read])
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = EDIT_MSG
+	cpack.Username = client.Account_info.Username
+
+	// getting the id of the message
+	message_id := -1
+	if len(command.Args) == 1 {
+		if id, err := strconv.Atoi(strings.TrimPrefix(command.Args[0], "#")); err == nil {
+			message_id = id
+		}
 	}
 
-	return packet
-}
+	if client.State != MESSAGING {
+		cpack.Arguments = []byte("You are not in a channel")
+	} else if message_id == -1 || len(command.Message) == 0 {
+		cpack.Arguments = []byte("Usage: /edit <id> <text>")
+	} else if muted, retry_after := is_muted(client); muted {
+		cpack.Arguments = []byte("429 muted for flooding - retry after " + format_retry_after(retry_after))
+	} else {
+		var edited Data_packet
+		var archived Archived_message
+		var mention Mention
+		var new_mentions []string
+		var pins_changed bool
 
-/*
- * This function sends a packet to a specified client
- */
-func send_command_packet(packet Command_packet, client Client) {
-	// marshaling data
-	json_data := marshal_command_packet(packet)
+		channels_mutex.Lock()
+		channel := channels[client.Current_channel]
+		message := find_message(channel, message_id)
+		if message == nil || message.Deleted {
+			cpack.Arguments = []byte("No message #" + strconv.Itoa(message_id) + " in #" + channel.Name)
+		} else if message.Username != client.Account_info.Username {
+			cpack.Arguments = []byte("You can only edit your own messages")
+		} else if time.Since(message.Sent) > EDIT_WINDOW {
+			cpack.Arguments = []byte("Messages can only be edited for " + strconv.Itoa(int(EDIT_WINDOW.Minutes())) + " minutes after they are sent")
+		} else {
+			// changing the message in the history so clients catching up get the new text
+			message.Data = command.Message
+			message.Edited = true
+
+			// only users the new text mentions for the first time are told about it
+			mentioned := find_mentions(string(message.Data), client.Account_info.Username, channel)
+			for _, username := range mentioned {
+				if !slices.Contains(message.Mentions, username) {
+					new_mentions = append(new_mentions, username)
+				}
+			}
+			message.Mentions = mentioned
+			mention = Mention{Message_id: message_id, Channel: channel.Name, From: message.Username, Text: message.Data, Sent: message.Sent}
+			edited = copy_message(*message)
+			archived = Archived_message{Message_id: message_id, Channel: client.Current_channel, Username: message.Username, Text: message.Data, Sent: message.Sent, Edited: true, Parent_id: message.Parent_id, Reactions: edited.Reactions}
+			edited.Type = EDITED
+
+			// the pinned copy of the message gets the new text as well
+			if pin := find_pin(channel, message_id); pin != -1 {
+				channel.Pinned[pin].Data = message.Data
+				channel.Pinned[pin].Edited = true
+				pins_changed = true
+				save_channels()
+			}
 
-	// sending packet
-	write_to_connection(client.command_sock, json_data)
+			cpack.Arguments = []byte("Edited message #" + strconv.Itoa(message_id))
+			cpack.Successful = true
+		}
+		users := append([]int(nil), channel.Users...)
+		var info Data_packet
+		if pins_changed {
+			info = get_channel_info(channel)
+		}
+		channels_mutex.Unlock()
+
+		// updating the message for everyone in the channel
+		if cpack.Successful {
+			broadcast_data_packet(edited, users, -1)
+			if pins_changed {
+				broadcast_data_packet(info, users, -1)
+			}
+			archive_message(archived)
+			if len(new_mentions) > 0 {
+				notify_mentions(mention, new_mentions)
+			}
+		}
+	}
+
+	send_command_packet(cpack, client)
 }
 
 /*
- * This function reads a packet from a client
+ * This function handles the delete-msg command which removes a message the client sent recently,
+ * or any message if the client is allowed to delete messages in the channel
  */
-func read_command_packet(client Client) Command_packet {
-	json_data, amount_read := read_from_connection(client.command_sock)
-	fmt.Printf("server: Recieved packet from client: %d\n", client.Id)
-	fmt.Printf("server: \"%s\"\n", string(json_data))
+func delete_message_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
 
-	var packet Command_packet
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = DELETE_MSG
+	cpack.Username = client.Account_info.Username
 
-	if amount_read == -1 {
-		packet.Type = CLOSE
-		return packet
+	// getting the id of the message
+	message_id := -1
+	if len(command.Args) == 1 {
+		if id, err := strconv.Atoi(strings.TrimPrefix(command.Args[0], "#")); err == nil {
+			message_id = id
+		}
 	}
 
-	// checking if the packet is empty
-	if amount_read < 0 {
-		return packet
-	}
+	if client.State != MESSAGING {
+		cpack.Arguments = []byte("You are not in a channel")
+	} else if message_id == -1 {
+		cpack.Arguments = []byte("Usage: /delete-msg <id>")
+	} else {
+		var deleted Data_packet
+		var author string
+		var pins_changed bool
 
-	// unmarshaling json packet
-	packet = unmarshal_command_packet(json_data[:amount_read])
+		channels_mutex.Lock()
+		channel := channels[client.Current_channel]
+		channel_name := channel.Name
+		message := find_message(channel, message_id)
+		is_author := message != nil && message.Username == client.Account_info.Username
+		if message == nil || message.Deleted {
+			cpack.Arguments = []byte("No message #" + strconv.Itoa(message_id) + " in #" + channel_name)
+		} else if !is_author && !authorize_in_channel(client.Account_info.Username, PERM_DELETE_MESSAGES, channel) {
+			cpack.Arguments = []byte("You can only delete your own messages")
+		} else if is_author && time.Since(message.Sent) > EDIT_WINDOW && !authorize_in_channel(client.Account_info.Username, PERM_DELETE_MESSAGES, channel) {
+			cpack.Arguments = []byte("Messages can only be deleted for " + strconv.Itoa(int(EDIT_WINDOW.Minutes())) + " minutes after they are sent")
+		} else {
+			// removing the text from the history so clients catching up never see it
+			message.Data = nil
+			message.Deleted = true
+			message.Reactions = nil
+			message.Mentions = nil
+			author = message.Username
+			deleted = *message
+			deleted.Type = DELETED
+
+			// deleted messages do not stay pinned
+			if unpin_message(channel, message_id) {
+				pins_changed = true
+				save_channels()
+			}
 
-	return packet
-}
+			cpack.Arguments = []byte("Deleted message #" + strconv.Itoa(message_id))
+			cpack.Successful = true
+		}
+		users := append([]int(nil), channel.Users...)
+		var info Data_packet
+		if pins_changed {
+			info = get_channel_info(channel)
+		}
+		channels_mutex.Unlock()
 
-/*
- * This function writes to a specified net.Conn
- */
-func write_to_connection(connection net.Conn, data []byte) {
-	if connection == nil {
-		return
+		// removing the message for everyone in the channel
+		if cpack.Successful {
+			broadcast_data_packet(deleted, users, -1)
+			if pins_changed {
+				broadcast_data_packet(info, users, -1)
+			}
+			remove_mentions(message_id)
+			archive_message(Archived_message{Message_id: message_id, Channel: client.Current_channel, Username: author, Deleted: true})
+			if !is_author {
+				audit_log("deletion of message #" + strconv.Itoa(message_id) + " by " + author + " in #" + channel_name + " by " + client.Account_info.Username)
+			}
+		}
 	}
 
-	_, err := connection.Write(data)
-	if err != nil {
-		fmt.Println("system: Failed to write to socket")
-	}
+	send_command_packet(cpack, client)
 }
 
 /*
- * This function reads from a specified net.Conn
+ * This function handles the pin command which pins a message to the current channel
  */
-func read_from_connection(connection net.Conn) ([]byte, int) {
-	if connection == nil {
-		return nil, -1
+func pin_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = PIN
+	cpack.Username = client.Account_info.Username
+
+	// getting the id of the message
+	message_id := -1
+	if len(command.Args) == 1 {
+		if id, err := strconv.Atoi(strings.TrimPrefix(command.Args[0], "#")); err == nil {
+			message_id = id
+		}
 	}
 
-	// reading packet from user
-	data := make([]byte, MAX_PACKET_SIZE)
-	amount_read, err := connection.Read(data)
-	if err != nil {
-		fmt.Println("system: Failed to read from socket")
+	if client.State != MESSAGING {
+		cpack.Arguments = []byte("You are not in a channel")
+	} else if message_id == -1 {
+		cpack.Arguments = []byte("Usage: /pin <id>")
+	} else {
+		var info Data_packet
+
+		channels_mutex.Lock()
+		channel := channels[client.Current_channel]
+		message := find_message(channel, message_id)
+		if !authorize_in_channel(client.Account_info.Username, PERM_PIN_MESSAGES, channel) {
+			cpack.Arguments = []byte("You don't have permission to use this command")
+		} else if message == nil || message.Deleted || message.Type != MESSAGE {
+			cpack.Arguments = []byte("No message #" + strconv.Itoa(message_id) + " in #" + channel.Name)
+		} else if find_pin(channel, message_id) != -1 {
+			cpack.Arguments = []byte("Message #" + strconv.Itoa(message_id) + " is already pinned")
+		} else if len(channel.Pinned) >= MAX_PINS {
+			cpack.Arguments = []byte("#" + channel.Name + " already has " + strconv.Itoa(MAX_PINS) + " pinned messages, unpin one first")
+		} else {
+			// reactions change too often to be kept with the pin
+			pinned := copy_message(*message)
+			pinned.Reactions = nil
+			channel.Pinned = append(channel.Pinned, pinned)
+			save_channels()
+			info = get_channel_info(channel)
+
+			cpack.Arguments = []byte("Pinned message #" + strconv.Itoa(message_id))
+			cpack.Successful = true
+		}
+		users := append([]int(nil), channel.Users...)
+		channels_mutex.Unlock()
+
+		// updating the pins of everyone in the channel
+		if cpack.Successful {
+			broadcast_data_packet(info, users, -1)
+		}
 	}
-	return data, amount_read
+
+	send_command_packet(cpack, client)
 }
 
 /*
- * This function marshals a packet into a json file
+ * This function handles the unpin command which takes a message off the pins of the current channel
  */
-func marshal_packet(packet Data_packet) []byte {
-	// marshaling data
-	json_data, err := json.Marshal(packet)
-	if err != nil {
-		fmt.Println("server: Error marshaling data-", err.Error())
+func unpin_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = UNPIN
+	cpack.Username = client.Account_info.Username
+
+	// getting the id of the message
+	message_id := -1
+	if len(command.Args) == 1 {
+		if id, err := strconv.Atoi(strings.TrimPrefix(command.Args[0], "#")); err == nil {
+			message_id = id
+		}
 	}
-	return json_data
-}
 
-/*
- * This function unmarshals json data into a packetand handles the possible errors
- */
-func unmarshal_packet(json_data []byte) Data_packet {
-	// unmarshaling json packet
-	//json_data = trim_null_characters(json_data)
-	var packet Data_packet
-	err := json.Unmarshal(json_data, &packet)
-	if err != nil {
-		error_exit(err)
+	if client.State != MESSAGING {
+		cpack.Arguments = []byte("You are not in a channel")
+	} else if message_id == -1 {
+		cpack.Arguments = []byte("Usage: /unpin <id>")
+	} else {
+		var info Data_packet
+
+		channels_mutex.Lock()
+		channel := channels[client.Current_channel]
+		if !authorize_in_channel(client.Account_info.Username, PERM_PIN_MESSAGES, channel) {
+			cpack.Arguments = []byte("You don't have permission to use this command")
+		} else if !unpin_message(channel, message_id) {
+			cpack.Arguments = []byte("Message #" + strconv.Itoa(message_id) + " is not pinned in #" + channel.Name)
+		} else {
+			save_channels()
+			info = get_channel_info(channel)
+
+			cpack.Arguments = []byte("Unpinned message #" + strconv.Itoa(message_id))
+			cpack.Successful = true
+		}
+		users := append([]int(nil), channel.Users...)
+		channels_mutex.Unlock()
+
+		// updating the pins of everyone in the channel
+		if cpack.Successful {
+			broadcast_data_packet(info, users, -1)
+		}
 	}
-	return packet
+
+	send_command_packet(cpack, client)
 }
 
 /*
- * This function marshals a packet into a json file
+ * This function handles the set-description command which changes the description shown at the start of the current channel.
+ * An empty description brings back the default one
  */
-func marshal_command_packet(packet Command_packet) []byte {
-	// marshaling data
-	json_data, err := json.Marshal(packet)
-	if err != nil {
-		fmt.Println("server: Error marshaling data-", err.Error())
+func set_description_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = SET_DESCRIPTION
+	cpack.Username = client.Account_info.Username
+
+	description := strings.TrimSpace(string(command.Message))
+
+	if client.State != MESSAGING {
+		cpack.Arguments = []byte("You are not in a channel")
+	} else if len(description) > MAX_DESCRIPTION_LENGTH {
+		cpack.Arguments = []byte("Descriptions can be at most " + strconv.Itoa(MAX_DESCRIPTION_LENGTH) + " bytes long")
+	} else {
+		var info Data_packet
+
+		channels_mutex.Lock()
+		channel := channels[client.Current_channel]
+		if client.Current_channel == 0 {
+			cpack.Arguments = []byte("Default channel. Cannot change this channel's description")
+		} else if !authorize_in_channel(client.Account_info.Username, PERM_CHANGE_TOPIC, channel) {
+			cpack.Arguments = []byte("You don't have permission to use this command")
+		} else {
+			channel.Description = description
+			save_channels()
+			info = get_channel_info(channel)
+
+			cpack.Arguments = []byte("Changed the description of #" + channel.Name)
+			if description == "" {
+				cpack.Arguments = []byte("Cleared the description of #" + channel.Name)
+			}
+			cpack.Successful = true
+		}
+		users := append([]int(nil), channel.Users...)
+		channels_mutex.Unlock()
+
+		// updating the banner of everyone in the channel
+		if cpack.Successful {
+			broadcast_data_packet(info, users, -1)
+		}
 	}
-	return json_data
+
+	send_command_packet(cpack, client)
 }
 
 /*
- * This function unmarshals json data into a packetand handles the possible errors
+ * This function gets the position of a message in the pins of a channel, or -1 if it is not pinned.
+ * The channels mutex must be held by the caller
  */
-func unmarshal_command_packet(json_data []byte) Command_packet {
-	var packet Command_packet
-	err := json.Unmarshal(json_data, &packet)
+func find_pin(channel *Channel, message_id int) int {
+	return slices.IndexFunc(channel.Pinned, func(pinned Data_packet) bool { return pinned.Message_id == message_id })
+}
+
+/*
+ * This function takes a message off the pins of a channel and reports if it was pinned.
+ * The channels mutex must be held by the caller
+ */
+func unpin_message(channel *Channel, message_id int) bool {
+	pin := find_pin(channel, message_id)
+	if pin == -1 {
+		return false
+	}
+	channel.Pinned = slices.Delete(channel.Pinned, pin, pin+1)
+	return true
+}
+
+/*
+ * This function builds the packet telling clients the name, topic, description and pinned messages of a channel.
+ * The channels mutex must be held by the caller
+ */
+func get_channel_info(channel *Channel) Data_packet {
+	info := Channel_info{
+		Name:        channel.Name,
+		Topic:       string(channel.Topic),
+		Description: channel.Description,
+		Pinned:      slices.Clone(channel.Pinned),
+	}
+
+	json_data, err := json.Marshal(info)
 	if err != nil {
 		error_exit(err)
 	}
-	return packet
+	return Data_packet{Type: CHANNEL_INFO, Data: json_data}
 }
 
 /*
- * This function handles incoming commands
+ * This function handles the react command which adds a reaction to a message or takes it away again
  */
-func handle_inbound_commands(client Client) {
-	for {
-		packet := read_command_packet(client)
-		if packet.Type == -1 {
-			return
+func react_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = REACT
+	cpack.Username = client.Account_info.Username
+
+	// getting the id of the message
+	message_id := -1
+	if len(command.Args) == 1 {
+		if id, err := strconv.Atoi(strings.TrimPrefix(command.Args[0], "#")); err == nil {
+			message_id = id
 		}
-		if execute_command(packet, client) {
-			fmt.Println("system: Closing command routine")
-			return
+	}
+	reaction := string(command.Message)
+
+	if client.State != MESSAGING {
+		cpack.Arguments = []byte("You are not in a channel")
+	} else if message_id == -1 || len(reaction) == 0 {
+		cpack.Arguments = []byte("Usage: /react <id> <reaction>")
+	} else if !is_valid_reaction(reaction) {
+		cpack.Arguments = []byte("Reactions must be an emoji or a shortcode like :+1:")
+	} else {
+		var reacted Data_packet
+		var archived Archived_message
+
+		channels_mutex.Lock()
+		channel := channels[client.Current_channel]
+		message := find_message(channel, message_id)
+		if message == nil || message.Deleted {
+			cpack.Arguments = []byte("No message #" + strconv.Itoa(message_id) + " in #" + channel.Name)
+		} else if slices.Contains(message.Reactions[reaction], client.Account_info.Username) {
+			// reacting again with the same reaction takes it away
+			message.Reactions[reaction] = slices.DeleteFunc(message.Reactions[reaction], func(user string) bool { return user == client.Account_info.Username })
+			if len(message.Reactions[reaction]) == 0 {
+				delete(message.Reactions, reaction)
+			}
+			cpack.Arguments = []byte("Removed your " + reaction + " from message #" + strconv.Itoa(message_id))
+			cpack.Successful = true
+		} else if len(message.Reactions) >= MAX_REACTIONS && message.Reactions[reaction] == nil {
+			cpack.Arguments = []byte("Message #" + strconv.Itoa(message_id) + " already has too many different reactions")
+		} else {
+			if message.Reactions == nil {
+				message.Reactions = make(map[string][]string)
+			}
+			message.Reactions[reaction] = append(message.Reactions[reaction], client.Account_info.Username)
+			cpack.Arguments = []byte("Reacted to message #" + strconv.Itoa(message_id) + " with " + reaction)
+			cpack.Successful = true
+		}
+
+		// copying the reactions so they can be sent and archived after the mutex is released
+		if cpack.Successful {
+			reactions := copy_message(*message).Reactions
+			reacted = Data_packet{Type: REACTED, Message_id: message_id, Reactions: reactions}
+			archived = Archived_message{Message_id: message_id, Channel: client.Current_channel, Username: message.Username, Text: message.Data, Sent: message.Sent, Edited: message.Edited, Parent_id: message.Parent_id, Reactions: reactions}
+		}
+		users := append([]int(nil), channel.Users...)
+		channels_mutex.Unlock()
+
+		// updating the reactions for everyone in the channel and in the archive so they are kept when scrolling back
+		if cpack.Successful {
+			broadcast_data_packet(reacted, users, -1)
+			archive_message(archived)
 		}
 	}
+
+	send_command_packet(cpack, client)
 }
 
 /*
- * Executes a command
+ * This function copies a message from the history of a channel so it can be sent after the channels mutex is released
  */
-func execute_command(command_packet Command_packet, client Client) bool {
-	// parsing the command
-	command := parse_command(command_packet)
-
-	fmt.Printf("system: Recieved command of type \"%d\" with %d arguments from client #%d\n", command.Type, len(command.Args), client.Id)
+func copy_message(packet Data_packet) Data_packet {
+	reactions := packet.Reactions
+	packet.Reactions = nil
+	for reaction, users := range reactions {
+		if packet.Reactions == nil {
+			packet.Reactions = make(map[string][]string, len(reactions))
+		}
+		packet.Reactions[reaction] = slices.Clone(users)
+	}
+	return packet
+}
 
-	// switching on the command type
-	switch command.Type {
-	case HELP:
-		fmt.Println("system: Running help command")
-		help_command(client, command)
-	case MAIN:
-		fmt.Println("system: Running main command")
-		main_command(client, command)
-	case EXIT:
-		fmt.Println("system: Running exit command")
-		exit_command(client)
-		return true
-	case LOG_OUT:
-	case LIST_C:
-	case LIST_S:
-	case DISCONNECT_C:
-	case DISCONNECT_S:
-	case BAN_C:
-	case BAN_S:
-		fmt.Println("system: Running ban-s command")
-		ban_s_command(client, command)
-	case CREATE:
-		fmt.Println("system: Running create command")
-		create_command(client, command)
-	case DELETE:
-	case CHANGE_TOPIC:
-		fmt.Println("system: Running change-topic command")
-		change_topic_command(client, command)
-	case ADD_MOD:
-		fmt.Println("system: Running add-mod command")
-		add_mod_command(client, command)
-	case RM_MOD:
-		fmt.Println("system: Running rm-mod command")
-		rm_mod_command(client, command)
-	default:
-		custom_error_exit(UNKNOWN)
+/*
+ * This function checks if a reaction is a shortcode like :+1: or an emoji
+ */
+func is_valid_reaction(reaction string) bool {
+	if len(reaction) > MAX_REACTION_LENGTH {
+		return false
+	} else if reaction_regex.MatchString(reaction) {
 		return true
 	}
-	return false
+
+	// emoji are made of characters outside of ascii
+	for _, character := range reaction {
+		if character < unicode.MaxASCII || character == utf8.RuneError || unicode.IsSpace(character) {
+			return false
+		}
+	}
+	return utf8.ValidString(reaction)
 }
 
 /*
- * This function parses a command packet into a command
+ * This function gives out the next message id
  */
-func parse_command(command_packet Command_packet) Parsed_command {
-	// parsing command into an array of tokens
-	args := strings.Split(string(command_packet.Arguments), ":")
+func next_message_id() int {
+	return int(last_message_id.Add(1))
+}
 
-	// creating command struct
-	var command Parsed_command
-	command.Type = command_packet.Type
-	command.Username = command_packet.Username
-	command.Successful = command_packet.Successful
-	command.Message = command_packet.Message
+/*
+ * This function adds a message to the history of a channel, forgetting the oldest message once full.
+ * The channels mutex must be held
+ */
+func add_to_history(channel *Channel, packet Data_packet) {
+	channel.History = append(channel.History, packet)
+	if len(channel.History) > MAX_HISTORY {
+		channel.History = channel.History[len(channel.History)-MAX_HISTORY:]
+	}
+}
 
-	// checking if the command had arguments
-	if len(command_packet.Arguments) > 0 {
-		// adding the arguments to the struct
-		for i := 0; i < len(args); i++ {
-			command.Args = append(command.Args, args[i])
-		}
-	} else {
-		// setting args to nil
-		command.Args = nil
+/*
+ * This function sends a client every message in its channel with an id greater than the one given
+ */
+func send_history(client Client, last_seen string) {
+	// converting string to int
+	last_seen_id, err := strconv.Atoi(last_seen)
+	if err != nil {
+		fmt.Printf("system: Client #%d sent an invalid message id \"%s\"\n", client.Id, last_seen)
+		return
 	}
 
-	return command
+	// gathering the messages the client missed
+	channels_mutex.Lock()
+	packet := build_history_packet(channels[client.Current_channel].History, last_seen_id)
+	channels_mutex.Unlock()
+
+	fmt.Printf("system: Sending missed messages to client #%d\n", client.Id)
+	send_data_packet(packet, client)
 }
 
 /*
- * This function is executes the help command
+ * This function puts the messages of a channel history sent after the given id into one packet, so sending
+ * a whole history takes one place in the outbound queue instead of filling it.
+ * The channels mutex must be held by the caller
  */
-func help_command(client Client, command Parsed_command) {
-	// updating client
-	client = update_client(client)
+func build_history_packet(history []Data_packet, after_id int) Data_packet {
+	messages := make([]Data_packet, 0, len(history))
+	for _, packet := range history {
+		if packet.Message_id > after_id {
+			messages = append(messages, copy_message(packet))
+		}
+	}
 
-	// creating return command packet
-	var cpack Command_packet
-	cpack.Type = HELP
-	cpack.Username = client.Account_info.Username
+	json_data, err := json.Marshal(messages)
+	if err != nil {
+		error_exit(err)
+	}
+	return Data_packet{Type: HISTORY, Data: json_data}
+}
 
-	// checking if an arguemtns were passed to help
-	if len(command.Args) > 0 {
-		cpack.Arguments = []byte("Too many arguments")
-		cpack.Successful = false
-		send_command_packet(cpack, client)
+/*
+ * This function sends a client a page of the archived messages in its channel sent before the one given, oldest first.
+ * A second id can follow the first to get every message down to it, up to MAX_HISTORY_JUMP, for opening a search result.
+ * Muted clients and clients asking too quickly are told to wait, with the id they asked before so they can ask again
+ */
+func send_older_history(client Client, request string) {
+	// converting strings to ints
+	before, down_to, jumping := strings.Cut(request, " ")
+	before_id, err := strconv.Atoi(before)
+	down_to_id := 0
+	if err == nil && jumping {
+		down_to_id, err = strconv.Atoi(down_to)
+	}
+	if err != nil {
+		fmt.Printf("system: Client #%d sent an invalid message id \"%s\"\n", client.Id, request)
 		return
 	}
 
-	cpack.Arguments = []byte("OK")
-	cpack.Successful = true
-	send_command_packet(cpack, client)
-
-	cpack = read_command_packet(client)
-	if string(cpack.Arguments) != "READY" {
-		custom_error_exit(UNEXPECTED_DATA)
+	// checking if the client has been muted for flooding or is reading the archive too quickly
+	if muted, retry_after := is_muted(client); muted {
+		send_data_packet(Data_packet{Type: RATE_LIMITED, Message_id: before_id, Data: []byte("429 muted for flooding - retry after " + format_retry_after(retry_after))}, client)
+		return
+	}
+	if allowed, retry_after := take_token(client, HISTORY_LIMIT); !allowed {
+		send_data_packet(Data_packet{Type: RATE_LIMITED, Message_id: before_id, Data: []byte(rate_limit_message(retry_after))}, client)
+		return
 	}
 
-	// saving current state and then setting state to IN_HELP_SCREEN
-	client = update_client(client)
-	previous_state := client.State
-	update_client_state(client, IN_HELP_SCREEN)
-	client.State = IN_HELP_SCREEN
+	// finding the newest messages of the channel older than the one given
+	archive_mutex.Lock()
+	ids := channel_archive[client.Current_channel]
+	end, _ := slices.BinarySearch(ids, before_id)
+	start := end - HISTORY_PAGE_SIZE
+	if jumping {
+		position, _ := slices.BinarySearch(ids[:end], down_to_id)
+		start = max(min(start, position), end-MAX_HISTORY_JUMP)
+	}
+	ids = ids[max(start, 0):end]
 
-	// sending packet with user's role
-	cpack = Command_packet{Type: HELP, Username: client.Account_info.Username, Arguments: []byte(strconv.Itoa(client.Account_info.Role))}
-	send_command_packet(cpack, client)
+	older := make([]Data_packet, 0, len(ids))
+	for _, id := range ids {
+		message := archive[id]
+		older = append(older, Data_packet{Type: MESSAGE, Username: message.Username, Data: message.Text, Message_id: id, Sent: message.Sent, Edited: message.Edited, Parent_id: message.Parent_id, Reactions: message.Reactions})
+	}
+	archive_mutex.Unlock()
 
-	// reading packet from client
-	cpack = read_command_packet(client)
+	// finding the users the messages mention so they are still highlighted
+	channels_mutex.Lock()
+	for index := range older {
+		older[index].Mentions = find_mentions(string(older[index].Data), older[index].Username, channels[client.Current_channel])
+	}
+	channels_mutex.Unlock()
 
-	// checking if data is expected keyword
-	if string(string(cpack.Arguments)) != "DONE" {
-		custom_error_exit(UNEXPECTED_DATA)
+	json_data, err := json.Marshal(older)
+	if err != nil {
+		error_exit(err)
 	}
 
-	// restoring state prior to command
-	update_client_state(client, previous_state)
+	fmt.Printf("system: Sending %d older messages to client #%d\n", len(older), client.Id)
+
+	// an empty page tells the client it has reached the start of the channel
+	send_data_packet(Data_packet{Type: OLDER_HISTORY, Message_id: before_id, Data: json_data}, client)
 }
 
 /*
- * This function handles the client exiting
+ * This function starts a new session for an account on the client's device and sends the client its token.
+ * Any older session of the account on the same device is revoked
  */
-func exit_command(client Client) {
-	// udpating client status to quitting
-	update_client_state(client, QUITTING)
+func send_session_token(client Client, username string) {
+	// generating random session id
+	random_bytes := make([]byte, SESSION_ID_SIZE)
+	if _, err := rand.Read(random_bytes); err != nil {
+		error_exit(err)
+	}
+	session_id := hex.EncodeToString(random_bytes)
 
-	// updating client to quitting
-	disconnect_client(client)
+	// a device only ever has one session per account
+	client = update_client(client)
+	revoke_sessions(username, client.device, false)
+
+	issue_session_token(client, session_id, username)
 }
 
-func disconnect_client(client Client) {
-	// updating the client's state to quitting
-	update_client_state(client, QUITTING)
+/*
+ * This function stores a session, signs a token for it and sends the token to the client
+ */
+func issue_session_token(client Client, session_id string, username string) {
+	expires := time.Now().Add(SESSION_TOKEN_LIFETIME)
 
-	// informing client that the state has been changed
-	var cpack Command_packet
-	cpack.Type = EXIT
-	cpack.Username = client.Account_info.Username
-	cpack.Arguments = []byte("READY")
-	send_command_packet(cpack, client)
+	// storing session
+	sessions_mutex.Lock()
+	sessions[session_id] = Session{Username: username, Device: client.device, Expires: expires}
+	sessions_mutex.Unlock()
+	save_sessions()
 
-	cpack = read_command_packet(client)
-	if client.Current_channel > -1 {
-		leave_channel(client)
+	active_clients_mutex.Lock()
+	active_clients[client.Id].token_id = session_id
+	active_clients_mutex.Unlock()
+
+	token := sign_session_token(Token_claims{Session_id: session_id, Username: username, Expires: expires.Unix()})
+	send_data_packet(Data_packet{Type: SESSION_TOKEN, Username: username, Data: []byte(token)}, client)
+}
+
+/*
+ * This function signs the claims of a session token.
+ * A token is the base64 encoded claims followed by a dot and the base64 encoded signature of the claims
+ */
+func sign_session_token(claims Token_claims) string {
+	json_data, err := json.Marshal(claims)
+	if err != nil {
+		error_exit(err)
 	}
+	payload := base64.RawURLEncoding.EncodeToString(json_data)
 
-	time.Sleep(5 * time.Second)
+	mac := hmac.New(sha256.New, session_secret)
+	mac.Write([]byte(payload))
 
-	sub_client(client)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
 }
 
 /*
- * This function handles the creat command
+ * This function checks the signature and expiry of a session token and returns its claims
  */
-func create_command(client Client, command Parsed_command) {
-	// updating client struct
-	client = update_client(client)
+func verify_session_token(token string) (Token_claims, bool) {
+	var claims Token_claims
 
-	// creating command packet to send to client
-	var cpack Command_packet
+	// splitting token into claims and signature
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return claims, false
+	}
+
+	// checking signature
+	given, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return claims, false
+	}
+	mac := hmac.New(sha256.New, session_secret)
+	mac.Write([]byte(payload))
+	if !hmac.Equal(given, mac.Sum(nil)) {
+		return claims, false
+	}
+
+	// reading claims
+	json_data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(json_data, &claims) != nil {
+		return claims, false
+	}
+
+	// checking expiry
+	if time.Now().Unix() >= claims.Expires {
+		return claims, false
+	}
+
+	return claims, true
+}
+
+/*
+ * This function resumes the session of a client that connected with a session token, either after reconnecting or
+ * because the client remembered it. The client is put in the main menu and given a fresh token if the token is valid
+ */
+func resume_session(client Client, token string) {
+	// checking token and looking up its session
+	claims, valid := verify_session_token(token)
+	sessions_mutex.Lock()
+	session, exists := sessions[claims.Session_id]
+	sessions_mutex.Unlock()
+
+	// checking that the account still exists and may log in
+	index := -1
+	if valid && exists && session.Username == claims.Username {
+		index = get_user_index(session.Username)
+	}
+	locked, _ := is_locked_out(session.Username, client.ip_address)
+	if index == -1 || is_banned(index) || locked {
+		fmt.Printf("system: Client #%d could not resume its session\n", client.Id)
+		send_data_packet(Data_packet{Type: DENY, Data: []byte("Your session has expired. Please log in again")}, client)
+		return
+	}
+
+	// dropping the old connection of this session if the server has not noticed it is gone yet
+	active_clients_mutex.Lock()
+	var stale []Client
+	for _, other := range active_clients {
+		if other.Id != -1 && other.Id != client.Id && other.token_id == claims.Session_id {
+			stale = append(stale, *other)
+		}
+	}
+	active_clients_mutex.Unlock()
+	for _, other := range stale {
+		drop_client(other, "session resumed elsewhere")
+	}
+
+	// restoring the client's account details
+	registered_accounts_mutex.Lock()
+	role := registered_accounts[index].Role
+	registered_accounts_mutex.Unlock()
+
+	active_clients_mutex.Lock()
+	active_clients[client.Id].Account_info.Username = session.Username
+	active_clients[client.Id].Account_info.Role = role
+	active_clients[client.Id].Logged_in = true
+	active_clients[client.Id].State = IN_MAIN_MENU
+	client = *active_clients[client.Id]
+	active_clients_mutex.Unlock()
+
+	audit_log("resumed session for account " + session.Username + " on " + client.device + " from " + client.ip_address)
+	send_data_packet(Data_packet{Type: ACCEPT, Username: session.Username, Data: []byte("Session resumed")}, client)
+
+	// replacing the token so the session stays valid while it is being used
+	issue_session_token(client, claims.Session_id, session.Username)
+
+	// handing over direct messages sent while the user was offline
+	deliver_pending_direct_messages(client, session.Username)
+}
+
+/*
+ * This function revokes the sessions of an account on a device, or on every device if every_device is set.
+ * It returns the number of sessions revoked
+ */
+func revoke_sessions(username string, device string, every_device bool) int {
+	sessions_mutex.Lock()
+	revoked := 0
+	for session_id, session := range sessions {
+		if session.Username == username && (every_device || session.Device == device) {
+			delete(sessions, session_id)
+			revoked++
+		}
+	}
+	sessions_mutex.Unlock()
+
+	if revoked > 0 {
+		save_sessions()
+	}
+
+	return revoked
+}
+
+/*
+ * This function disconnects the clients signed in to an account on a device, or on every device if every_device is set.
+ * The calling client is left connected
+ */
+func drop_sessions(client Client, username string, device string, every_device bool) {
+	// gathering clients to drop
+	active_clients_mutex.Lock()
+	var signed_in []Client
+	for _, other := range active_clients {
+		if other.Id != -1 && other.Id != client.Id && other.Account_info.Username == username && (every_device || other.device == device) {
+			signed_in = append(signed_in, *other)
+		}
+	}
+	active_clients_mutex.Unlock()
+
+	for _, other := range signed_in {
+		drop_client(other, "signed out by "+username)
+	}
+}
+
+/*
+ * This function loads the key used to sign session tokens, creating it the first time the server runs
+ */
+func load_session_secret() {
+	secret, err := os.ReadFile(SESSION_SECRET_PATH)
+	if err == nil {
+		session_secret = secret
+		return
+	}
+	if !os.IsNotExist(err) {
+		error_exit(err)
+	}
+
+	// generating new key
+	session_secret = make([]byte, SESSION_SECRET_SIZE)
+	if _, err := rand.Read(session_secret); err != nil {
+		error_exit(err)
+	}
+
+	// only the server should be able to read the key
+	if err := os.WriteFile(SESSION_SECRET_PATH, session_secret, 0600); err != nil {
+		error_exit(err)
+	}
+
+	msg := GREEN + " - created session signing key\n" + RESET
+	fmt.Print(msg)
+}
+
+/*
+ * This function loads the saved sessions, skipping any that have expired
+ */
+func load_sessions() {
+	json_data, err := os.ReadFile(SESSIONS_PATH)
+	if err != nil {
+		// there are no sessions to load the first time the server runs
+		if os.IsNotExist(err) {
+			return
+		}
+		error_exit(err)
+	}
+
+	sessions_mutex.Lock()
+	defer sessions_mutex.Unlock()
+
+	// unmarshaling json data
+	if err := json.Unmarshal(json_data, &sessions); err != nil {
+		error_exit(err)
+	}
+
+	// forgetting expired sessions
+	for token, session := range sessions {
+		if time.Now().After(session.Expires) {
+			delete(sessions, token)
+		}
+	}
+
+	msg := GREEN + " - loaded sessions\n" + RESET
+	fmt.Print(msg)
+}
+
+/*
+ * This function saves the sessions so they can still be resumed after the server restarts
+ */
+func save_sessions() {
+	sessions_mutex.Lock()
+	json_data, err := json.Marshal(sessions)
+	sessions_mutex.Unlock()
+	if err != nil {
+		error_exit(err)
+	}
+
+	// only the server should be able to read the tokens
+	if err := os.WriteFile(SESSIONS_PATH, json_data, 0600); err != nil {
+		error_exit(err)
+	}
+}
+
+/*
+ * This function gets the ip address of a connection without the port
+ */
+func get_ip_address(connection net.Conn) string {
+	host, _, err := net.SplitHostPort(connection.RemoteAddr().String())
+	if err != nil {
+		return connection.RemoteAddr().String()
+	}
+	return host
+}
+
+/*
+ * This function creates a rate limiter with full buckets
+ */
+func new_rate_limiter(multiplier float64) *Rate_limiter {
+	limiter := &Rate_limiter{multiplier: multiplier}
+
+	// filling every bucket
+	now := time.Now()
+	for kind := range limiter.buckets {
+		limiter.buckets[kind] = Token_bucket{tokens: rate_limits[kind].Burst * multiplier, last_refill: now}
+	}
+
+	return limiter
+}
+
+/*
+ * This function gets the rate limiter of an ip address and creates it if it does not exist yet
+ */
+func get_ip_rate_limiter(ip_address string) *Rate_limiter {
+	ip_limiters_mutex.Lock()
+	defer ip_limiters_mutex.Unlock()
+
+	limiter, exists := ip_limiters[ip_address]
+	if !exists {
+		prune_ip_limiters()
+		limiter = new_rate_limiter(IP_LIMIT_MULTIPLIER)
+		ip_limiters[ip_address] = limiter
+	}
+
+	return limiter
+}
+
+/*
+ * This function forgets the limiters of ip addresses that would be no different from a new limiter,
+ * so the map does not grow with every address that ever connected. It looks at most once every IP_LIMITER_PRUNE.
+ * The ip limiters mutex must be held by the caller
+ */
+func prune_ip_limiters() {
+	now := time.Now()
+	if now.Sub(ip_limiters_pruned) < IP_LIMITER_PRUNE {
+		return
+	}
+	ip_limiters_pruned = now
+
+	for ip_address, limiter := range ip_limiters {
+		limiter.mutex.Lock()
+		idle := now.After(limiter.muted_until) && (len(limiter.violations) == 0 || now.Sub(limiter.violations[len(limiter.violations)-1]) >= VIOLATION_WINDOW)
+		for kind := range limiter.buckets {
+			refill_bucket(limiter, kind, now)
+			idle = idle && limiter.buckets[kind].tokens >= rate_limits[kind].Burst*limiter.multiplier
+		}
+		limiter.mutex.Unlock()
+
+		if idle {
+			delete(ip_limiters, ip_address)
+		}
+	}
+}
+
+/*
+ * This function reads changes to how often clients are pinged and how long they may stay silent.
+ * Clients must be pinged more often than they time out, or every client would be dropped
+ */
+func load_heartbeat_settings() {
+	if interval := os.Getenv(HEARTBEAT_INTERVAL_ENV); interval != "" {
+		duration, err := time.ParseDuration(interval)
+		if err != nil || duration <= 0 {
+			error_exit(fmt.Errorf("invalid duration \"%s\" in %s", interval, HEARTBEAT_INTERVAL_ENV))
+		}
+		heartbeat_interval = duration
+	}
+
+	if timeout := os.Getenv(HEARTBEAT_TIMEOUT_ENV); timeout != "" {
+		duration, err := time.ParseDuration(timeout)
+		if err != nil || duration <= 0 {
+			error_exit(fmt.Errorf("invalid duration \"%s\" in %s", timeout, HEARTBEAT_TIMEOUT_ENV))
+		}
+		heartbeat_timeout = duration
+	}
+
+	if heartbeat_timeout <= heartbeat_interval {
+		error_exit(fmt.Errorf("%s must be longer than %s", HEARTBEAT_TIMEOUT_ENV, HEARTBEAT_INTERVAL_ENV))
+	}
+
+	msg := GREEN + " - pinging clients every " + heartbeat_interval.String() + ", dropping them after " + heartbeat_timeout.String() + " of silence\n" + RESET
+	fmt.Print(msg)
+}
+
+/*
+ * This function reads changes to the rate limits from RATE_LIMITS_ENV. Each limit is written as
+ * name=rate/burst, where rate is the tokens added each second, and limits are separated by commas
+ */
+func load_rate_limits() {
+	config := os.Getenv(RATE_LIMITS_ENV)
+	if config == "" {
+		return
+	}
+
+	for _, setting := range strings.Split(config, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(setting), "=")
+		rate_text, burst_text, _ := strings.Cut(value, "/")
+		kind := slices.Index(rate_limit_names[:], name)
+		rate, rate_err := strconv.ParseFloat(rate_text, 64)
+		burst, burst_err := strconv.ParseFloat(burst_text, 64)
+		if kind == -1 || rate_err != nil || burst_err != nil || rate <= 0 || burst < 1 {
+			error_exit(fmt.Errorf("invalid rate limit \"%s\" in %s, limits look like message=1/5", setting, RATE_LIMITS_ENV))
+		}
+		rate_limits[kind] = Rate_limit{Rate: rate, Burst: burst}
+	}
+
+	msg := GREEN + " - loaded rate limits from " + RATE_LIMITS_ENV + "\n" + RESET
+	fmt.Print(msg)
+}
+
+/*
+ * This function adds the tokens earned since the last refill to a bucket.
+ * The limiter must be locked by the caller
+ */
+func refill_bucket(limiter *Rate_limiter, kind int, now time.Time) {
+	bucket := &limiter.buckets[kind]
+	rate := rate_limits[kind].Rate * limiter.multiplier
+	burst := rate_limits[kind].Burst * limiter.multiplier
+
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last_refill).Seconds()*rate)
+	bucket.last_refill = now
+}
+
+/*
+ * This function calculates how long until a bucket has a full token.
+ * The limiter must be locked by the caller
+ */
+func time_until_token(limiter *Rate_limiter, kind int) time.Duration {
+	missing := 1 - limiter.buckets[kind].tokens
+	if missing <= 0 {
+		return 0
+	}
+
+	rate := rate_limits[kind].Rate * limiter.multiplier
+	return time.Duration(missing / rate * float64(time.Second))
+}
+
+/*
+ * This function checks if a client may do an action of the given kind.
+ * A token is only taken when both the client's bucket and the bucket of its ip address have one.
+ * If not, the violation is recorded and the time the client should wait is returned
+ */
+func take_token(client Client, kind int) (bool, time.Duration) {
+	if client.limiter == nil {
+		return true, 0
+	}
+
+	ip_limiter := get_ip_rate_limiter(client.ip_address)
+	now := time.Now()
+
+	client.limiter.mutex.Lock()
+	defer client.limiter.mutex.Unlock()
+	ip_limiter.mutex.Lock()
+	defer ip_limiter.mutex.Unlock()
+
+	refill_bucket(client.limiter, kind, now)
+	refill_bucket(ip_limiter, kind, now)
+
+	// checking if both buckets have a token
+	if client.limiter.buckets[kind].tokens >= 1 && ip_limiter.buckets[kind].tokens >= 1 {
+		client.limiter.buckets[kind].tokens--
+		ip_limiter.buckets[kind].tokens--
+		return true, 0
+	}
+
+	fmt.Printf("system: Client #%d (%s) hit a rate limit of kind %d\n", client.Id, client.ip_address, kind)
+
+	// recording violation and muting persistent abusers
+	if record_violation(client.limiter, now) {
+		fmt.Printf("system: Muted client #%d for flooding\n", client.Id)
+	}
+	if record_violation(ip_limiter, now) {
+		fmt.Printf("system: Muted ip address %s for flooding\n", client.ip_address)
+	}
+
+	return false, max(time_until_token(client.limiter, kind), time_until_token(ip_limiter, kind))
+}
+
+/*
+ * This function records a rate limit violation and mutes the client or ip address once
+ * it has MUTE_THRESHOLD violations within VIOLATION_WINDOW.
+ * It returns true if this violation caused a mute. The limiter must be locked by the caller
+ */
+func record_violation(limiter *Rate_limiter, now time.Time) bool {
+	// dropping violations that are outside of the window
+	var recent []time.Time
+	for _, violation := range limiter.violations {
+		if now.Sub(violation) < VIOLATION_WINDOW {
+			recent = append(recent, violation)
+		}
+	}
+	limiter.violations = append(recent, now)
+
+	// checking if the threshold was reached
+	if len(limiter.violations) < MUTE_THRESHOLD || now.Before(limiter.muted_until) {
+		return false
+	}
+
+	limiter.muted_until = now.Add(MUTE_DURATION)
+	limiter.violations = nil
+	return true
+}
+
+/*
+ * This function checks if a client or its ip address is muted and returns how long the mute lasts
+ */
+func is_muted(client Client) (bool, time.Duration) {
+	if client.limiter == nil {
+		return false, 0
+	}
+
+	now := time.Now()
+	remaining := time.Duration(0)
+
+	// checking the client's mute
+	client.limiter.mutex.Lock()
+	remaining = max(remaining, client.limiter.muted_until.Sub(now))
+	client.limiter.mutex.Unlock()
+
+	// checking the ip address's mute
+	ip_limiter := get_ip_rate_limiter(client.ip_address)
+	ip_limiter.mutex.Lock()
+	remaining = max(remaining, ip_limiter.muted_until.Sub(now))
+	ip_limiter.mutex.Unlock()
+
+	return remaining > 0, remaining
+}
+
+/*
+ * This function creates the message sent to a client that hit a rate limit
+ */
+func rate_limit_message(retry_after time.Duration) string {
+	return "429 slow down - retry after " + format_retry_after(retry_after)
+}
+
+/*
+ * This function formats a retry after duration in whole seconds
+ */
+func format_retry_after(retry_after time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(retry_after.Seconds()))) + "s"
+}
+
+/*
+ * This function checks to see if a username is already taken
+ */
+func name_is_exists(username string) (int, bool) {
+	registered_accounts_mutex.Lock()
+	defer registered_accounts_mutex.Unlock()
+
+	// looping over exising accounts
+	for index, current_account := range registered_accounts {
+		// checking if username exists
+		if current_account.Username == username {
+			return index, true
+		}
+	}
+
+	// looping over active clients
+	for index, current_user := range active_clients {
+		if current_user.Account_info.Username == username {
+			return index, true
+		}
+	}
+
+	return -1, false
+}
+
+/*
+ * This function handles closing the server is a predefined error occures
+ */
+func error_exit(err error) {
+	fmt.Println("system: ERROR -", err)
+	fmt.Println("system: shutting down server...")
+
+	// TODO
+	os.Exit(1)
+}
+
+/*
+ * This function handles exiting the server if a custom error occurs
+ */
+func custom_error_exit(err int) {
+	switch err {
+	case OUT_OF_SYNC:
+		fmt.Println("system: ERROR - Server and client out of sync")
+	case UNEXPECTED_DATA:
+		fmt.Println("system: ERROR - Unexpected data found in packet")
+	case UNKNOWN:
+		fmt.Println("system: ERROR - An unknown error occured")
+	}
+
+	fmt.Println("system: Shutting down...")
+	// TODO
+	os.Exit(1)
+}
+
+/*
+ * This function handles removing a client from the active clients list
+ */
+func sub_client(client Client) {
+	fmt.Println("system: Disconnecting client...")
+
+	decrement_num_of_active_clients()
+
+	active_clients_mutex.Lock()
+	defer active_clients_mutex.Unlock()
+
+	active_clients[client.Id].data_sock = nil
+	active_clients[client.Id].command_sock = nil
+	active_clients[client.Id].Account_info.Username = ""
+	active_clients[client.Id].Account_info.Password = ""
+	active_clients[client.Id].State = CHOOSING_SIGN_IN_OPT
+	active_clients[client.Id].Id = -1
+	active_clients[client.Id].Logged_in = false
+	active_clients[client.Id].Account_info.Role = 0
+	active_clients[client.Id].Current_channel = -1
+	active_clients[client.Id].command_reader = nil
+	active_clients[client.Id].data_reader = nil
+	active_clients[client.Id].outbound = nil
+	active_clients[client.Id].command_queue = nil
+	active_clients[client.Id].limiter = nil
+	active_clients[client.Id].ip_address = ""
+	active_clients[client.Id].session_id = 0
+	active_clients[client.Id].device = ""
+	active_clients[client.Id].token_id = ""
+
+	// stopping the client's writer routines
+	close_outbound_queue(client.outbound)
+	close_outbound_queue(client.command_queue)
+
+	client.command_sock.Close()
+	client.data_sock.Close()
+}
+
+/*
+ * This function decrements the number of active clients
+ */
+func decrement_num_of_active_clients() {
+	num_of_active_clients_mutex.Lock()
+	defer num_of_active_clients_mutex.Unlock()
+
+	num_of_active_clients--
+}
+
+/*
+ * This function checks if an account has another session in a channel.
+ * The channels mutex must be held
+ */
+func has_other_session_in_channel(client Client, channel_id int) bool {
+	active_clients_mutex.Lock()
+	defer active_clients_mutex.Unlock()
+
+	for _, user := range channels[channel_id].Users {
+		if user != client.Id && active_clients[user].Account_info.Username == client.Account_info.Username {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * This function lists a group of clients with every account named once,
+ * followed by the number of sessions if it has more than one
+ */
+func list_accounts(users []Client) string {
+	// counting sessions of each account
+	var names []string
+	sessions_per_account := make(map[string]int)
+	for _, user := range users {
+		if sessions_per_account[user.Account_info.Username] == 0 {
+			names = append(names, user.Account_info.Username)
+		}
+		sessions_per_account[user.Account_info.Username]++
+	}
+	slices.Sort(names)
+
+	// building list
+	var entries []string
+	for _, name := range names {
+		if sessions_per_account[name] > 1 {
+			entries = append(entries, name+" ("+strconv.Itoa(sessions_per_account[name])+" sessions)")
+		} else {
+			entries = append(entries, name)
+		}
+	}
+	return strings.Join(entries, ", ")
+}
+
+/*
+ * This function updates the state of a client
+ */
+func update_client_state(client Client, state int) Client {
+	active_clients_mutex.Lock()
+	active_clients[client.Id].State = state
+	active_clients_mutex.Unlock()
+	client.State = state
+	return client
+}
+
+/*
+ * This function queues a packet to be sent to a specified client
+ */
+func send_data_packet(packet Data_packet, client Client) {
+	// marshaling data
+	json_data := marshal_packet(packet)
+
+	// queueing packet for the client's writer routine
+	enqueue_packet(client.outbound, json_data, client.Id)
+}
+
+/*
+ * This function sends a packet to a list of clients, skipping the client with the excluded id.
+ * The recipients are gathered while holding the lock and the packet is queued after releasing it
+ * so that no network I/O happens while a global lock is held
+ */
+func broadcast_data_packet(packet Data_packet, users []int, excluded_id int) {
+	// marshaling data once for all recipients
+	json_data := marshal_packet(packet)
+
+	// gathering the queues of the recipients
+	var queues []*Outbound_queue
+	var ids []int
+	active_clients_mutex.Lock()
+	for _, user := range users {
+		if user != excluded_id {
+			queues = append(queues, active_clients[user].outbound)
+			ids = append(ids, user)
+		}
+	}
+	active_clients_mutex.Unlock()
+
+	// queueing packet for each recipient
+	for index, queue := range queues {
+		enqueue_packet(queue, json_data, ids[index])
+	}
+}
+
+/*
+ * This function creates an outbound queue for a socket and starts its writer routine
+ */
+func create_outbound_queue(connection net.Conn) *Outbound_queue {
+	queue := &Outbound_queue{
+		packets: make(chan []byte, OUTBOUND_QUEUE_SIZE),
+		done:    make(chan struct{}),
+	}
+
+	// starting routine to write queued packets
+	go write_outbound_packets(queue, connection)
+
+	return queue
+}
+
+/*
+ * This function adds a packet to an outbound queue without blocking.
+ * If the queue is full the packet is dropped so a slow client cannot stall the sender
+ */
+func enqueue_packet(queue *Outbound_queue, json_data []byte, client_id int) {
+	if queue == nil {
+		return
+	}
+
+	select {
+	case queue.packets <- json_data:
+	default:
+		dropped := queue.dropped.Add(1)
+		fmt.Printf("system: Outbound queue of client #%d is full, dropped packet (%d dropped so far)\n", client_id, dropped)
+	}
+}
+
+/*
+ * This function writes the packets in an outbound queue to a connection until the queue is closed
+ */
+func write_outbound_packets(queue *Outbound_queue, connection net.Conn) {
+	for {
+		select {
+		case json_data := <-queue.packets:
+			// making sure a stuck client cannot hold this routine forever
+			connection.SetWriteDeadline(time.Now().Add(WRITE_TIMEOUT))
+			if write_to_connection(connection, json_data) {
+				queue.dropped.Store(0)
+			}
+		case <-queue.done:
+			return
+		}
+	}
+}
+
+/*
+ * This function pings a client on an interval and drops it once it stops
+ * answering or has fallen too far behind on reading its data socket
+ */
+func send_heartbeats(client Client) {
+	ticker := time.NewTicker(heartbeat_interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// checking if the client is still connected
+		current, same_session := update_session(client)
+		if !same_session {
+			return
+		}
+
+		// checking if the client stopped answering
+		if time.Since(current.last_heard) > heartbeat_timeout {
+			drop_client(client, "heartbeat timed out")
+			return
+		}
+
+		// checking if the client stopped reading
+		if current.outbound.dropped.Load() >= MAX_DROPPED_PACKETS || current.command_queue.dropped.Load() >= MAX_DROPPED_PACKETS {
+			drop_client(client, "too slow to keep up with its packets")
+			return
+		}
+
+		// pinging client through its queue so a client that stopped reading cannot hold this routine
+		queue_command_packet(Command_packet{Type: PING}, current)
+	}
+}
+
+/*
+ * This function records that a client was heard from
+ */
+func record_heartbeat(client Client) {
+	active_clients_mutex.Lock()
+	defer active_clients_mutex.Unlock()
+
+	if active_clients[client.Id].session_id == client.session_id {
+		active_clients[client.Id].last_heard = time.Now()
+	}
+}
+
+/*
+ * This function cleans up after a client that disappeared without exiting.
+ * It removes the client from its channel and frees its slot
+ */
+func drop_client(client Client, reason string) {
+	// making sure the client is only cleaned up once
+	active_clients_mutex.Lock()
+	current := active_clients[client.Id]
+	if current.session_id != client.session_id || current.State == QUITTING {
+		active_clients_mutex.Unlock()
+		return
+	}
+	current.State = QUITTING
+	client = *current
+	active_clients_mutex.Unlock()
+
+	fmt.Printf("system: Dropping client #%d - %s\n", client.Id, reason)
+
+	// removing client from its channel
+	if client.Current_channel > -1 {
+		leave_channel(client)
+	}
+
+	// freeing the client's slot and closing its sockets
+	sub_client(client)
+}
+
+/*
+ * This function stops the writer routine of an outbound queue
+ */
+func close_outbound_queue(queue *Outbound_queue) {
+	if queue == nil {
+		return
+	}
+
+	close(queue.done)
+}
+
+/*
+ * This function reads a packet from a client
+ */
+func read_data_packet(client Client) Data_packet {
+	json_data, amount_read := read_from_connection(client.data_reader)
+	var packet Data_packet
+
+	// checking if the connection was closed so the current function returns
+	if amount_read < 0 {
+		drop_client(client, "data connection closed")
+		packet.Type = CLOSE
+		return packet
+	}
+
+	// checking if the packet is empty
+	if amount_read > 0 {
+		// unmarshaling json packet
+		packet = unmarshal_packet(json_data[:amount_read])
+	}
+
+	return packet
+}
+
+/*
+ * This function sends a packet to a specified client
+ */
+func send_command_packet(packet Command_packet, client Client) {
+	// marshaling data
+	json_data := marshal_command_packet(packet)
+
+	// sending packet, a client that stopped reading only holds its own routine until the deadline
+	if client.command_sock != nil {
+		client.command_sock.SetWriteDeadline(time.Now().Add(WRITE_TIMEOUT))
+	}
+	write_to_connection(client.command_sock, json_data)
+}
+
+/*
+ * This function queues a packet to be sent to a specified client on its command socket. Packets sent from
+ * routines other than the client's own go through the queue so the client cannot stall them
+ */
+func queue_command_packet(packet Command_packet, client Client) {
+	// marshaling data
+	json_data := marshal_command_packet(packet)
+
+	// queueing packet for the client's command writer routine
+	enqueue_packet(client.command_queue, json_data, client.Id)
+}
+
+/*
+ * This function reads a packet from a client
+ */
+func read_command_packet(client Client) Command_packet {
+	var packet Command_packet
+
+	// looping until a packet that is not a heartbeat arrives
+	for {
+		json_data, amount_read := read_from_connection(client.command_reader)
+
+		// checking if the connection was closed
+		if amount_read <= 0 {
+			packet.Type = -1
+			return packet
+		}
+
+		// recording that the client is still there
+		record_heartbeat(client)
+
+		// unmarshaling json packet
+		packet = unmarshal_command_packet(json_data[:amount_read])
+		if packet.Type == PONG {
+			continue
+		}
+
+		fmt.Printf("server: Recieved packet from client: %d\n", client.Id)
+		fmt.Printf("server: \"%s\"\n", string(json_data))
+
+		return packet
+	}
+}
+
+/*
+ * This function writes to a specified net.Conn
+ */
+func write_to_connection(connection net.Conn, data []byte) bool {
+	if connection == nil {
+		return false
+	}
+
+	_, err := connection.Write(data)
+	if err != nil {
+		fmt.Println("system: Failed to write to socket")
+		return false
+	}
+	return true
+}
+
+/*
+ * This function reads a single packet from the reader of a connection.
+ * Packets longer than MAX_PACKET_LENGTH are treated like a closed connection so they cannot fill the server's memory
+ */
+func read_from_connection(reader *bufio.Reader) ([]byte, int) {
+	if reader == nil {
+		return nil, -1
+	}
+
+	// reading packet from user up to the delimiter, a buffer at a time
+	var data []byte
+	for {
+		part, err := reader.ReadSlice(PACKET_DELIMITER)
+		if len(data)+len(part) > MAX_PACKET_LENGTH {
+			fmt.Println("system: Packet over " + strconv.Itoa(MAX_PACKET_LENGTH) + " bytes, closing connection")
+			return nil, -1
+		}
+		data = append(data, part...)
+
+		if err == bufio.ErrBufferFull {
+			continue
+		} else if err != nil {
+			fmt.Println("system: Failed to read from socket")
+			return nil, -1
+		}
+		return data, len(data)
+	}
+}
+
+/*
+ * This function marshals a packet into a json file
+ */
+func marshal_packet(packet Data_packet) []byte {
+	// marshaling data
+	json_data, err := json.Marshal(packet)
+	if err != nil {
+		fmt.Println("server: Error marshaling data-", err.Error())
+	}
+
+	// adding delimiter so packets written back to back can be told apart
+	return append(json_data, PACKET_DELIMITER)
+}
+
+/*
+ * This function unmarshals json data into a packetand handles the possible errors
+ */
+func unmarshal_packet(json_data []byte) Data_packet {
+	// unmarshaling json packet
+	//json_data = trim_null_characters(json_data)
+	var packet Data_packet
+	err := json.Unmarshal(json_data, &packet)
+	if err != nil {
+		error_exit(err)
+	}
+	return packet
+}
+
+/*
+ * This function marshals a packet into a json file
+ */
+func marshal_command_packet(packet Command_packet) []byte {
+	// marshaling data
+	json_data, err := json.Marshal(packet)
+	if err != nil {
+		fmt.Println("server: Error marshaling data-", err.Error())
+	}
+
+	// adding delimiter so packets written back to back can be told apart
+	return append(json_data, PACKET_DELIMITER)
+}
+
+/*
+ * This function unmarshals json data into a packetand handles the possible errors
+ */
+func unmarshal_command_packet(json_data []byte) Command_packet {
+	var packet Command_packet
+	err := json.Unmarshal(json_data, &packet)
+	if err != nil {
+		error_exit(err)
+	}
+	return packet
+}
+
+/*
+ * This function handles incoming commands
+ */
+func handle_inbound_commands(client Client) {
+	for {
+		packet := read_command_packet(client)
+		if packet.Type == -1 {
+			drop_client(client, "connection closed")
+			return
+		}
+
+		// direct messages are limited like channel messages rather than like commands
+		limit := COMMAND_LIMIT
+		if packet.Type == MSG || packet.Type == EDIT_MSG || packet.Type == REACT {
+			limit = MESSAGE_LIMIT
+		}
+
+		// checking if the client is sending commands too quickly, exiting is always allowed
+		if packet.Type != EXIT {
+			if allowed, retry_after := take_token(client, limit); !allowed {
+				msg := []byte(rate_limit_message(retry_after))
+				send_command_packet(Command_packet{Type: packet.Type, Username: packet.Username, Arguments: msg, Message: msg}, client)
+				continue
+			}
+		}
+
+		if execute_command(packet, client) {
+			fmt.Println("system: Closing command routine")
+			return
+		}
+	}
+}
+
+/*
+ * Executes a command
+ */
+func execute_command(command_packet Command_packet, client Client) bool {
+	// parsing the command
+	command := parse_command(command_packet)
+
+	fmt.Printf("system: Recieved command of type \"%d\" with %d arguments from client #%d\n", command.Type, len(command.Args), client.Id)
+
+	// switching on the command type
+	switch command.Type {
+	case HELP:
+		fmt.Println("system: Running help command")
+		help_command(client, command)
+	case MAIN:
+		fmt.Println("system: Running main command")
+		main_command(client, command)
+	case EXIT:
+		fmt.Println("system: Running exit command")
+		exit_command(client)
+		return true
+	case LOG_OUT:
+		fmt.Println("system: Running log_out command")
+		log_out_command(client, command)
+	case LIST_C:
+		fmt.Println("system: Running list-c command")
+		list_c_command(client, command)
+	case LIST_S:
+		fmt.Println("system: Running list-s command")
+		list_s_command(client, command)
+	case DISCONNECT_C:
+	case DISCONNECT_S:
+	case BAN_C:
+	case BAN_S:
+		fmt.Println("system: Running ban-s command")
+		ban_s_command(client, command)
+	case CREATE:
+		fmt.Println("system: Running create command")
+		create_command(client, command)
+	case DELETE:
+	case CHANGE_TOPIC:
+		fmt.Println("system: Running change-topic command")
+		change_topic_command(client, command)
+	case ADD_MOD:
+		fmt.Println("system: Running add-mod command")
+		add_mod_command(client, command)
+	case RM_MOD:
+		fmt.Println("system: Running rm-mod command")
+		rm_mod_command(client, command)
+	case LOCKOUTS:
+		fmt.Println("system: Running lockouts command")
+		lockouts_command(client, command)
+	case UNLOCK:
+		fmt.Println("system: Running unlock command")
+		unlock_command(client, command)
+	case LOG_OUT_ALL:
+		fmt.Println("system: Running log_out_all command")
+		log_out_all_command(client, command)
+	case DEVICES:
+		fmt.Println("system: Running devices command")
+		devices_command(client, command)
+	case REVOKE:
+		fmt.Println("system: Running revoke command")
+		revoke_command(client, command)
+	case MSG:
+		fmt.Println("system: Running msg command")
+		msg_command(client, command)
+	case INVITE:
+		fmt.Println("system: Running invite command")
+		invite_command(client, command)
+	case JOIN:
+		fmt.Println("system: Running join command")
+		join_command(client, command)
+	case KICK:
+		fmt.Println("system: Running kick command")
+		kick_command(client, command)
+	case ADD_CHANNEL_MOD:
+		fmt.Println("system: Running add-channel-mod command")
+		add_channel_mod_command(client, command)
+	case RM_CHANNEL_MOD:
+		fmt.Println("system: Running rm-channel-mod command")
+		rm_channel_mod_command(client, command)
+	case ROLES:
+		fmt.Println("system: Running roles command")
+		roles_command(client, command)
+	case CREATE_ROLE:
+		fmt.Println("system: Running create-role command")
+		create_role_command(client, command)
+	case DELETE_ROLE:
+		fmt.Println("system: Running delete-role command")
+		delete_role_command(client, command)
+	case GRANT_ROLE, REVOKE_ROLE:
+		fmt.Println("system: Running grant-role or revoke-role command")
+		assign_role_command(client, command)
+	case EDIT_MSG:
+		fmt.Println("system: Running edit command")
+		edit_command(client, command)
+	case DELETE_MSG:
+		fmt.Println("system: Running delete command")
+		delete_message_command(client, command)
+	case REACT:
+		fmt.Println("system: Running react command")
+		react_command(client, command)
+	case MENTIONS:
+		fmt.Println("system: Running mentions command")
+		mentions_command(client)
+	case SEARCH:
+		fmt.Println("system: Running search command")
+		search_command(client, command)
+	case SEARCH_CONTEXT:
+		fmt.Println("system: Running search context command")
+		search_context_command(client, command)
+	case PIN:
+		fmt.Println("system: Running pin command")
+		pin_command(client, command)
+	case UNPIN:
+		fmt.Println("system: Running unpin command")
+		unpin_command(client, command)
+	case SET_DESCRIPTION:
+		fmt.Println("system: Running set description command")
+		set_description_command(client, command)
+	case UPLOAD:
+		fmt.Println("system: Running upload command")
+		upload_command(client, command)
+	case DOWNLOAD:
+		fmt.Println("system: Running download command")
+		download_command(client, command)
+	default:
+		custom_error_exit(UNKNOWN)
+		return true
+	}
+	return false
+}
+
+/*
+ * This function parses a command packet into a command
+ */
+func parse_command(command_packet Command_packet) Parsed_command {
+	// parsing command into an array of tokens
+	args := strings.Split(string(command_packet.Arguments), ":")
+
+	// creating command struct
+	var command Parsed_command
+	command.Type = command_packet.Type
+	command.Username = command_packet.Username
+	command.Successful = command_packet.Successful
+	command.Message = command_packet.Message
+
+	// checking if the command had arguments
+	if len(command_packet.Arguments) > 0 {
+		// adding the arguments to the struct
+		for i := 0; i < len(args); i++ {
+			command.Args = append(command.Args, args[i])
+		}
+	} else {
+		// setting args to nil
+		command.Args = nil
+	}
+
+	return command
+}
+
+/*
+ * This function is executes the help command
+ */
+func help_command(client Client, command Parsed_command) {
+	// updating client
+	client = update_client(client)
+
+	// creating return command packet
+	var cpack Command_packet
+	cpack.Type = HELP
+	cpack.Username = client.Account_info.Username
+
+	// checking if an arguemtns were passed to help
+	if len(command.Args) > 0 {
+		cpack.Arguments = []byte("Too many arguments")
+		cpack.Successful = false
+		send_command_packet(cpack, client)
+		return
+	}
+
+	cpack.Arguments = []byte("OK")
+	cpack.Successful = true
+	send_command_packet(cpack, client)
+
+	cpack = read_command_packet(client)
+	if cpack.Type == -1 {
+		return
+	} else if string(cpack.Arguments) != "READY" {
+		custom_error_exit(UNEXPECTED_DATA)
+	}
+
+	// saving current state and then setting state to IN_HELP_SCREEN
+	client = update_client(client)
+	previous_state := client.State
+	update_client_state(client, IN_HELP_SCREEN)
+	client.State = IN_HELP_SCREEN
+
+	// sending packet with user's role
+	cpack = Command_packet{Type: HELP, Username: client.Account_info.Username, Arguments: []byte(strconv.Itoa(client.Account_info.Role))}
+	send_command_packet(cpack, client)
+
+	// reading packet from client
+	cpack = read_command_packet(client)
+
+	// checking if the connection was closed while in the help screen
+	if cpack.Type == -1 {
+		return
+	}
+
+	// checking if data is expected keyword
+	if string(string(cpack.Arguments)) != "DONE" {
+		custom_error_exit(UNEXPECTED_DATA)
+	}
+
+	// restoring state prior to command
+	update_client_state(client, previous_state)
+}
+
+/*
+ * This function handles the client exiting
+ */
+func exit_command(client Client) {
+	// udpating client status to quitting
+	update_client_state(client, QUITTING)
+
+	// updating client to quitting
+	disconnect_client(client)
+}
+
+func disconnect_client(client Client) {
+	// updating the client's state to quitting
+	update_client_state(client, QUITTING)
+
+	// informing client that the state has been changed
+	var cpack Command_packet
+	cpack.Type = EXIT
+	cpack.Username = client.Account_info.Username
+	cpack.Arguments = []byte("READY")
+	send_command_packet(cpack, client)
+
+	cpack = read_command_packet(client)
+	if client.Current_channel > -1 {
+		leave_channel(client)
+	}
+
+	time.Sleep(5 * time.Second)
+
+	sub_client(client)
+}
+
+/*
+ * This function handles the creat command
+ */
+func create_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating command packet to send to client
+	var cpack Command_packet
 	cpack.Type = CREATE
 	cpack.Username = client.Account_info.Username
 
-	// checking if client is in a state to enter this command
-	if client.Account_info.Role <= PUBLIC {
-		cpack.Message = []byte("You don't have permission to use this command")
-		cpack.Arguments = nil
-	} else if len(command.Args) < 1 {
-		cpack.Message = []byte("Not enough arguments")
-		cpack.Arguments = nil
-	} else if len(command.Args) > 1 {
-		cpack.Message = []byte("Too many arguments")
-		cpack.Arguments = nil
+	// checking if client is in a state to enter this command
+	if !authorize(client.Account_info.Username, PERM_CREATE_CHANNEL, CHANNEL_MEMBER) {
+		cpack.Message = []byte("You don't have permission to use this command")
+		cpack.Arguments = nil
+	} else if len(command.Args) < 1 {
+		cpack.Message = []byte("Not enough arguments")
+		cpack.Arguments = nil
+	} else if len(command.Args) > 2 && command.Args[1] != "password" {
+		cpack.Message = []byte("Too many arguments")
+		cpack.Arguments = nil
+	} else {
+		// creating channel
+		var successful bool
+		cpack.Message, successful = create_channel(client, command)
+
+		// checking if the channel was created successfully
+		if successful {
+			// building a string from the channels the client can see
+			cpack.Arguments = []byte(get_channel_list(client))
+			cpack.Successful = true
+		} else {
+			cpack.Arguments = nil
+			cpack.Successful = false
+		}
+	}
+
+	// sending packet
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function handles the main command
+ */
+func main_command(client Client, command Parsed_command) {
+	// creating part of the return packet
+	var cpack Command_packet
+	cpack.Type = MAIN
+	cpack.Username = client.Account_info.Username
+
+	// checking if args were passed in with the command
+	if len(command.Args) > 0 {
+		cpack.Arguments = []byte("Too many arguments")
+	} else if leave_channel(client) {
+		update_client_state(client, IN_MAIN_MENU)
+		dpack := Data_packet{Type: CLOSE, Username: client.Account_info.Username, Data: []byte("Going to main menu")}
+		send_data_packet(dpack, client)
+		cpack.Arguments = []byte("Success")
+	} else {
+		cpack.Arguments = []byte("Failed to leave channel")
+	}
+
+	// sending resonse to client
+	send_command_packet(cpack, client)
+}
+
+/*
+ *
+ */
+func change_topic_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	var cpack Command_packet
+	cpack.Type = CHANGE_TOPIC
+	cpack.Username = client.Account_info.Username
+
+	// the topic may have spaces and colons, it is sent apart from the channel name
+	topic := strings.TrimSpace(string(command.Message))
+
+	// ensuring proper arguments, permissions, and other requirements
+	if len(command.Args) < 1 || topic == "" {
+		cpack.Arguments = []byte("Not enough arguments")
+	} else if len(command.Args) > 1 {
+		cpack.Arguments = []byte("Too many arguments")
+	} else if len(topic) > MAX_TOPIC_LENGTH {
+		cpack.Arguments = []byte("Topics can be at most " + strconv.Itoa(MAX_TOPIC_LENGTH) + " bytes long")
+	} else if command.Args[0] == "nonsense" {
+		cpack.Arguments = []byte("Default channel. Cannot change this channel's topic")
+	} else {
+		index := get_channel_id(command.Args[0])
+
+		channels_mutex.Lock()
+		defer channels_mutex.Unlock()
+
+		// checking if the channel exists
+		if index == -1 || !can_access_channel(client.Account_info.Username, channels[index]) {
+			cpack.Arguments = []byte("No chat found with the name \"" + command.Args[0] + "\"")
+		} else if !authorize_in_channel(client.Account_info.Username, PERM_CHANGE_TOPIC, channels[index]) {
+			cpack.Arguments = []byte("You don't have permission to use this command")
+		} else {
+			channels[index].Topic = []byte(topic)
+			save_channels()
+			cpack.Arguments = []byte("Successfully changed the topic of #" + channels[index].Name + " to \"" + topic + "\"")
+
+			// updating the banner of everyone in the channel
+			users := append([]int(nil), channels[index].Users...)
+			broadcast_data_packet(get_channel_info(channels[index]), users, -1)
+		}
+	}
+
+	// sending response to server
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function gives the moderator role to a user
+ */
+func add_mod_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = ADD_MOD
+	cpack.Username = client.Account_info.Username
+
+	// checking requirements
+	if !authorize(client.Account_info.Username, PERM_MANAGE_ROLES, CHANNEL_MEMBER) {
+		cpack.Arguments = []byte("You don't have permission to use this command")
+	} else if len(command.Args) < 1 {
+		cpack.Arguments = []byte("Not enough arguments")
+	} else if len(command.Args) > 1 {
+		cpack.Arguments = []byte("Too many arguments")
+	} else {
+
+		active_clients_mutex.Lock()
+		found_1 := false
+		found_2 := false
+
+		// finding account
+		for _, user := range active_clients {
+			if user.Account_info.Username == command.Args[0] {
+				user.Account_info.Role = 1
+				found_1 = true
+
+				registered_accounts_mutex.Lock()
+				for index, user := range registered_accounts {
+					if user.Username == command.Args[0] {
+						registered_accounts[index].Role = 1
+						found_2 = true
+						break
+					}
+				}
+				registered_accounts_mutex.Unlock()
+			}
+		}
+		active_clients_mutex.Unlock()
+
+		// checking if account exists
+		if found_1 && found_2 {
+			cpack.Arguments = []byte("Successfully gave " + command.Args[0] + " the moderator role")
+		} else {
+			cpack.Arguments = []byte("Did not find account with that name")
+		}
+	}
+
+	fmt.Println("system: sending command status")
+
+	// sending response
+	send_command_packet(cpack, client)
+
+	// saving changes to account
+	save_accounts()
+}
+
+/*
+ * This function gives the moderator role to a user
+ */
+func rm_mod_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	var cpack Command_packet
+	cpack.Type = ADD_MOD
+	cpack.Username = client.Account_info.Username
+
+	if !authorize(client.Account_info.Username, PERM_MANAGE_ROLES, CHANNEL_MEMBER) {
+		cpack.Arguments = []byte("You don't have permission to use this command")
+	} else if len(command.Args) < 1 {
+		cpack.Arguments = []byte("Not enough arguments")
+	} else if len(command.Args) > 1 {
+		cpack.Arguments = []byte("Too many arguments")
+	} else {
+
+		active_clients_mutex.Lock()
+		for _, user := range active_clients {
+			if user.Account_info.Username == command.Args[0] {
+				user.Account_info.Role = 0
+
+				registered_accounts_mutex.Lock()
+				for _, user := range registered_accounts {
+					user.Role = 0
+				}
+				registered_accounts_mutex.Unlock()
+			}
+		}
+		active_clients_mutex.Unlock()
+
+		cpack.Arguments = []byte("Successfully removed the moderator role from " + command.Args[0])
+	}
+
+	// sending response
+	send_command_packet(cpack, client)
+
+	// saving account changes
+	save_accounts()
+}
+
+/*
+ * This function lists the accounts and addresses that have failed logins or are locked out
+ */
+func lockouts_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = LOCKOUTS
+	cpack.Username = client.Account_info.Username
+
+	if !authorize(client.Account_info.Username, PERM_MANAGE_LOCKOUTS, CHANNEL_MEMBER) {
+		cpack.Arguments = []byte("You don't have permission to use this command")
+	} else if len(command.Args) > 0 {
+		cpack.Arguments = []byte("Too many arguments")
+	} else {
+		// building list of failures
+		var entries []string
+		now := time.Now()
+		login_failures_mutex.Lock()
+		for _, failures_map := range []map[string]*Login_failures{account_failures, address_failures} {
+			for key, failures := range failures_map {
+				entry := key + " (" + strconv.Itoa(failures.Count) + " failed"
+				if failures.Locked_until.After(now) {
+					entry += ", locked for " + failures.Locked_until.Sub(now).Round(time.Second).String()
+				}
+				entries = append(entries, entry+")")
+			}
+		}
+		login_failures_mutex.Unlock()
+
+		if len(entries) == 0 {
+			cpack.Arguments = []byte("No failed logins")
+		} else {
+			cpack.Arguments = []byte(strings.Join(entries, ", "))
+		}
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function clears the failed logins and lockout of an account or address
+ */
+func unlock_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = UNLOCK
+	cpack.Username = client.Account_info.Username
+
+	if !authorize(client.Account_info.Username, PERM_MANAGE_LOCKOUTS, CHANNEL_MEMBER) {
+		cpack.Arguments = []byte("You don't have permission to use this command")
+	} else if len(command.Args) < 1 {
+		cpack.Arguments = []byte("Not enough arguments")
+	} else if len(command.Args) > 1 {
+		cpack.Arguments = []byte("Too many arguments")
+	} else {
+		// removing the failures of the account or address
+		login_failures_mutex.Lock()
+		_, account_found := account_failures[command.Args[0]]
+		_, address_found := address_failures[command.Args[0]]
+		delete(account_failures, command.Args[0])
+		delete(address_failures, command.Args[0])
+		login_failures_mutex.Unlock()
+
+		if account_found || address_found {
+			audit_log(client.Account_info.Username + " cleared the lockout of " + command.Args[0])
+			cpack.Arguments = []byte("Cleared the lockout of " + command.Args[0])
+		} else {
+			cpack.Arguments = []byte("No failed logins found for " + command.Args[0])
+		}
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function handles the list-c command which lists the users in the client's channel
+ */
+func list_c_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = LIST_C
+	cpack.Username = client.Account_info.Username
+
+	if client.State != MESSAGING {
+		cpack.Arguments = []byte("You are not in a channel")
+	} else if len(command.Args) > 0 {
+		cpack.Arguments = []byte("Too many arguments")
+	} else {
+		// gathering users in channel
+		var users []Client
+		channels_mutex.Lock()
+		active_clients_mutex.Lock()
+		for _, user := range channels[client.Current_channel].Users {
+			users = append(users, *active_clients[user])
+		}
+		active_clients_mutex.Unlock()
+		channels_mutex.Unlock()
+
+		cpack.Arguments = []byte("In #" + get_channel_name(client) + ": " + list_accounts(users))
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function handles the list-s command which lists the users logged in to the server
+ */
+func list_s_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = LIST_S
+	cpack.Username = client.Account_info.Username
+
+	if !client.Logged_in {
+		cpack.Arguments = []byte("You are not logged in")
+	} else if len(command.Args) > 0 {
+		cpack.Arguments = []byte("Too many arguments")
+	} else {
+		// gathering logged in users
+		var users []Client
+		active_clients_mutex.Lock()
+		for _, user := range active_clients {
+			if user.Logged_in {
+				users = append(users, *user)
+			}
+		}
+		active_clients_mutex.Unlock()
+
+		cpack.Arguments = []byte("Online: " + list_accounts(users))
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function gets the name of the channel a client is in
+ */
+func get_channel_name(client Client) string {
+	channels_mutex.Lock()
+	defer channels_mutex.Unlock()
+
+	return channels[client.Current_channel].Name
+}
+
+/*
+ * This function handles the log_out command which signs the client out and forgets the session of its device
+ */
+func log_out_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = LOG_OUT
+	cpack.Username = client.Account_info.Username
+
+	if !client.Logged_in {
+		cpack.Arguments = []byte("You are not logged in")
+	} else if len(command.Args) > 0 {
+		cpack.Arguments = []byte("Too many arguments")
+	} else {
+		revoke_sessions(client.Account_info.Username, client.device, false)
+		sign_out(client)
+		audit_log("logged out account " + client.Account_info.Username + " on " + client.device)
+		cpack.Arguments = []byte("Success")
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function handles the log_out_all command which signs an account out on every device
+ */
+func log_out_all_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = LOG_OUT_ALL
+	cpack.Username = client.Account_info.Username
+
+	if !client.Logged_in {
+		cpack.Arguments = []byte("You are not logged in")
+	} else if len(command.Args) > 0 {
+		cpack.Arguments = []byte("Too many arguments")
+	} else {
+		revoke_sessions(client.Account_info.Username, "", true)
+		drop_sessions(client, client.Account_info.Username, "", true)
+		sign_out(client)
+		audit_log("logged out account " + client.Account_info.Username + " on every device")
+		cpack.Arguments = []byte("Success")
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function handles the devices command which lists the devices an account has sessions on
+ */
+func devices_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = DEVICES
+	cpack.Username = client.Account_info.Username
+
+	if !client.Logged_in {
+		cpack.Arguments = []byte("You are not logged in")
+	} else if len(command.Args) > 0 {
+		cpack.Arguments = []byte("Too many arguments")
+	} else {
+		// building list of devices
+		var entries []string
+		sessions_mutex.Lock()
+		for session_id, session := range sessions {
+			if session.Username != client.Account_info.Username {
+				continue
+			}
+			if session_id == client.token_id {
+				entries = append(entries, session.Device+" (this device)")
+			} else {
+				entries = append(entries, session.Device+" (until "+session.Expires.Format("Jan 2")+")")
+			}
+		}
+		sessions_mutex.Unlock()
+
+		if len(entries) == 0 {
+			cpack.Arguments = []byte("No devices are remembered")
+		} else {
+			slices.Sort(entries)
+			cpack.Arguments = []byte(strings.Join(entries, ", "))
+		}
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function handles the revoke command which signs an account out on one of its other devices
+ */
+func revoke_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = REVOKE
+	cpack.Username = client.Account_info.Username
+
+	if !client.Logged_in {
+		cpack.Arguments = []byte("You are not logged in")
+	} else if len(command.Args) != 1 {
+		cpack.Arguments = []byte("Usage: /revoke <device>")
+	} else if command.Args[0] == client.device {
+		cpack.Arguments = []byte("Use /log_out to sign out on this device")
+	} else if revoke_sessions(client.Account_info.Username, command.Args[0], false) == 0 {
+		cpack.Arguments = []byte("No session found for that device")
+	} else {
+		drop_sessions(client, client.Account_info.Username, command.Args[0], false)
+		audit_log("revoked session of account " + client.Account_info.Username + " on " + command.Args[0])
+		cpack.Arguments = []byte("Signed out on " + command.Args[0])
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function handles the msg command which sends a direct message to a user.
+ * The text of the message is in the message of the packet so it is not split up like arguments are
+ */
+func msg_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = MSG
+	cpack.Username = client.Account_info.Username
+
+	if !client.Logged_in {
+		cpack.Arguments = []byte("You are not logged in")
+	} else if len(command.Args) != 1 || len(command.Message) == 0 {
+		cpack.Arguments = []byte("Usage: /msg <user> <text>")
+	} else if muted, retry_after := is_muted(client); muted {
+		cpack.Arguments = []byte("429 muted for flooding - retry after " + format_retry_after(retry_after))
+	} else if command.Args[0] == client.Account_info.Username {
+		cpack.Arguments = []byte("Cannot message yourself")
+	} else if get_user_index(command.Args[0]) == -1 {
+		cpack.Arguments = []byte("Could not find a user with that name")
+	} else {
+		direct_message := Direct_message{
+			Id:   next_message_id(),
+			From: client.Account_info.Username,
+			To:   command.Args[0],
+			Text: command.Message,
+			Sent: time.Now(),
+		}
+
+		// keeping the message for later if the recipient is offline
+		if deliver_direct_message(direct_message, client.Id) == 0 {
+			store_pending_direct_message(direct_message)
+			cpack.Arguments = []byte(command.Args[0] + " is offline and will get your message when they log in")
+		} else {
+			cpack.Arguments = []byte("Sent to " + command.Args[0])
+		}
+		cpack.Successful = true
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function sends a direct message to every session of its recipient and to the other sessions of its sender.
+ * It returns the number of recipient sessions the message was sent to
+ */
+func deliver_direct_message(direct_message Direct_message, excluded_id int) int {
+	// gathering sessions
+	var recipients []Client
+	delivered := 0
+	active_clients_mutex.Lock()
+	for _, user := range active_clients {
+		if !user.Logged_in || user.State == QUITTING || user.Id == excluded_id {
+			continue
+		}
+		if user.Account_info.Username == direct_message.To {
+			recipients = append(recipients, *user)
+			delivered++
+		} else if user.Account_info.Username == direct_message.From {
+			recipients = append(recipients, *user)
+		}
+	}
+	active_clients_mutex.Unlock()
+
+	// direct messages go over the command socket since it is read all the time, through each session's queue
+	// so a session that stopped reading cannot stall the sender
+	json_data, err := json.Marshal(direct_message)
+	if err != nil {
+		error_exit(err)
+	}
+	for _, recipient := range recipients {
+		queue_command_packet(Command_packet{Type: DIRECT_MSG, Username: direct_message.From, Message: json_data}, recipient)
+	}
+
+	return delivered
+}
+
+/*
+ * This function keeps a direct message until its recipient logs in
+ */
+func store_pending_direct_message(direct_message Direct_message) {
+	pending_direct_messages_mutex.Lock()
+	pending := append(pending_direct_messages[direct_message.To], direct_message)
+	if len(pending) > MAX_PENDING_DIRECT_MESSAGES {
+		pending = pending[len(pending)-MAX_PENDING_DIRECT_MESSAGES:]
+	}
+	pending_direct_messages[direct_message.To] = pending
+	pending_direct_messages_mutex.Unlock()
+
+	save_pending_direct_messages()
+}
+
+/*
+ * This function sends a client the direct messages that were sent to its account while it was offline
+ */
+func deliver_pending_direct_messages(client Client, username string) {
+	pending_direct_messages_mutex.Lock()
+	pending := pending_direct_messages[username]
+	delete(pending_direct_messages, username)
+	pending_direct_messages_mutex.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+	save_pending_direct_messages()
+
+	fmt.Printf("system: Delivering %d direct messages to client #%d\n", len(pending), client.Id)
+
+	for _, direct_message := range pending {
+		json_data, err := json.Marshal(direct_message)
+		if err != nil {
+			error_exit(err)
+		}
+		send_command_packet(Command_packet{Type: DIRECT_MSG, Username: direct_message.From, Message: json_data}, client)
+	}
+}
+
+/*
+ * This function loads the direct messages waiting for offline users
+ */
+func load_pending_direct_messages() {
+	json_data, err := os.ReadFile(DIRECT_MESSAGES_PATH)
+	if err != nil {
+		// there are no direct messages to load the first time the server runs
+		if os.IsNotExist(err) {
+			return
+		}
+		error_exit(err)
+	}
+
+	pending_direct_messages_mutex.Lock()
+	defer pending_direct_messages_mutex.Unlock()
+
+	// unmarshaling json data
+	if err := json.Unmarshal(json_data, &pending_direct_messages); err != nil {
+		error_exit(err)
+	}
+
+	msg := GREEN + " - loaded direct messages\n" + RESET
+	fmt.Print(msg)
+}
+
+/*
+ * This function saves the direct messages waiting for offline users
+ */
+func save_pending_direct_messages() {
+	pending_direct_messages_mutex.Lock()
+	json_data, err := json.Marshal(pending_direct_messages)
+	pending_direct_messages_mutex.Unlock()
+	if err != nil {
+		error_exit(err)
+	}
+
+	// only the server should be able to read other people's messages
+	if err := os.WriteFile(DIRECT_MESSAGES_PATH, json_data, 0600); err != nil {
+		error_exit(err)
+	}
+}
+
+/*
+ * This function handles the mentions command which sends the client the messages it was mentioned in
+ */
+func mentions_command(client Client) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = MENTIONS
+	cpack.Username = client.Account_info.Username
+
+	if !client.Logged_in {
+		cpack.Arguments = []byte("You are not logged in")
+	} else {
+		mentions_mutex.Lock()
+		json_data, err := json.Marshal(mentions[client.Account_info.Username])
+		mentions_mutex.Unlock()
+		if err != nil {
+			error_exit(err)
+		}
+		cpack.Message = json_data
+		cpack.Successful = true
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function loads the last message each user read in each channel
+ */
+func load_read_markers() {
+	json_data, err := os.ReadFile(READ_MARKERS_PATH)
+	if err != nil {
+		// there are no read markers to load the first time the server runs
+		if os.IsNotExist(err) {
+			return
+		}
+		error_exit(err)
+	}
+
+	read_markers_mutex.Lock()
+	defer read_markers_mutex.Unlock()
+
+	// unmarshaling json data
+	if err := json.Unmarshal(json_data, &read_markers); err != nil {
+		error_exit(err)
+	}
+
+	msg := GREEN + " - loaded read markers\n" + RESET
+	fmt.Print(msg)
+}
+
+/*
+ * This function saves the last message each user read in each channel if it changed since it was last saved
+ */
+func save_read_markers() {
+	read_markers_mutex.Lock()
+	if !read_markers_changed {
+		read_markers_mutex.Unlock()
+		return
+	}
+	json_data, err := json.Marshal(read_markers)
+	read_markers_changed = false
+	read_markers_mutex.Unlock()
+	if err != nil {
+		error_exit(err)
+	}
+
+	// the read markers are saved again next time if this fails
+	if err := os.WriteFile(READ_MARKERS_PATH, json_data, 0600); err != nil {
+		fmt.Println("system: Failed to save read markers -", err)
+		read_markers_mutex.Lock()
+		read_markers_changed = true
+		read_markers_mutex.Unlock()
+	}
+}
+
+/*
+ * This function loads the mentions inbox of every user
+ */
+func load_mentions() {
+	json_data, err := os.ReadFile(MENTIONS_PATH)
+	if err != nil {
+		// there are no mentions to load the first time the server runs
+		if os.IsNotExist(err) {
+			return
+		}
+		error_exit(err)
+	}
+
+	mentions_mutex.Lock()
+	defer mentions_mutex.Unlock()
+
+	// unmarshaling json data
+	if err := json.Unmarshal(json_data, &mentions); err != nil {
+		error_exit(err)
+	}
+
+	msg := GREEN + " - loaded mentions\n" + RESET
+	fmt.Print(msg)
+}
+
+/*
+ * This function saves what changed since it was last saved every SAVE_INTERVAL, so the files are not
+ * written again on every message
+ */
+func save_periodically() {
+	ticker := time.NewTicker(SAVE_INTERVAL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		save_mentions()
+		save_read_markers()
+	}
+}
+
+/*
+ * This function saves the mentions inbox of every user if it changed since it was last saved
+ */
+func save_mentions() {
+	mentions_mutex.Lock()
+	if !mentions_changed {
+		mentions_mutex.Unlock()
+		return
+	}
+	json_data, err := json.Marshal(mentions)
+	mentions_changed = false
+	mentions_mutex.Unlock()
+	if err != nil {
+		error_exit(err)
+	}
+
+	// mentions hold the text of messages in private channels, they are saved again next time if this fails
+	if err := os.WriteFile(MENTIONS_PATH, json_data, 0600); err != nil {
+		fmt.Println("system: Failed to save mentions -", err)
+		mentions_mutex.Lock()
+		mentions_changed = true
+		mentions_mutex.Unlock()
+	}
+}
+
+/*
+ * This function handles the search command which sends the client a page of the messages matching a search
+ */
+func search_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = SEARCH
+	cpack.Username = client.Account_info.Username
+
+	// getting the page of results
+	page := -1
+	if len(command.Args) == 1 {
+		if number, err := strconv.Atoi(command.Args[0]); err == nil && number >= 0 {
+			page = number
+		}
+	}
+	query, problem := parse_search_query(string(command.Message))
+
+	if !client.Logged_in {
+		cpack.Arguments = []byte("You are not logged in")
+	} else if page == -1 || len(command.Message) == 0 {
+		cpack.Arguments = []byte("Usage: /search [in:<channel>] [from:<user>] [since:<yyyy-mm-dd>] [until:<yyyy-mm-dd>] <words>")
+	} else if problem != "" {
+		cpack.Arguments = []byte(problem)
+	} else if allowed, retry_after := take_token(client, SEARCH_LIMIT); !allowed {
+		cpack.Arguments = []byte(rate_limit_message(retry_after))
+	} else {
+		results := search_messages(client.Account_info.Username, query)
+
+		// cutting out the page that was asked for
+		search_page := Search_page{Total: len(results), Page: page, Page_size: SEARCH_PAGE_SIZE}
+		start := min(page*SEARCH_PAGE_SIZE, len(results))
+		search_page.Results = results[start:min(start+SEARCH_PAGE_SIZE, len(results))]
+
+		json_data, err := json.Marshal(search_page)
+		if err != nil {
+			error_exit(err)
+		}
+		cpack.Message = json_data
+		cpack.Successful = true
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function handles the search context command which sends the client the messages around a search result
+ */
+func search_context_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = SEARCH_CONTEXT
+	cpack.Username = client.Account_info.Username
+
+	// getting the id of the message
+	message_id := -1
+	if len(command.Args) == 1 {
+		if id, err := strconv.Atoi(command.Args[0]); err == nil {
+			message_id = id
+		}
+	}
+
+	if !client.Logged_in {
+		cpack.Arguments = []byte("You are not logged in")
+	} else if context := get_search_context(client.Account_info.Username, message_id); context == nil {
+		cpack.Arguments = []byte("Could not find that message")
+	} else {
+		json_data, err := json.Marshal(Search_page{Results: context, Total: len(context)})
+		if err != nil {
+			error_exit(err)
+		}
+		cpack.Message = json_data
+		cpack.Successful = true
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function parses a search into its words and filters.
+ * It returns a description of the problem if the search is not valid
+ */
+func parse_search_query(text string) (Search_query, string) {
+	query := Search_query{Channel: -1}
+	for _, token := range strings.Fields(text) {
+		name, value, _ := strings.Cut(token, ":")
+		switch name {
+		case "in":
+			query.Channel = get_channel_id(strings.TrimPrefix(value, "#"))
+			if query.Channel == -1 {
+				return query, "No channel named #" + strings.TrimPrefix(value, "#")
+			}
+		case "from":
+			query.Author = value
+		case "since", "until":
+			date, err := time.ParseInLocation(SEARCH_DATE_FORMAT, value, time.Local)
+			if err != nil {
+				return query, "Dates must look like " + SEARCH_DATE_FORMAT
+			}
+			if name == "since" {
+				query.Since = date
+			} else {
+				// including the whole day
+				query.Until = date.AddDate(0, 0, 1)
+			}
+		default:
+			query.Terms = append(query.Terms, search_terms(token)...)
+		}
+	}
+
+	// not allowing searches that would match every message
+	if len(query.Terms) == 0 && query.Channel == -1 && query.Author == "" && query.Since.IsZero() && query.Until.IsZero() {
+		return query, "Search for at least one word or use a filter"
+	}
+	return query, ""
+}
+
+/*
+ * This function splits text into the lowercase words it can be searched by
+ */
+func search_terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	slices.Sort(words)
+	return slices.Compact(words)
+}
+
+/*
+ * This function finds the messages a user can see that match a search, newest first
+ */
+func search_messages(username string, query Search_query) []Search_result {
+	channel_names := get_accessible_channels(username)
+
+	archive_mutex.Lock()
+	defer archive_mutex.Unlock()
+
+	// finding the messages with a word starting with every search term
+	var candidates map[int]bool
+	for _, term := range query.Terms {
+		matches := make(map[int]bool)
+		start, _ := slices.BinarySearch(indexed_words, term)
+		for _, word := range indexed_words[start:] {
+			if !strings.HasPrefix(word, term) {
+				break
+			}
+			for id := range search_index[word] {
+				if candidates == nil || candidates[id] {
+					matches[id] = true
+				}
+			}
+		}
+		candidates = matches
+	}
+
+	// searching every message when only filters were given
+	if candidates == nil {
+		candidates = make(map[int]bool, len(archive))
+		for id := range archive {
+			candidates[id] = true
+		}
+	}
+
+	// applying filters
+	var results []Search_result
+	for id := range candidates {
+		message := archive[id]
+		channel_name, accessible := channel_names[message.Channel]
+		if !accessible || (query.Channel != -1 && message.Channel != query.Channel) || (query.Author != "" && message.Username != query.Author) {
+			continue
+		}
+		if (!query.Since.IsZero() && message.Sent.Before(query.Since)) || (!query.Until.IsZero() && !message.Sent.Before(query.Until)) {
+			continue
+		}
+		results = append(results, Search_result{Message_id: id, Channel: channel_name, Username: message.Username, Text: message.Text, Sent: message.Sent, Edited: message.Edited})
+	}
+
+	// newest first
+	slices.SortFunc(results, func(a, b Search_result) int { return b.Message_id - a.Message_id })
+	return results
+}
+
+/*
+ * This function gets a message from the archive with the messages sent just before and after it in its channel.
+ * It returns nil if the message does not exist or the user cannot see its channel
+ */
+func get_search_context(username string, message_id int) []Search_result {
+	channel_names := get_accessible_channels(username)
+
+	archive_mutex.Lock()
+	defer archive_mutex.Unlock()
+
+	message, exists := archive[message_id]
+	channel_name, accessible := channel_names[message.Channel]
+	if !exists || !accessible {
+		return nil
+	}
+
+	// cutting out the messages around the result
+	ids := channel_archive[message.Channel]
+	position, _ := slices.BinarySearch(ids, message_id)
+	var context []Search_result
+	for _, id := range ids[max(position-SEARCH_CONTEXT_SIZE, 0):min(position+SEARCH_CONTEXT_SIZE+1, len(ids))] {
+		other := archive[id]
+		context = append(context, Search_result{Message_id: id, Channel: channel_name, Username: other.Username, Text: other.Text, Sent: other.Sent, Edited: other.Edited})
+	}
+	return context
+}
+
+/*
+ * This function gets the names of the channels a user can see, keyed by channel id
+ */
+func get_accessible_channels(username string) map[int]string {
+	channels_mutex.Lock()
+	defer channels_mutex.Unlock()
+
+	channel_names := make(map[int]string)
+	for index, channel := range channels {
+		if can_access_channel(username, channel) {
+			channel_names[index] = channel.Name
+		}
+	}
+	return channel_names
+}
+
+/*
+ * This function adds the current state of a message to the archive and its search index,
+ * and appends it to the archive file so it can still be searched after a restart
+ */
+func archive_message(message Archived_message) {
+	archive_mutex.Lock()
+	defer archive_mutex.Unlock()
+
+	index_message(message)
+	trim_archive()
+
+	json_data, err := json.Marshal(message)
+	if err != nil {
+		error_exit(err)
+	}
+
+	// opening archive for appending, only the server should be able to read messages from private channels
+	file, err := os.OpenFile(ARCHIVE_PATH, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Println("system: Failed to open message archive -", err)
+		return
+	}
+	write_to_file(file, append(json_data, '\n'))
+	file.Close()
+	archive_lines++
+
+	compact_archive()
+}
+
+/*
+ * This function drops the oldest messages from the archive once it has more than MAX_ARCHIVED_MESSAGES.
+ * The archive mutex must be held by the caller
+ */
+func trim_archive() {
+	for len(archive) > MAX_ARCHIVED_MESSAGES {
+		if _, exists := archive[oldest_archive_id]; exists {
+			index_message(Archived_message{Message_id: oldest_archive_id, Deleted: true})
+		}
+		oldest_archive_id++
+	}
+}
+
+/*
+ * This function rewrites the archive file with only the current state of every archived message once most of
+ * its lines are edits, deletions or dropped messages, so the file does not grow without end.
+ * The archive mutex must be held by the caller
+ */
+func compact_archive() {
+	if archive_lines < ARCHIVE_COMPACT_LINES || archive_lines <= 2*len(archive) {
+		return
+	}
+
+	// writing the messages oldest first to a new file so the archive is never left half written
+	ids := make([]int, 0, len(archive))
+	for id := range archive {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	var lines []byte
+	for _, id := range ids {
+		json_data, err := json.Marshal(archive[id])
+		if err != nil {
+			error_exit(err)
+		}
+		lines = append(append(lines, json_data...), '\n')
+	}
+
+	temp_path := ARCHIVE_PATH + ".tmp"
+	if err := os.WriteFile(temp_path, lines, 0600); err != nil {
+		fmt.Println("system: Failed to compact message archive -", err)
+		return
+	}
+	if err := os.Rename(temp_path, ARCHIVE_PATH); err != nil {
+		fmt.Println("system: Failed to compact message archive -", err)
+		return
+	}
+
+	fmt.Printf("system: Compacted message archive from %d to %d lines\n", archive_lines, len(ids))
+	archive_lines = len(ids)
+}
+
+/*
+ * This function replaces a message in the archive and its search index, deleted messages are taken out of both.
+ * The archive mutex must be held by the caller
+ */
+func index_message(message Archived_message) {
+	// taking the old text out of the index
+	if old, exists := archive[message.Message_id]; exists {
+		for _, term := range search_terms(string(old.Text)) {
+			delete(search_index[term], message.Message_id)
+			if len(search_index[term]) == 0 {
+				delete(search_index, term)
+				if position, found := slices.BinarySearch(indexed_words, term); archive_loaded && found {
+					indexed_words = slices.Delete(indexed_words, position, position+1)
+				}
+			}
+		}
+		delete(archive, message.Message_id)
+
+		// taking the message out of the order of its channel, edits keep their place
+		if message.Deleted || message.Channel != old.Channel {
+			ids := channel_archive[old.Channel]
+			if position, found := slices.BinarySearch(ids, message.Message_id); found {
+				channel_archive[old.Channel] = slices.Delete(ids, position, position+1)
+			}
+		}
+	}
+
+	if message.Deleted {
+		return
+	}
+
+	// adding the new text, messages almost always arrive newest last so they go on the end of their channel
+	archive[message.Message_id] = message
+	ids := channel_archive[message.Channel]
+	if position, found := slices.BinarySearch(ids, message.Message_id); !found {
+		channel_archive[message.Channel] = slices.Insert(ids, position, message.Message_id)
+	}
+	for _, term := range search_terms(string(message.Text)) {
+		if search_index[term] == nil {
+			search_index[term] = make(map[int]bool)
+			if archive_loaded {
+				position, _ := slices.BinarySearch(indexed_words, term)
+				indexed_words = slices.Insert(indexed_words, position, term)
+			}
+		}
+		search_index[term][message.Message_id] = true
+	}
+}
+
+/*
+ * This function loads the archive of messages and builds its search index
+ */
+func load_archive() {
+	file, err := os.Open(ARCHIVE_PATH)
+	if err != nil {
+		// there is no archive the first time the server runs
+		if os.IsNotExist(err) {
+			archive_loaded = true
+			return
+		}
+		error_exit(err)
+	}
+	defer file.Close()
+
+	archive_mutex.Lock()
+	defer archive_mutex.Unlock()
+
+	// replaying every change in the order it happened
+	newest := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var message Archived_message
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			error_exit(err)
+		}
+		index_message(message)
+		newest = max(newest, message.Message_id)
+		archive_lines++
+	}
+	if err := scanner.Err(); err != nil {
+		error_exit(err)
+	}
+	trim_archive()
+	compact_archive()
+
+	// putting the words in order all at once rather than one at a time as they were read
+	indexed_words = make([]string, 0, len(search_index))
+	for word := range search_index {
+		indexed_words = append(indexed_words, word)
+	}
+	slices.Sort(indexed_words)
+	archive_loaded = true
+
+	// new messages must not reuse the ids of archived ones
+	if int64(newest) > last_message_id.Load() {
+		last_message_id.Store(int64(newest))
+	}
+
+	msg := GREEN + " - loaded " + strconv.Itoa(len(archive)) + " archived messages\n" + RESET
+	fmt.Print(msg)
+}
+
+/*
+ * This function handles the upload command which starts or resumes sending a file to the current channel.
+ * The chunks of the file are sent over a connection the server opens back to the client, so they stay out of the way of chat messages
+ */
+func upload_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = UPLOAD
+	cpack.Username = client.Account_info.Username
+
+	var request Transfer_request
+	err := json.Unmarshal(command.Message, &request)
+	name := clean_file_name(request.Name)
+	address := get_transfer_address(client, request.Address)
+
+	if client.State != MESSAGING {
+		cpack.Arguments = []byte("You are not in a channel")
+	} else if err != nil || name == "" || address == "" || len(request.Checksum) != sha256.Size*2 {
+		cpack.Arguments = []byte("Usage: /upload <path>")
+	} else if request.Size <= 0 {
+		cpack.Arguments = []byte("Empty files can't be shared")
+	} else if request.Size > MAX_FILE_SIZE {
+		cpack.Arguments = []byte("Files can be at most " + format_size(MAX_FILE_SIZE))
+	} else if muted, retry_after := is_muted(client); muted {
+		cpack.Arguments = []byte("429 muted for flooding - retry after " + format_retry_after(retry_after))
+	} else if allowed, retry_after := take_token(client, UPLOAD_LIMIT); !allowed {
+		cpack.Arguments = []byte(rate_limit_message(retry_after))
+	} else {
+		shared_files_mutex.Lock()
+		remove_expired_uploads()
+
+		// resuming an unfinished upload of the same file
+		var file *Shared_file
+		for _, shared := range shared_files {
+			if !shared.Complete && shared.Uploader == client.Account_info.Username && shared.Checksum == request.Checksum && shared.Size == request.Size {
+				file = shared
+			}
+		}
+
+		if file != nil && file.uploading {
+			cpack.Arguments = []byte(file.Name + " is already being uploaded")
+		} else if file == nil && storage_used()+request.Size > MAX_STORAGE {
+			cpack.Arguments = []byte("The server has no room left for files")
+		} else if file == nil && user_storage_used(client.Account_info.Username)+request.Size > MAX_USER_STORAGE {
+			cpack.Arguments = []byte("Your files can take at most " + format_size(MAX_USER_STORAGE) + ", unfinished uploads count at their full size")
+		} else if !start_transfer(client) {
+			cpack.Arguments = []byte("You can only run " + strconv.Itoa(MAX_ACTIVE_TRANSFERS) + " uploads and downloads at once")
+		} else {
+			if file == nil {
+				last_file_id++
+				file = &Shared_file{Id: last_file_id, Size: request.Size, Checksum: request.Checksum, Uploader: client.Account_info.Username}
+				shared_files[file.Id] = file
+			}
+
+			// the file is shared in the channel the upload was last started from
+			file.Name = name
+			file.Channel = client.Current_channel
+			file.Uploaded = time.Now()
+			file.uploading = true
+			save_files()
+
+			// finding out how much of the file already arrived
+			var offset int64
+			if info, err := os.Stat(get_file_path(file.Id) + PART_SUFFIX); err == nil {
+				offset = min(info.Size(), file.Size)
+			}
+
+			start := Transfer_start{Id: file.Id, Name: file.Name, Size: file.Size, Checksum: file.Checksum, Offset: offset}
+			json_data, err := json.Marshal(start)
+			if err != nil {
+				error_exit(err)
+			}
+			cpack.Message = json_data
+			cpack.Arguments = []byte("Uploading " + file.Name + " (" + format_size(file.Size) + ")")
+			if offset > 0 {
+				cpack.Arguments = []byte("Resuming the upload of " + file.Name + " at " + format_size(offset) + " of " + format_size(file.Size))
+			}
+			cpack.Successful = true
+
+			go receive_upload(*file, address, offset, client.session_id)
+		}
+		shared_files_mutex.Unlock()
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function handles the download command which starts or resumes sending a shared file to the client
+ */
+func download_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = DOWNLOAD
+	cpack.Username = client.Account_info.Username
+
+	// getting the id of the file
+	file_id := -1
+	if len(command.Args) == 1 {
+		if id, err := strconv.Atoi(strings.TrimPrefix(command.Args[0], "#")); err == nil {
+			file_id = id
+		}
+	}
+
+	var request Transfer_request
+	err := json.Unmarshal(command.Message, &request)
+	address := get_transfer_address(client, request.Address)
+
+	if !client.Logged_in {
+		cpack.Arguments = []byte("You are not logged in")
+	} else if file_id == -1 || err != nil || address == "" {
+		cpack.Arguments = []byte("Usage: /download <id>")
+	} else if muted, retry_after := is_muted(client); muted {
+		cpack.Arguments = []byte("429 muted for flooding - retry after " + format_retry_after(retry_after))
+	} else if allowed, retry_after := take_token(client, DOWNLOAD_LIMIT); !allowed {
+		cpack.Arguments = []byte(rate_limit_message(retry_after))
+	} else {
+		shared_files_mutex.Lock()
+		file, found := shared_files[file_id]
+		if found {
+			found = file.Complete
+		}
+		var shared Shared_file
+		if found {
+			shared = *file
+		}
+		shared_files_mutex.Unlock()
+
+		// files can only be downloaded by users who can see the channel they were shared in
+		if found {
+			channels_mutex.Lock()
+			found = can_access_channel(client.Account_info.Username, channels[shared.Channel])
+			channels_mutex.Unlock()
+		}
+
+		if !found {
+			cpack.Arguments = []byte("No file #" + strconv.Itoa(file_id))
+		} else if !start_transfer(client) {
+			cpack.Arguments = []byte("You can only run " + strconv.Itoa(MAX_ACTIVE_TRANSFERS) + " uploads and downloads at once")
+		} else {
+			// starting over if the client has more of the file than there is
+			offset := request.Offset
+			if offset < 0 || offset > shared.Size {
+				offset = 0
+			}
+
+			start := Transfer_start{Id: shared.Id, Name: shared.Name, Size: shared.Size, Checksum: shared.Checksum, Offset: offset}
+			json_data, err := json.Marshal(start)
+			if err != nil {
+				error_exit(err)
+			}
+			cpack.Message = json_data
+			cpack.Arguments = []byte("Downloading " + shared.Name + " (" + format_size(shared.Size) + ")")
+			if offset > 0 {
+				cpack.Arguments = []byte("Resuming the download of " + shared.Name + " at " + format_size(offset) + " of " + format_size(shared.Size))
+			}
+			cpack.Successful = true
+
+			go send_download(shared, address, offset, client.session_id)
+		}
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function gets the address the server connects to for a transfer. Only the port comes from the client, the host
+ * is the one its command socket connected from so clients cannot make the server connect anywhere else.
+ * It returns an empty string if there is no valid port
+ */
+func get_transfer_address(client Client, address string) string {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return ""
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+		return ""
+	}
+	return net.JoinHostPort(client.ip_address, port)
+}
+
+/*
+ * This function counts a transfer the session is starting. It returns false if the session
+ * already runs MAX_ACTIVE_TRANSFERS
+ */
+func start_transfer(client Client) bool {
+	active_transfers_mutex.Lock()
+	defer active_transfers_mutex.Unlock()
+
+	if active_transfers[client.session_id] >= MAX_ACTIVE_TRANSFERS {
+		return false
+	}
+	active_transfers[client.session_id]++
+	return true
+}
+
+/*
+ * This function stops counting a transfer once it has ended
+ */
+func end_transfer(session_id int64) {
+	active_transfers_mutex.Lock()
+	defer active_transfers_mutex.Unlock()
+
+	active_transfers[session_id]--
+	if active_transfers[session_id] <= 0 {
+		delete(active_transfers, session_id)
+	}
+}
+
+/*
+ * This function receives the chunks of an upload over a connection to the client, checks the file against its checksum
+ * and shares it in its channel. What arrived before the connection was lost is kept so the upload can be resumed
+ */
+func receive_upload(file Shared_file, address string, offset int64, session_id int64) {
+	defer end_transfer(session_id)
+	defer func() {
+		shared_files_mutex.Lock()
+		if shared, found := shared_files[file.Id]; found {
+			shared.uploading = false
+		}
+		shared_files_mutex.Unlock()
+	}()
+
+	// connecting to the client the same way as the data socket
+	connection, err := net.DialTimeout(SERVER_TYPE, address, TRANSFER_TIMEOUT)
+	if err != nil {
+		fmt.Println("system: Failed to open transfer socket for file #" + strconv.Itoa(file.Id))
+		return
+	}
+	defer connection.Close()
+	reader := bufio.NewReaderSize(connection, MAX_PACKET_SIZE)
+
+	// appending to what arrived before
+	part_path := get_file_path(file.Id) + PART_SUFFIX
+	part, err := os.OpenFile(part_path, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		abort_transfer(connection, file, offset, err)
+		return
+	}
+	if err := part.Truncate(offset); err != nil {
+		part.Close()
+		abort_transfer(connection, file, offset, err)
+		return
+	}
+	if _, err := part.Seek(offset, io.SeekStart); err != nil {
+		part.Close()
+		abort_transfer(connection, file, offset, err)
+		return
+	}
+
+	// writing chunks until the client says it is done
+	for {
+		connection.SetReadDeadline(time.Now().Add(TRANSFER_TIMEOUT))
+		json_data, amount_read := read_from_connection(reader)
+		if amount_read <= 0 {
+			part.Close()
+			fmt.Println("system: Upload of file #" + strconv.Itoa(file.Id) + " stopped at " + format_size(offset))
+			return
+		}
+
+		var chunk Transfer_chunk
+		if err := json.Unmarshal(json_data[:amount_read], &chunk); err != nil || chunk.Offset != offset || offset+int64(len(chunk.Data)) > file.Size {
+			part.Close()
+			write_transfer_chunk(connection, Transfer_chunk{Offset: offset, Error: "the server got an unexpected chunk"})
+			return
+		}
+		if chunk.Done {
+			break
+		}
+
+		if _, err := part.Write(chunk.Data); err != nil {
+			part.Close()
+			abort_transfer(connection, file, offset, err)
+			return
+		}
+		offset += int64(len(chunk.Data))
+	}
+	part.Close()
+
+	// making sure the whole file arrived unchanged
+	checksum, err := get_file_checksum(part_path)
+	if err != nil {
+		abort_transfer(connection, file, offset, err)
+		return
+	}
+	if offset != file.Size || checksum != file.Checksum {
+		remove_file(part_path)
+		shared_files_mutex.Lock()
+		delete(shared_files, file.Id)
+		save_files()
+		shared_files_mutex.Unlock()
+		write_transfer_chunk(connection, Transfer_chunk{Offset: 0, Error: "the file did not match its checksum, upload it again"})
+		return
+	}
+	if err := os.Rename(part_path, get_file_path(file.Id)); err != nil {
+		abort_transfer(connection, file, offset, err)
+		return
+	}
+
+	shared_files_mutex.Lock()
+	shared, found := shared_files[file.Id]
+	if found {
+		shared.Complete = true
+		shared.Uploaded = time.Now()
+		file = *shared
+		save_files()
+	}
+	shared_files_mutex.Unlock()
+
+	write_transfer_chunk(connection, Transfer_chunk{Offset: offset, Done: true})
+	post_file_message(file)
+}
+
+/*
+ * This function sends the chunks of a shared file over a connection to the client, starting at an offset
+ */
+func send_download(file Shared_file, address string, offset int64, session_id int64) {
+	defer end_transfer(session_id)
+
+	// connecting to the client the same way as the data socket
+	connection, err := net.DialTimeout(SERVER_TYPE, address, TRANSFER_TIMEOUT)
+	if err != nil {
+		fmt.Println("system: Failed to open transfer socket for file #" + strconv.Itoa(file.Id))
+		return
+	}
+	defer connection.Close()
+
+	contents, err := os.Open(get_file_path(file.Id))
+	if err != nil {
+		write_transfer_chunk(connection, Transfer_chunk{Offset: offset, Error: "the file is gone from the server"})
+		return
+	}
+	defer contents.Close()
+	if _, err := contents.Seek(offset, io.SeekStart); err != nil {
+		abort_transfer(connection, file, offset, err)
+		return
+	}
+
+	// sending the file a chunk at a time
+	buffer := make([]byte, TRANSFER_CHUNK_SIZE)
+	for {
+		amount_read, err := contents.Read(buffer)
+		if amount_read > 0 {
+			connection.SetWriteDeadline(time.Now().Add(TRANSFER_TIMEOUT))
+			if !write_transfer_chunk(connection, Transfer_chunk{Offset: offset, Data: buffer[:amount_read]}) {
+				return
+			}
+			offset += int64(amount_read)
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			abort_transfer(connection, file, offset, err)
+			return
+		}
+	}
+
+	write_transfer_chunk(connection, Transfer_chunk{Offset: offset, Done: true})
+}
+
+/*
+ * This function logs the disk error that stopped a transfer and tells the client the transfer failed.
+ * What was uploaded before the error is kept so the upload can be resumed
+ */
+func abort_transfer(connection net.Conn, file Shared_file, offset int64, err error) {
+	fmt.Println("system: Transfer of file #"+strconv.Itoa(file.Id)+" failed at "+format_size(offset)+" -", err)
+	write_transfer_chunk(connection, Transfer_chunk{Offset: offset, Error: "the server could not read or write the file, try again later"})
+}
+
+/*
+ * This function writes one chunk of a transfer to its connection
+ */
+func write_transfer_chunk(connection net.Conn, chunk Transfer_chunk) bool {
+	json_data, err := json.Marshal(chunk)
+	if err != nil {
+		error_exit(err)
+	}
+	return write_to_connection(connection, append(json_data, PACKET_DELIMITER))
+}
+
+/*
+ * This function sends the message that shares an uploaded file to its channel, from the user who uploaded it
+ */
+func post_file_message(file Shared_file) {
+	// images are pointed out since they are the most common thing to share
+	kind := "a file"
+	if is_image(get_file_path(file.Id)) {
+		kind = "an image"
+	}
+	text := "shared " + kind + ": " + file.Name + " (" + format_size(file.Size) + ") - /download " + strconv.Itoa(file.Id)
+
+	packet := Data_packet{Type: MESSAGE, Username: file.Uploader, Data: []byte(text), Message_id: next_message_id(), Sent: time.Now()}
+
+	// the channel may have been deleted while the file was uploading
+	channels_mutex.Lock()
+	channel := channels[file.Channel]
+	if channel.Id == -1 {
+		channels_mutex.Unlock()
+		return
+	}
+	add_to_history(channel, packet)
+	users := append([]int(nil), channel.Users...)
+	channels_mutex.Unlock()
+
+	// sending message to everyone in the chat, including the uploader
+	broadcast_data_packet(packet, users, -1)
+	archive_message(Archived_message{Message_id: packet.Message_id, Channel: file.Channel, Username: file.Uploader, Text: packet.Data, Sent: packet.Sent})
+}
+
+/*
+ * This function checks if a file starts like an image
+ */
+func is_image(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	// the content type is found from the first 512 bytes at most
+	header := make([]byte, 512)
+	amount_read, _ := file.Read(header)
+	return strings.HasPrefix(http.DetectContentType(header[:amount_read]), "image/")
+}
+
+/*
+ * This function gets the sha256 of a file as hex
+ */
+func get_file_checksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+/*
+ * This function keeps only the last part of a file name and takes out characters that would mess up the terminal.
+ * It returns an empty string for names that can't be used
+ */
+func clean_file_name(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(character rune) rune {
+		if unicode.IsControl(character) {
+			return -1
+		}
+		return character
+	}, name)
+	name = strings.TrimSpace(name)
+
+	if name == "." || name == "/" || !utf8.ValidString(name) || len(name) > MAX_FILE_NAME_LENGTH {
+		return ""
+	}
+	return name
+}
+
+/*
+ * This function formats a number of bytes for people to read
+ */
+func format_size(size int64) string {
+	if size < 1024 {
+		return strconv.FormatInt(size, 10) + " B"
+	} else if size < 1024*1024 {
+		return strconv.FormatFloat(float64(size)/1024, 'f', 1, 64) + " KB"
+	}
+	return strconv.FormatFloat(float64(size)/(1024*1024), 'f', 1, 64) + " MB"
+}
+
+/*
+ * This function gets where the contents of a shared file are kept
+ */
+func get_file_path(file_id int) string {
+	return filepath.Join(files_dir, strconv.Itoa(file_id))
+}
+
+/*
+ * This function adds up the space taken by shared files, counting unfinished uploads at their full size.
+ * The shared files mutex must be held by the caller
+ */
+func storage_used() int64 {
+	var used int64
+	for _, file := range shared_files {
+		used += file.Size
+	}
+	return used
+}
+
+/*
+ * This function adds up the space taken by the shared files of one user, counting unfinished uploads at their full size.
+ * The shared files mutex must be held by the caller
+ */
+func user_storage_used(username string) int64 {
+	var used int64
+	for _, file := range shared_files {
+		if file.Uploader == username {
+			used += file.Size
+		}
+	}
+	return used
+}
+
+/*
+ * This function forgets uploads that were not finished within UPLOAD_EXPIRY so their space can be used again.
+ * The shared files mutex must be held by the caller
+ */
+func remove_expired_uploads() {
+	removed := false
+	for id, file := range shared_files {
+		if !file.Complete && !file.uploading && time.Since(file.Uploaded) > UPLOAD_EXPIRY {
+			os.Remove(get_file_path(id) + PART_SUFFIX)
+			delete(shared_files, id)
+			removed = true
+		}
+	}
+	if removed {
+		save_files()
+	}
+}
+
+/*
+ * This function reads in the list of shared files and creates the directory they are kept in.
+ * The directory can be moved with the CHAT429_FILES_DIR environment variable
+ */
+func load_files() {
+	if dir := os.Getenv(FILES_DIR_ENV); dir != "" {
+		files_dir = dir
+	}
+
+	// only the server should be able to read files shared in private channels
+	if err := os.MkdirAll(files_dir, 0700); err != nil {
+		error_exit(err)
+	}
+
+	json_data, err := os.ReadFile(filepath.Join(files_dir, FILES_INDEX))
+	if err != nil {
+		// there are no shared files the first time the server runs
+		if os.IsNotExist(err) {
+			return
+		}
+		error_exit(err)
+	}
+
+	shared_files_mutex.Lock()
+	defer shared_files_mutex.Unlock()
+
+	// unmarshaling json data
+	if err := json.Unmarshal(json_data, &shared_files); err != nil {
+		error_exit(err)
+	}
+
+	// new files must not reuse the ids of old ones
+	for id := range shared_files {
+		last_file_id = max(last_file_id, id)
+	}
+
+	msg := GREEN + " - loaded " + strconv.Itoa(len(shared_files)) + " shared files\n" + RESET
+	fmt.Print(msg)
+}
+
+/*
+ * This function saves the list of shared files.
+ * The shared files mutex must be held by the caller
+ */
+func save_files() {
+	json_data, err := json.Marshal(shared_files)
+	if err != nil {
+		error_exit(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(files_dir, FILES_INDEX), json_data, 0600); err != nil {
+		error_exit(err)
+	}
+}
+
+/*
+ * This function signs a client out and sends it back to the sign in menu
+ */
+func sign_out(client Client) {
+	// removing client from its channel
+	if client.State == MESSAGING {
+		leave_channel(client)
+
+		// closing the client's routine for inbound messages
+		dpack := Data_packet{Type: CLOSE, Username: client.Account_info.Username, Data: []byte("Signing out")}
+		send_data_packet(dpack, client)
+	}
+
+	active_clients_mutex.Lock()
+	active_clients[client.Id].Account_info.Username = ""
+	active_clients[client.Id].Account_info.Password = ""
+	active_clients[client.Id].Account_info.Role = PUBLIC
+	active_clients[client.Id].Logged_in = false
+	active_clients[client.Id].token_id = ""
+	active_clients[client.Id].Current_channel = -1
+	active_clients[client.Id].State = CHOOSING_SIGN_IN_OPT
+	active_clients_mutex.Unlock()
+}
+
+/*
+ * This function checks permmisions and requriemtns and then attmp to ban a user from
+ */
+func ban_s_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = BAN_S
+	cpack.Username = client.Account_info.Username
+
+	if !authorize(client.Account_info.Username, PERM_BAN, CHANNEL_MEMBER) { // checking if you have permission
+		cpack.Arguments = []byte("You don't have permission to use this command")
+	} else if len(command.Args) < 1 { // checking if there are enough arguments
+		cpack.Arguments = []byte("Not enough arguments")
+	} else if len(command.Args) > 1 { // checking if there are too many arguments
+		cpack.Arguments = []byte("Too many arguments")
+	} else if client.Account_info.Username == command.Args[0] { // checking if you are trying to ban yourself
+		cpack.Arguments = []byte("Cannot ban yourself")
+	} else {
+		// getting the index of the user given their name
+		user_index := get_user_index(command.Args[0])
+
+		if user_index == -1 {
+			cpack.Arguments = []byte("Could not find a user with that name")
+		} else if command.Args[0] == "Admin" {
+			cpack.Arguments = []byte("Cannot ban the Admin")
+		} else if authorize(command.Args[0], PERM_BAN, CHANNEL_MEMBER) && !authorize(client.Account_info.Username, PERM_MANAGE_ROLES, CHANNEL_MEMBER) {
+			cpack.Arguments = []byte("Must be able to manage roles to ban a moderator")
+		} else {
+			// banning user from server
+			ban_from_server(user_index)
+			cpack.Arguments = []byte("Banned " + command.Args[0] + " from the server")
+		}
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function handles udpating the account to be banned
+ */
+func ban_from_server(user_index int) {
+	registered_accounts_mutex.Lock()
+	defer registered_accounts_mutex.Unlock()
+
+	registered_accounts[user_index].Banned = true
+
+	save_accounts()
+
+	// TODO: Disconnect the user from the server
+}
+
+/*
+ * This function checks if a set of roles grants a permission.
+ * It only uses what it is given and the fixed channel role permissions so it can be tested on its own
+ */
+func has_permission(roles map[string][]string, account_roles []string, channel_role int, permission string) bool {
+	// checking the roles of the account
+	for _, role := range account_roles {
+		if slices.Contains(roles[role], permission) {
+			return true
+		}
+	}
+
+	// checking the role the account has in the channel
+	return slices.Contains(channel_role_permissions[channel_role], permission)
+}
+
+/*
+ * This function is where every permission is checked.
+ * The channel role is the role the user has in the channel being acted on, or CHANNEL_MEMBER if there is none
+ */
+func authorize(username string, permission string, channel_role int) bool {
+	index := get_user_index(username)
+	if index == -1 {
+		return false
+	}
+
+	// getting the built in role and the custom roles of the account
+	registered_accounts_mutex.Lock()
+	account_roles := append([]string{role_names[registered_accounts[index].Role]}, registered_accounts[index].Roles...)
+	registered_accounts_mutex.Unlock()
+
+	custom_roles_mutex.Lock()
+	defer custom_roles_mutex.Unlock()
+
+	return has_permission(get_role_definitions(), account_roles, channel_role, permission)
+}
+
+/*
+ * This function checks a permission for an action on a channel.
+ * The channels mutex must be held by the caller
+ */
+func authorize_in_channel(username string, permission string, channel *Channel) bool {
+	return authorize(username, permission, get_channel_role(username, channel))
+}
+
+/*
+ * This function gets the permissions of every role, keyed by role name.
+ * The custom roles mutex must be held by the caller
+ */
+func get_role_definitions() map[string][]string {
+	roles := make(map[string][]string, len(builtin_roles)+len(custom_roles))
+	for name, permissions := range custom_roles {
+		roles[name] = permissions
+	}
+
+	// built in roles cannot be replaced by custom ones
+	for name, permissions := range builtin_roles {
+		roles[name] = permissions
+	}
+	return roles
+}
+
+/*
+ * This function ranks a user in a channel by what they are allowed to do in it.
+ * The channels mutex must be held by the caller
+ */
+func get_channel_rank(username string, channel *Channel) int {
+	if authorize_in_channel(username, PERM_MANAGE_CHANNEL_MODS, channel) {
+		return CHANNEL_OWNER
+	} else if authorize_in_channel(username, PERM_KICK, channel) {
+		return CHANNEL_MODERATOR
+	}
+	return CHANNEL_MEMBER
+}
+
+/*
+ * This function handles the roles command which lists every role and its permissions
+ */
+func roles_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = ROLES
+	cpack.Username = client.Account_info.Username
+
+	if !authorize(client.Account_info.Username, PERM_MANAGE_ROLES, CHANNEL_MEMBER) {
+		cpack.Arguments = []byte("You don't have permission to use this command")
+	} else if len(command.Args) > 0 {
+		cpack.Arguments = []byte("Too many arguments")
+	} else {
+		custom_roles_mutex.Lock()
+		roles := get_role_definitions()
+		custom_roles_mutex.Unlock()
+
+		// listing roles by name
+		var names []string
+		for name := range roles {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		var role_list []string
+		for _, name := range names {
+			permissions := "none"
+			if len(roles[name]) > 0 {
+				permissions = strings.Join(roles[name], ", ")
+			}
+			role_list = append(role_list, name+": "+permissions)
+		}
+		cpack.Arguments = []byte(strings.Join(role_list, " | "))
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function handles the create-role command which makes a role out of a set of permissions
+ */
+func create_role_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = CREATE_ROLE
+	cpack.Username = client.Account_info.Username
+
+	if !authorize(client.Account_info.Username, PERM_MANAGE_ROLES, CHANNEL_MEMBER) {
+		cpack.Arguments = []byte("You don't have permission to use this command")
+	} else if len(command.Args) < 2 {
+		cpack.Arguments = []byte("Usage: /create-role <name> <permission> ...")
+	} else if !role_name_regex.MatchString(command.Args[0]) {
+		cpack.Arguments = []byte("Role names may only have lowercase letters, numbers, - and _")
+	} else if unknown := slices.IndexFunc(command.Args[1:], func(permission string) bool { return !slices.Contains(permission_names, permission) }); unknown != -1 {
+		cpack.Arguments = []byte("Unknown permission \"" + command.Args[1+unknown] + "\", choose from " + strings.Join(permission_names, ", "))
 	} else {
-		// creating channel
-		var successful bool
-		cpack.Message, successful = create_channel(command)
+		custom_roles_mutex.Lock()
+		_, exists := get_role_definitions()[command.Args[0]]
+		if !exists {
+			custom_roles[command.Args[0]] = slices.Compact(slices.Sorted(slices.Values(command.Args[1:])))
+		}
+		custom_roles_mutex.Unlock()
 
-		// checking if the channel was created successfully
-		if successful {
-			// building a string from the channel array
-			var channel_list strings.Builder
-			channels_mutex.Lock()
-			for index, channel := range channels {
-				if channel.Id != -1 {
-					if index != 0 {
-						channel_list.WriteString(" ")
-					}
-					channel_list.WriteString(string(channel.Topic))
-				}
-			}
-			channels_mutex.Unlock()
+		if exists {
+			cpack.Arguments = []byte("A role already exists with the name " + command.Args[0])
+		} else {
+			save_roles()
+			cpack.Arguments = []byte("Created role " + command.Args[0])
+		}
+	}
 
-			cpack.Arguments = []byte(channel_list.String())
-			cpack.Successful = true
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function handles the delete-role command which removes a custom role from the server and every account
+ */
+func delete_role_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = DELETE_ROLE
+	cpack.Username = client.Account_info.Username
+
+	if !authorize(client.Account_info.Username, PERM_MANAGE_ROLES, CHANNEL_MEMBER) {
+		cpack.Arguments = []byte("You don't have permission to use this command")
+	} else if len(command.Args) != 1 {
+		cpack.Arguments = []byte("Usage: /delete-role <name>")
+	} else if _, builtin := builtin_roles[command.Args[0]]; builtin {
+		cpack.Arguments = []byte("Built in roles cannot be deleted")
+	} else {
+		custom_roles_mutex.Lock()
+		_, exists := custom_roles[command.Args[0]]
+		delete(custom_roles, command.Args[0])
+		custom_roles_mutex.Unlock()
+
+		if !exists {
+			cpack.Arguments = []byte("No role found with the name " + command.Args[0])
 		} else {
-			cpack.Arguments = nil
-			cpack.Successful = false
+			// taking the role away from everyone who had it
+			registered_accounts_mutex.Lock()
+			for index := range registered_accounts {
+				registered_accounts[index].Roles = slices.DeleteFunc(registered_accounts[index].Roles, func(role string) bool { return role == command.Args[0] })
+			}
+			save_accounts()
+			registered_accounts_mutex.Unlock()
+
+			save_roles()
+			cpack.Arguments = []byte("Deleted role " + command.Args[0])
 		}
 	}
 
-	// sending packet
 	send_command_packet(cpack, client)
 }
 
 /*
- * This function handles the main command
+ * This function handles the grant-role and revoke-role commands which give a custom role to an account or take it away
  */
-func main_command(client Client, command Parsed_command) {
-	// creating part of the return packet
+func assign_role_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
+
+	// creating return packet
 	var cpack Command_packet
-	cpack.Type = MAIN
+	cpack.Type = command.Type
 	cpack.Username = client.Account_info.Username
 
-	// checking if args were passed in with the command
-	if len(command.Args) > 0 {
-		cpack.Arguments = []byte("Too many arguments")
-	} else if leave_channel(client) {
-		update_client_state(client, IN_MAIN_MENU)
-		dpack := Data_packet{Type: CLOSE, Username: client.Account_info.Username, Data: []byte("Going to main menu")}
-		send_data_packet(dpack, client)
-		cpack.Arguments = []byte("Success")
+	// getting the role as it is
+	custom_roles_mutex.Lock()
+	role_exists := false
+	if len(command.Args) == 2 {
+		_, role_exists = custom_roles[command.Args[1]]
+	}
+	custom_roles_mutex.Unlock()
+
+	if !authorize(client.Account_info.Username, PERM_MANAGE_ROLES, CHANNEL_MEMBER) {
+		cpack.Arguments = []byte("You don't have permission to use this command")
+	} else if len(command.Args) != 2 && command.Type == GRANT_ROLE {
+		cpack.Arguments = []byte("Usage: /grant-role <username> <role>")
+	} else if len(command.Args) != 2 {
+		cpack.Arguments = []byte("Usage: /revoke-role <username> <role>")
+	} else if index := get_user_index(command.Args[0]); index == -1 {
+		cpack.Arguments = []byte("Could not find a user with that name")
+	} else if _, builtin := builtin_roles[command.Args[1]]; builtin {
+		cpack.Arguments = []byte("Built in roles are given with /add-mod and /rm-mod")
+	} else if !role_exists {
+		cpack.Arguments = []byte("No role found with the name " + command.Args[1])
 	} else {
-		cpack.Arguments = []byte("Failed to leave channel")
+		registered_accounts_mutex.Lock()
+		has_role := slices.Contains(registered_accounts[index].Roles, command.Args[1])
+		if command.Type == GRANT_ROLE && has_role {
+			cpack.Arguments = []byte(command.Args[0] + " already has the role " + command.Args[1])
+		} else if command.Type == GRANT_ROLE {
+			registered_accounts[index].Roles = append(registered_accounts[index].Roles, command.Args[1])
+			save_accounts()
+			cpack.Arguments = []byte("Gave " + command.Args[0] + " the role " + command.Args[1])
+		} else if !has_role {
+			cpack.Arguments = []byte(command.Args[0] + " does not have the role " + command.Args[1])
+		} else {
+			registered_accounts[index].Roles = slices.DeleteFunc(registered_accounts[index].Roles, func(role string) bool { return role == command.Args[1] })
+			save_accounts()
+			cpack.Arguments = []byte("Took the role " + command.Args[1] + " from " + command.Args[0])
+		}
+		registered_accounts_mutex.Unlock()
+	}
+
+	send_command_packet(cpack, client)
+}
+
+/*
+ * This function loads the custom roles saved the last time the server ran
+ */
+func load_roles() {
+	json_data, err := os.ReadFile(ROLES_PATH)
+	if err != nil {
+		// there are no custom roles the first time the server runs
+		if os.IsNotExist(err) {
+			return
+		}
+		error_exit(err)
+	}
+
+	custom_roles_mutex.Lock()
+	defer custom_roles_mutex.Unlock()
+
+	// unmarshaling json data
+	if err := json.Unmarshal(json_data, &custom_roles); err != nil {
+		error_exit(err)
+	}
+
+	msg := GREEN + " - loaded roles\n" + RESET
+	fmt.Print(msg)
+}
+
+/*
+ * This function saves the custom roles so they are kept after the server restarts
+ */
+func save_roles() {
+	custom_roles_mutex.Lock()
+	json_data, err := json.Marshal(custom_roles)
+	custom_roles_mutex.Unlock()
+	if err != nil {
+		error_exit(err)
+	}
+
+	if err := os.WriteFile(ROLES_PATH, json_data, 0644); err != nil {
+		error_exit(err)
+	}
+}
+
+/*
+ * This function reads in the channels that were created before the server restarted
+ */
+func load_channels() {
+	json_data, err := os.ReadFile(CHANNELS_PATH)
+	if err != nil {
+		// there are no saved channels the first time the server runs
+		if os.IsNotExist(err) {
+			return
+		}
+		error_exit(err)
+	}
+
+	// unmarshaling json data
+	var saved []Channel
+	if err := json.Unmarshal(json_data, &saved); err != nil {
+		error_exit(err)
+	}
+
+	channels_mutex.Lock()
+	defer channels_mutex.Unlock()
+
+	// putting every channel back in its own slot, so its id stays the same
+	for index := range saved {
+		if saved[index].Id >= 0 && saved[index].Id < MAX_CHANNELS {
+			channels[saved[index].Id] = &saved[index]
+		}
+	}
+
+	msg := GREEN + " - loaded channels\n" + RESET
+	fmt.Print(msg)
+}
+
+/*
+ * This function saves every channel so they are kept after the server restarts.
+ * The channels mutex must be held by the caller
+ */
+func save_channels() {
+	var saved []*Channel
+	for _, channel := range channels {
+		if channel.Id != -1 {
+			saved = append(saved, channel)
+		}
+	}
+
+	json_data, err := json.Marshal(saved)
+	if err != nil {
+		error_exit(err)
+	}
+
+	// channels hold password hashes and the members of private channels
+	if err := os.WriteFile(CHANNELS_PATH, json_data, 0600); err != nil {
+		error_exit(err)
+	}
+}
+
+/*
+ * This function gets the index of an account given the accounts username
+ */
+func get_user_index(username string) int {
+	registered_accounts_mutex.Lock()
+	defer registered_accounts_mutex.Unlock()
+
+	// looping over registered accounts
+	for index, user := range registered_accounts {
+		if user.Username == username {
+			return index
+		}
+	}
+
+	return -1
+}
+
+/*
+ * This function prints data packets
+ */
+func print_data_packet(packet Data_packet) {
+	fmt.Println("---------------------------------------------------")
+	fmt.Printf(" - TYPE\t\t%d\n", packet.Type)
+	fmt.Printf(" - Username\t\t%s\n", packet.Username)
+	fmt.Printf(" - DATA\t\t%s\n", string(packet.Data))
+	fmt.Println("---------------------------------------------------")
+}
+
+/*
+ * This function clears the terminal
+ */
+func clear_terminal() {
+	cmd := exec.Command("clear")
+	cmd.Stdout = os.Stdout
+	cmd.Run()
+}
+
+/*
+ * This function creates a channel
+ */
+func create_channel(client Client, command Parsed_command) ([]byte, bool) {
+	// creating channel struct
+	channel := Channel{Name: command.Args[0], Topic: []byte(""), Users: nil, Members: []string{client.Account_info.Username}, Owner: client.Account_info.Username}
+
+	// setting visibility of the channel
+	if len(command.Args) > 1 {
+		switch command.Args[1] {
+		case "public":
+			channel.Visibility = CHANNEL_PUBLIC
+		case "invite":
+			channel.Visibility = CHANNEL_INVITE_ONLY
+		case "password":
+			if len(command.Args) < 3 || command.Args[2] == "" {
+				return []byte("Password protected channels need a password"), false
+			}
+			channel.Visibility = CHANNEL_PASSWORD
+			channel.Password = hash_channel_password(strings.Join(command.Args[2:], ":"))
+		default:
+			return []byte("Visibility must be public, invite or password"), false
+		}
+	}
+
+	for _, channel := range channels {
+		if channel.Name == string(command.Args[0]) {
+			return []byte("A channel already exists with the name"), false
+		}
+	}
+
+	// finding free slot for channel
+	free_slot_index := find_free_channel_slot()
+	if free_slot_index == -1 {
+		return []byte("Maximum number of channels already exist"), false
+	}
+
+	// adding channel to array, its slot is its id so read markers of different channels stay apart
+	channels_mutex.Lock()
+	defer channels_mutex.Unlock()
+	channel.Id = free_slot_index
+	channels[free_slot_index] = &channel
+	save_channels()
+
+	// returning success message
+	return []byte("Successfull added a channel with name #" + command.Args[0]), true
+}
+
+/*
+ * This function checks if a user may see and join a channel.
+ * The channels mutex must be held by the caller
+ */
+func can_access_channel(username string, channel *Channel) bool {
+	if channel.Id == -1 {
+		return false
+	}
+	return channel.Visibility == CHANNEL_PUBLIC || slices.Contains(channel.Members, username) || authorize(username, PERM_ACCESS_CHANNELS, CHANNEL_MEMBER)
+}
+
+/*
+ * This function gets the indexes of the channels a client may see.
+ * The channels mutex must be held by the caller
+ */
+func get_visible_channels(client Client) []int {
+	var visible []int
+	for index, channel := range channels {
+		if can_access_channel(client.Account_info.Username, channel) {
+			visible = append(visible, index)
+		}
+	}
+	return visible
+}
+
+/*
+ * This function builds the list of channel names a client may see
+ */
+func get_channel_list(client Client) string {
+	channels_mutex.Lock()
+	defer channels_mutex.Unlock()
+
+	var channel_list strings.Builder
+	for position, index := range get_visible_channels(client) {
+		if position != 0 {
+			channel_list.WriteString(" ")
+		}
+		channel_list.WriteString(channels[index].Name)
+	}
+	return channel_list.String()
+}
+
+/*
+ * This function counts the unread messages and unread mentions in each channel a client may see,
+ * in the same order as the channel list
+ */
+func get_unread_counts(client Client) ([]int, []int) {
+	channels_mutex.Lock()
+	defer channels_mutex.Unlock()
+
+	var unread []int
+	var unread_mentions []int
+	for _, index := range get_visible_channels(client) {
+		messages, mentions := count_unread(client.Account_info.Username, channels[index])
+		unread = append(unread, messages)
+		unread_mentions = append(unread_mentions, mentions)
+	}
+	return unread, unread_mentions
+}
+
+/*
+ * This function counts the messages in a channel a user has not read and how many of them mention the user.
+ * The channels mutex must be held by the caller
+ */
+func count_unread(username string, channel *Channel) (int, int) {
+	read_markers_mutex.Lock()
+	last_read := read_markers[username][channel.Id]
+	read_markers_mutex.Unlock()
+
+	unread := 0
+	mentions := 0
+	for _, packet := range channel.History {
+		// the user's own messages and joining and leaving messages are never unread
+		if packet.Message_id <= last_read || packet.Type != MESSAGE || packet.Deleted || packet.Username == username {
+			continue
+		}
+		unread++
+		if slices.Contains(packet.Mentions, username) {
+			mentions++
+		}
 	}
-
-	// sending resonse to client
-	send_command_packet(cpack, client)
+	return unread, mentions
 }
 
 /*
- *
+ * This function moves the read marker of a user in a channel to the newest message and returns where it was.
+ * The channels mutex must be held by the caller
  */
-func change_topic_command(client Client, command Parsed_command) {
-	// updating client struct
-	client = update_client(client)
-
-	var cpack Command_packet
-	cpack.Type = CHANGE_TOPIC
-	cpack.Username = client.Account_info.Username
-
-	// ensuring proper arguments, permissions, and other requirements
-	if client.Account_info.Role <= PUBLIC {
-		cpack.Arguments = []byte("You don't have permission to use this command")
-	} else if len(command.Args) < 2 {
-		cpack.Arguments = []byte("Not enough arguments")
-	} else if len(command.Args) > 2 {
-		cpack.Arguments = []byte("Too many arguments")
-	} else if command.Args[0] == "nonsense" {
-		cpack.Arguments = []byte("Default channel. Cannot change this channel's topic")
-	} else {
-		index := get_channel_id([]byte(command.Args[0]))
-
-		channels_mutex.Lock()
-		defer channels_mutex.Unlock()
-
-		// checking if the channel exists
-		if index == -1 {
-			cpack.Arguments = []byte("No chat found with the name \"" + string(command.Args[1]) + "\"")
-		} else {
-			channels[index].Topic = []byte(command.Args[1])
-			cpack.Arguments = []byte("Successfully changed channel topic to #" + command.Args[1])
-
-			var refresh_packet Data_packet
-			refresh_packet.Type = REFRESH
-			refresh_packet.Data = []byte(command.Args[1])
+func mark_channel_read(username string, channel *Channel) int {
+	newest := 0
+	if len(channel.History) > 0 {
+		newest = channel.History[len(channel.History)-1].Message_id
+	}
 
-			active_clients_mutex.Lock()
-			for _, user := range channels[client.Current_channel].Users {
-				send_data_packet(refresh_packet, *active_clients[user])
-			}
-			active_clients_mutex.Unlock()
+	read_markers_mutex.Lock()
+	defer read_markers_mutex.Unlock()
 
-		}
+	if read_markers[username] == nil {
+		read_markers[username] = make(map[int]int)
 	}
+	last_read := read_markers[username][channel.Id]
+	if newest > last_read {
+		read_markers[username][channel.Id] = newest
+		read_markers_changed = true
+	}
+	return last_read
+}
 
-	// sending response to server
-	send_command_packet(cpack, client)
+/*
+ * This function hashes the password of a channel
+ */
+func hash_channel_password(password string) []byte {
+	hash := sha256.Sum256([]byte(password))
+	return hash[:]
 }
 
 /*
- * This function gives the moderator role to a user
+ * This function handles the join command which lets a user into a password protected channel
  */
-func add_mod_command(client Client, command Parsed_command) {
+func join_command(client Client, command Parsed_command) {
 	// updating client struct
 	client = update_client(client)
 
 	// creating return packet
 	var cpack Command_packet
-	cpack.Type = ADD_MOD
+	cpack.Type = JOIN
 	cpack.Username = client.Account_info.Username
 
-	// checking requirements
-	if client.Account_info.Role < ADMIN {
-		cpack.Arguments = []byte("You don't have permission to use this command")
+	if !client.Logged_in {
+		cpack.Message = []byte("You are not logged in")
 	} else if len(command.Args) < 1 {
-		cpack.Arguments = []byte("Not enough arguments")
-	} else if len(command.Args) > 1 {
-		cpack.Arguments = []byte("Too many arguments")
+		cpack.Message = []byte("Usage: /join <channel> <password>")
 	} else {
+		index := get_channel_id(command.Args[0])
+		password := strings.Join(command.Args[1:], ":")
 
-		active_clients_mutex.Lock()
-		found_1 := false
-		found_2 := false
-
-		// finding account
-		for _, user := range active_clients {
-			if user.Account_info.Username == command.Args[0] {
-				user.Account_info.Role = 1
-				found_1 = true
-
-				registered_accounts_mutex.Lock()
-				for index, user := range registered_accounts {
-					if user.Username == command.Args[0] {
-						registered_accounts[index].Role = 1
-						found_2 = true
-						break
-					}
-				}
-				registered_accounts_mutex.Unlock()
-				break
-			}
+		channels_mutex.Lock()
+		if index == -1 || (channels[index].Visibility == CHANNEL_INVITE_ONLY && !can_access_channel(client.Account_info.Username, channels[index])) {
+			// invite only channels are not admitted to
+			cpack.Message = []byte("No channel found with the name \"" + command.Args[0] + "\"")
+		} else if can_access_channel(client.Account_info.Username, channels[index]) {
+			cpack.Message = []byte("You can already join #" + command.Args[0] + " from the main menu")
+		} else if !hmac.Equal(hash_channel_password(password), channels[index].Password) {
+			cpack.Message = []byte("Wrong password for #" + command.Args[0])
+		} else {
+			channels[index].Members = append(channels[index].Members, client.Account_info.Username)
+			save_channels()
+			cpack.Message = []byte("Joined #" + command.Args[0] + ", it is now in your main menu")
+			cpack.Successful = true
 		}
-		active_clients_mutex.Unlock()
+		channels_mutex.Unlock()
 
-		// checking if account exists
-		if found_1 && found_2 {
-			cpack.Arguments = []byte("Successfully gave " + command.Args[0] + " the moderator role")
-		} else {
-			cpack.Arguments = []byte("Did not find account with that name")
+		// sending updated channel list
+		if cpack.Successful {
+			cpack.Arguments = []byte(get_channel_list(client))
 		}
 	}
 
-	fmt.Println("system: sending command status")
-
-	// sending response
 	send_command_packet(cpack, client)
-
-	// saving changes to account
-	save_accounts()
 }
 
 /*
- * This function gives the moderator role to a user
+ * This function handles the invite command which adds a user to the members of the current channel
  */
-func rm_mod_command(client Client, command Parsed_command) {
+func invite_command(client Client, command Parsed_command) {
 	// updating client struct
 	client = update_client(client)
 
+	// creating return packet
 	var cpack Command_packet
-	cpack.Type = ADD_MOD
+	cpack.Type = INVITE
 	cpack.Username = client.Account_info.Username
 
-	if client.Account_info.Role < ADMIN {
-		cpack.Arguments = []byte("You don't have permission to use this command")
-	} else if len(command.Args) < 1 {
-		cpack.Arguments = []byte("Not enough arguments")
-	} else if len(command.Args) > 1 {
-		cpack.Arguments = []byte("Too many arguments")
+	if client.State != MESSAGING {
+		cpack.Arguments = []byte("You are not in a channel")
+	} else if len(command.Args) != 1 {
+		cpack.Arguments = []byte("Usage: /invite <user>")
+	} else if get_user_index(command.Args[0]) == -1 {
+		cpack.Arguments = []byte("Could not find a user with that name")
 	} else {
+		invited := false
 
-		active_clients_mutex.Lock()
-		for _, user := range active_clients {
-			if user.Account_info.Username == command.Args[0] {
-				user.Account_info.Role = 0
+		channels_mutex.Lock()
+		channel := channels[client.Current_channel]
+		channel_name := channel.Name
+		if channel.Visibility == CHANNEL_PUBLIC {
+			cpack.Arguments = []byte("#" + channel_name + " is public, anyone can join it")
+		} else if !slices.Contains(channel.Members, client.Account_info.Username) && !authorize_in_channel(client.Account_info.Username, PERM_INVITE, channel) {
+			cpack.Arguments = []byte("You don't have permission to use this command")
+		} else if slices.Contains(channel.Members, command.Args[0]) {
+			cpack.Arguments = []byte(command.Args[0] + " is already a member of #" + channel_name)
+		} else {
+			channel.Members = append(channel.Members, command.Args[0])
+			save_channels()
+			cpack.Arguments = []byte("Invited " + command.Args[0] + " to #" + channel_name)
+			invited = true
+		}
+		channels_mutex.Unlock()
 
-				registered_accounts_mutex.Lock()
-				for _, user := range registered_accounts {
-					user.Role = 0
-				}
-				registered_accounts_mutex.Unlock()
+		// letting the user know about the invite, even if they are offline
+		if invited {
+			direct_message := Direct_message{
+				Id:   next_message_id(),
+				From: client.Account_info.Username,
+				To:   command.Args[0],
+				Text: []byte("Invited you to #" + channel_name),
+				Sent: time.Now(),
+			}
+			if deliver_direct_message(direct_message, -1) == 0 {
+				store_pending_direct_message(direct_message)
 			}
 		}
-		active_clients_mutex.Unlock()
-
-		cpack.Arguments = []byte("Successfully removed the moderator role from " + command.Args[0])
 	}
 
-	// sending response
 	send_command_packet(cpack, client)
-
-	// saving account changes
-	save_accounts()
 }
 
 /*
- * This function checks permmisions and requriemtns and then attmp to ban a user from
+ * This function handles the kick command which removes a user from the current channel
  */
-func ban_s_command(client Client, command Parsed_command) {
+func kick_command(client Client, command Parsed_command) {
 	// updating client struct
 	client = update_client(client)
 
 	// creating return packet
 	var cpack Command_packet
-	cpack.Type = BAN_S
+	cpack.Type = KICK
 	cpack.Username = client.Account_info.Username
 
-	if client.Account_info.Role < MODERATOR { // checking if you have permission
-		cpack.Arguments = []byte("You don't have permission to use this command")
-	} else if len(command.Args) < 1 { // checking if there are enough arguments
-		cpack.Arguments = []byte("Not enough arguments")
-	} else if len(command.Args) > 1 { // checking if there are too many arguments
-		cpack.Arguments = []byte("Too many arguments")
-	} else if client.Account_info.Username == command.Args[0] { // checking if you are trying to ban yourself
-		cpack.Arguments = []byte("Cannot ban yourself")
+	if client.State != MESSAGING {
+		cpack.Arguments = []byte("You are not in a channel")
+	} else if len(command.Args) != 1 {
+		cpack.Arguments = []byte("Usage: /kick <user>")
+	} else if command.Args[0] == client.Account_info.Username {
+		cpack.Arguments = []byte("Cannot kick yourself")
+	} else if index := get_user_index(command.Args[0]); index == -1 {
+		cpack.Arguments = []byte("Could not find a user with that name")
 	} else {
-		// getting the index of the user given their name
-		user_index := get_user_index(command.Args[0])
+		// getting the roles of both users in the channel
+		channels_mutex.Lock()
+		channel := channels[client.Current_channel]
+		kicker_role := get_channel_rank(client.Account_info.Username, channel)
+		kicked_role := get_channel_rank(command.Args[0], channel)
+		channel_name := channel.Name
+		channels_mutex.Unlock()
 
-		if user_index == -1 {
-			cpack.Arguments = []byte("Could not find a user with that name")
-		} else if client.Account_info.Role == ADMIN {
-			// banning user from server
-			ban_from_server(user_index)
-			cpack.Arguments = []byte("Banned " + command.Args[0] + " from the server")
+		// users can only be kicked by someone above them in the channel
+		if kicker_role < CHANNEL_MODERATOR {
+			cpack.Arguments = []byte("You don't have permission to use this command")
+		} else if kicked_role >= kicker_role {
+			cpack.Arguments = []byte("You can't kick " + command.Args[0] + " from #" + channel_name)
 		} else {
-			if is_public(user_index) {
-				ban_from_server(user_index)
-				cpack.Arguments = []byte("Banned " + command.Args[0] + " from the server")
-			} else if command.Args[0] == "Admin" {
-				cpack.Arguments = []byte("Cannot ban the Admin")
-			} else {
-				cpack.Arguments = []byte("Must be Admin to ban a moderator")
+			removed := remove_from_channel(client.Current_channel, command.Args[0])
+			cpack.Arguments = []byte("Removed " + command.Args[0] + " from #" + channel_name)
+			if removed > 0 {
+				cpack.Arguments = []byte("Removed " + command.Args[0] + " from #" + channel_name + " and sent them to the main menu")
 			}
+			audit_log("kick of " + command.Args[0] + " from #" + channel_name + " by " + client.Account_info.Username)
 		}
 	}
 
@@ -1791,92 +6596,130 @@ func ban_s_command(client Client, command Parsed_command) {
 }
 
 /*
- * This function handles udpating the account to be banned
+ * This function gets the role a user has in a channel from its owner and moderators.
+ * The channels mutex must be held by the caller
  */
-func ban_from_server(user_index int) {
-	registered_accounts_mutex.Lock()
-	defer registered_accounts_mutex.Unlock()
-
-	registered_accounts[user_index].Banned = true
-
-	save_accounts()
-
-	// TODO: Disconnect the user from the server
+func get_channel_role(username string, channel *Channel) int {
+	if channel.Owner != "" && channel.Owner == username {
+		return CHANNEL_OWNER
+	} else if slices.Contains(channel.Moderators, username) {
+		return CHANNEL_MODERATOR
+	}
+	return CHANNEL_MEMBER
 }
 
 /*
- * This function checks if an account has the public role
+ * This function handles the add-channel-mod command which makes a user a moderator of the current channel
  */
-func is_public(user_index int) bool {
-	registered_accounts_mutex.Lock()
-	defer registered_accounts_mutex.Unlock()
+func add_channel_mod_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
 
-	return registered_accounts[user_index].Role == PUBLIC
-}
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = ADD_CHANNEL_MOD
+	cpack.Username = client.Account_info.Username
 
-/*
- * This function gets the index of an account given the accounts username
- */
-func get_user_index(username string) int {
-	registered_accounts_mutex.Lock()
-	defer registered_accounts_mutex.Unlock()
+	if client.State != MESSAGING {
+		cpack.Arguments = []byte("You are not in a channel")
+	} else if len(command.Args) != 1 {
+		cpack.Arguments = []byte("Usage: /add-channel-mod <username>")
+	} else if get_user_index(command.Args[0]) == -1 {
+		cpack.Arguments = []byte("Could not find a user with that name")
+	} else {
+		channels_mutex.Lock()
+		channel := channels[client.Current_channel]
+		channel_name := channel.Name
+		if !authorize_in_channel(client.Account_info.Username, PERM_MANAGE_CHANNEL_MODS, channel) {
+			cpack.Arguments = []byte("Only the owner of #" + channel_name + " can appoint moderators")
+		} else if get_channel_role(command.Args[0], channel) != CHANNEL_MEMBER {
+			cpack.Arguments = []byte(command.Args[0] + " already moderates #" + channel_name)
+		} else {
+			channel.Moderators = append(channel.Moderators, command.Args[0])
 
-	// looping over registered accounts
-	for index, user := range registered_accounts {
-		if user.Username == username {
-			return index
+			// moderators of a channel that is not public need to be able to get in
+			if !slices.Contains(channel.Members, command.Args[0]) {
+				channel.Members = append(channel.Members, command.Args[0])
+			}
+			save_channels()
+			cpack.Arguments = []byte(command.Args[0] + " is now a moderator of #" + channel_name)
 		}
+		channels_mutex.Unlock()
 	}
 
-	return -1
+	send_command_packet(cpack, client)
 }
 
 /*
- * This function prints data packets
+ * This function handles the rm-channel-mod command which takes a moderator of the current channel back to a member
  */
-func print_data_packet(packet Data_packet) {
-	fmt.Println("---------------------------------------------------")
-	fmt.Printf(" - TYPE\t\t%d\n", packet.Type)
-	fmt.Printf(" - Username\t\t%s\n", packet.Username)
-	fmt.Printf(" - DATA\t\t%s\n", string(packet.Data))
-	fmt.Println("---------------------------------------------------")
-}
+func rm_channel_mod_command(client Client, command Parsed_command) {
+	// updating client struct
+	client = update_client(client)
 
-/*
- * This function clears the terminal
- */
-func clear_terminal() {
-	cmd := exec.Command("clear")
-	cmd.Stdout = os.Stdout
-	cmd.Run()
+	// creating return packet
+	var cpack Command_packet
+	cpack.Type = RM_CHANNEL_MOD
+	cpack.Username = client.Account_info.Username
+
+	if client.State != MESSAGING {
+		cpack.Arguments = []byte("You are not in a channel")
+	} else if len(command.Args) != 1 {
+		cpack.Arguments = []byte("Usage: /rm-channel-mod <username>")
+	} else {
+		channels_mutex.Lock()
+		channel := channels[client.Current_channel]
+		channel_name := channel.Name
+		if !authorize_in_channel(client.Account_info.Username, PERM_MANAGE_CHANNEL_MODS, channel) {
+			cpack.Arguments = []byte("Only the owner of #" + channel_name + " can remove moderators")
+		} else if get_channel_role(command.Args[0], channel) != CHANNEL_MODERATOR {
+			cpack.Arguments = []byte(command.Args[0] + " is not a moderator of #" + channel_name)
+		} else {
+			channel.Moderators = slices.DeleteFunc(channel.Moderators, func(moderator string) bool { return moderator == command.Args[0] })
+			save_channels()
+			cpack.Arguments = []byte(command.Args[0] + " is no longer a moderator of #" + channel_name)
+		}
+		channels_mutex.Unlock()
+	}
+
+	send_command_packet(cpack, client)
 }
 
 /*
- * This function creates a channel
+ * This function takes a user off the members of a channel and sends every session of theirs in it to the main menu.
+ * It returns the number of sessions that were sent to the main menu
  */
-func create_channel(command Parsed_command) ([]byte, bool) {
-	// creating channel struct
-	channel := Channel{Topic: []byte(command.Args[0]), Users: nil}
-
-	for _, channel := range channels {
-		if string(channel.Topic) == string(command.Args[0]) {
-			return []byte("A channel already exists with the name"), false
+func remove_from_channel(channel_id int, username string) int {
+	// removing membership
+	channels_mutex.Lock()
+	channel := channels[channel_id]
+	channel.Members = slices.DeleteFunc(channel.Members, func(member string) bool { return member == username })
+	channel.Moderators = slices.DeleteFunc(channel.Moderators, func(moderator string) bool { return moderator == username })
+	channel_name := channel.Name
+	save_channels()
+
+	// gathering the user's sessions in the channel
+	var sessions []Client
+	active_clients_mutex.Lock()
+	for _, user := range channel.Users {
+		if active_clients[user].Account_info.Username == username {
+			sessions = append(sessions, *active_clients[user])
 		}
 	}
+	active_clients_mutex.Unlock()
+	channels_mutex.Unlock()
 
-	// finding free slot for channel
-	free_slot_index := find_free_channel_slot()
-	if free_slot_index == -1 {
-		return []byte("Maximum number of channels already exist"), false
-	}
+	for _, session := range sessions {
+		// leaving channel and going to the main menu
+		leave_channel(session)
+		update_client_state(session, IN_MAIN_MENU)
+		send_data_packet(Data_packet{Type: CLOSE, Username: username, Data: []byte("Removed from channel")}, session)
 
-	// adding channel to array
-	channels_mutex.Lock()
-	defer channels_mutex.Unlock()
-	channels[free_slot_index] = &channel
+		// telling the client why it was sent to the main menu
+		queue_command_packet(Command_packet{Type: REMOVED, Username: username, Arguments: []byte("You were removed from #" + channel_name)}, session)
+	}
 
-	// returning success message
-	return []byte("Successfull added a channel with topic #" + command.Args[0]), true
+	return len(sessions)
 }
 
 /*
@@ -1908,26 +6751,15 @@ func main_menu(client Client) {
 		return
 	}
 
-	// validating packet adn confirming client is ready
+	// skipping packets sent from a channel the client has just been turned away from
 	if data_packet.Type != MAIN || string(data_packet.Data) != "READY" {
-		custom_error_exit(OUT_OF_SYNC)
-	}
-
-	// sending channels to client
-	var channel_list strings.Builder
-	channels_mutex.Lock()
-	for index, channel := range channels {
-		if channel.Id != -1 {
-			if index != 0 {
-				channel_list.WriteString(" ")
-			}
-			channel_list.WriteString(string(channel.Topic))
-		}
+		fmt.Printf("system: Ignoring packet of type %d from client #%d in the main menu\n", data_packet.Type, client.Id)
+		return
 	}
-	channels_mutex.Unlock()
 
-	// preparing packet
-	data_packet = Data_packet{Type: MAIN, Username: client.Account_info.Username, Data: []byte(channel_list.String())}
+	// sending the channels the client can see and what it has not read in them
+	data_packet = Data_packet{Type: MAIN, Username: client.Account_info.Username, Data: []byte(get_channel_list(client))}
+	data_packet.Unread, data_packet.Unread_mentions = get_unread_counts(client)
 	send_data_packet(data_packet, client)
 
 	// reading packet from client
@@ -1940,29 +6772,36 @@ func main_menu(client Client) {
 
 	// checking if the packet has the expected type
 	if packet.Type != MENU_OPTION {
-		custom_error_exit(OUT_OF_SYNC)
+		fmt.Printf("system: Ignoring packet of type %d from client #%d in the main menu\n", packet.Type, client.Id)
+		return
 	}
 
-	// converting string to int
-	user_choice, err := strconv.Atoi(string(packet.Data))
-	if err != nil {
-		error_exit(err)
+	// joining the channel the client chose by name, the list it was sent may have changed since
+	client = update_client(client)
+	if !join_channel(client, string(packet.Data)) {
+		packet = Data_packet{Type: DENY, Username: client.Account_info.Username, Data: []byte("#" + string(packet.Data) + " is no longer available")}
+		send_data_packet(packet, client)
+		return
 	}
 
-	// joining a channel
-	join_channel(client, user_choice)
-
 	// updating client status
 	update_client_state(client, MESSAGING)
 }
 
 /*
- * This function joins a specific channel
+ * This function joins a channel by name. It returns false if the channel does not exist or the client
+ * may not access it
  */
-func join_channel(client Client, channel_id int) {
+func join_channel(client Client, name string) bool {
 	channels_mutex.Lock()
 	defer channels_mutex.Unlock()
 
+	// finding the channel and checking the client may still see it
+	channel_id := slices.IndexFunc(channels, func(channel *Channel) bool { return channel.Name == name })
+	if channel_id == -1 || !can_access_channel(client.Account_info.Username, channels[channel_id]) {
+		return false
+	}
+
 	// adding user id to list of users in channel
 	channels[channel_id].Users = append(channels[channel_id].Users, client.Id)
 
@@ -1970,8 +6809,33 @@ func join_channel(client Client, channel_id int) {
 	active_clients[client.Id].Current_channel = channel_id
 	client.Current_channel = channel_id
 	active_clients_mutex.Unlock()
+
+	// sending the history of the channel and the last message the user read, so the client can open the channel there.
+	// They are queued while the channel is locked so no message sent to the channel meanwhile can get ahead of them
+	send_data_packet(build_history_packet(channels[channel_id].History, 0), client)
+	last_read := mark_channel_read(client.Account_info.Username, channels[channel_id])
+	send_data_packet(Data_packet{Type: READ_MARKER, Message_id: last_read}, client)
+	send_data_packet(get_channel_info(channels[channel_id]), client)
+
+	// only announcing the account's first session in the channel
+	if has_other_session_in_channel(client, channel_id) {
+		return true
+	}
+
 	msg := "\n" + client.Account_info.Username + " has joined the chat\n" + time.Now().Format("3:04 PM") + "\n"
 	send_message(client, JOIN_MSG, msg)
+	return true
+}
+
+/*
+ * This function updates a client struct and reports if it still belongs to the same session
+ */
+func update_session(client Client) (Client, bool) {
+	active_clients_mutex.Lock()
+	defer active_clients_mutex.Unlock()
+
+	current := *active_clients[client.Id]
+	return current, current.session_id == client.session_id
 }
 
 /*
@@ -1997,10 +6861,14 @@ func leave_channel(client Client) bool {
 	for index, user := range channels[client.Current_channel].Users {
 		// checking if we found the user
 		if user == client.Id {
+			// everything sent while the user was in the channel has been read
+			mark_channel_read(client.Account_info.Username, channels[client.Current_channel])
 
-			// sending leaving message
-			msg := "\n" + client.Account_info.Username + " has left the chat\n" + time.Now().Format("3:04 PM") + "\n"
-			send_message(client, LEAVE_MSG, msg)
+			// sending leaving message once the account's last session leaves
+			if !has_other_session_in_channel(client, client.Current_channel) {
+				msg := "\n" + client.Account_info.Username + " has left the chat\n" + time.Now().Format("3:04 PM") + "\n"
+				send_message(client, LEAVE_MSG, msg)
+			}
 
 			// removing user from channel
 			if index+1 == len(channels[client.Current_channel].Users) {
@@ -2035,25 +6903,20 @@ func send_message(client Client, msg_type int, msg string) {
 	}
 
 	// sending message to channel
-	active_clients_mutex.Lock()
-	for _, user := range channels[client.Current_channel].Users {
-		if user != client.Id {
-			send_data_packet(packet, *active_clients[user])
-		}
-	}
-	active_clients_mutex.Unlock()
+	users := append([]int(nil), channels[client.Current_channel].Users...)
+	broadcast_data_packet(packet, users, client.Id)
 }
 
 /*
  * This function gets a channel ID given a name
  */
-func get_channel_id(topic []byte) int {
+func get_channel_id(name string) int {
 	channels_mutex.Lock()
 	defer channels_mutex.Unlock()
 
 	// looping over channels
 	for index, channel := range channels {
-		if string(channel.Topic) == string(topic) {
+		if channel.Name == name {
 			return index
 		}
 	}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+/*
+ * This function tests which permissions built in roles, custom roles and channel roles grant
+ */
+func Test_has_permission(t *testing.T) {
+	roles := map[string][]string{
+		"public":     builtin_roles["public"],
+		"moderator":  builtin_roles["moderator"],
+		"admin":      builtin_roles["admin"],
+		"pinner":     {PERM_PIN_MESSAGES},
+		"gatekeeper": {PERM_MANAGE_LOCKOUTS, PERM_ACCESS_CHANNELS},
+	}
+
+	tests := []struct {
+		name          string
+		account_roles []string
+		channel_role  int
+		permission    string
+		want          bool
+	}{
+		// built in roles
+		{"public may not ban", []string{"public"}, CHANNEL_MEMBER, PERM_BAN, false},
+		{"public may not create channels", []string{"public"}, CHANNEL_MEMBER, PERM_CREATE_CHANNEL, false},
+		{"moderator may ban", []string{"moderator"}, CHANNEL_MEMBER, PERM_BAN, true},
+		{"moderator may create channels", []string{"moderator"}, CHANNEL_MEMBER, PERM_CREATE_CHANNEL, true},
+		{"moderator may not manage roles", []string{"moderator"}, CHANNEL_MEMBER, PERM_MANAGE_ROLES, false},
+		{"moderator may not manage lockouts", []string{"moderator"}, CHANNEL_MEMBER, PERM_MANAGE_LOCKOUTS, false},
+		{"admin may manage roles", []string{"admin"}, CHANNEL_MEMBER, PERM_MANAGE_ROLES, true},
+		{"admin may access every channel", []string{"admin"}, CHANNEL_MEMBER, PERM_ACCESS_CHANNELS, true},
+		{"admin may manage channel mods anywhere", []string{"admin"}, CHANNEL_MEMBER, PERM_MANAGE_CHANNEL_MODS, true},
+
+		// custom roles on top of a built in role
+		{"custom role grants its permission", []string{"public", "pinner"}, CHANNEL_MEMBER, PERM_PIN_MESSAGES, true},
+		{"custom role grants nothing else", []string{"public", "pinner"}, CHANNEL_MEMBER, PERM_KICK, false},
+		{"second custom role is checked", []string{"public", "pinner", "gatekeeper"}, CHANNEL_MEMBER, PERM_ACCESS_CHANNELS, true},
+		{"deleted custom role grants nothing", []string{"public", "deleted"}, CHANNEL_MEMBER, PERM_PIN_MESSAGES, false},
+		{"no roles grant nothing", nil, CHANNEL_MEMBER, PERM_CHANGE_TOPIC, false},
+
+		// roles in a channel
+		{"channel owner may change the topic", []string{"public"}, CHANNEL_OWNER, PERM_CHANGE_TOPIC, true},
+		{"channel owner may manage channel mods", []string{"public"}, CHANNEL_OWNER, PERM_MANAGE_CHANNEL_MODS, true},
+		{"channel owner may not ban", []string{"public"}, CHANNEL_OWNER, PERM_BAN, false},
+		{"channel moderator may kick", []string{"public"}, CHANNEL_MODERATOR, PERM_KICK, true},
+		{"channel moderator may pin", []string{"public"}, CHANNEL_MODERATOR, PERM_PIN_MESSAGES, true},
+		{"channel moderator may not manage channel mods", []string{"public"}, CHANNEL_MODERATOR, PERM_MANAGE_CHANNEL_MODS, false},
+		{"channel member may not kick", []string{"public"}, CHANNEL_MEMBER, PERM_KICK, false},
+
+		// permissions that do not exist
+		{"unknown permission for admin", []string{"admin"}, CHANNEL_OWNER, "launch_rockets", false},
+		{"empty permission for admin", []string{"admin"}, CHANNEL_OWNER, "", false},
+		{"unknown permission for custom role", []string{"pinner"}, CHANNEL_MEMBER, "pin", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := has_permission(roles, test.account_roles, test.channel_role, test.permission)
+			if got != test.want {
+				t.Errorf("has_permission(%v, %d, %q) = %t, want %t", test.account_roles, test.channel_role, test.permission, got, test.want)
+			}
+		})
+	}
+}
+
+/*
+ * This function tests authorizing registered accounts against their roles and the channel they act on
+ */
+func Test_authorize(t *testing.T) {
+	// swapping in accounts and custom roles for the test and putting the real ones back after
+	saved_accounts, saved_roles := registered_accounts, custom_roles
+	t.Cleanup(func() {
+		registered_accounts, custom_roles = saved_accounts, saved_roles
+	})
+
+	registered_accounts = []Account_info{
+		{Username: "Alice", Role: 0},
+		{Username: "Bob", Role: 1},
+		{Username: "Carol", Role: 2},
+		{Username: "Dave", Role: 0, Roles: []string{"pinner"}},
+		{Username: "Erin", Role: 0, Roles: []string{"admin"}},
+		{Username: "Frank", Role: 0, Roles: []string{"impostor"}},
+	}
+	custom_roles = map[string][]string{
+		"pinner": {PERM_PIN_MESSAGES},
+
+		// a custom role can not take the name of a built in one to change what it grants
+		"admin":    {PERM_PIN_MESSAGES},
+		"impostor": {PERM_MANAGE_ROLES},
+	}
+	channel := &Channel{Id: 1, Name: "general", Owner: "Alice", Moderators: []string{"Dave"}}
+
+	tests := []struct {
+		name       string
+		username   string
+		permission string
+		channel    *Channel
+		want       bool
+	}{
+		// built in roles
+		{"public user may not ban", "Alice", PERM_BAN, nil, false},
+		{"moderator may ban", "Bob", PERM_BAN, nil, true},
+		{"moderator may not manage roles", "Bob", PERM_MANAGE_ROLES, nil, false},
+		{"admin may manage roles", "Carol", PERM_MANAGE_ROLES, nil, true},
+
+		// custom roles
+		{"custom role grants its permission", "Dave", PERM_PIN_MESSAGES, nil, true},
+		{"custom role grants nothing else", "Dave", PERM_BAN, nil, false},
+		{"custom role named after a built in role grants the built in permissions", "Erin", PERM_MANAGE_ROLES, nil, true},
+		{"custom role may grant manage roles", "Frank", PERM_MANAGE_ROLES, nil, true},
+
+		// roles in a channel
+		{"channel owner may manage channel mods", "Alice", PERM_MANAGE_CHANNEL_MODS, channel, true},
+		{"channel owner may kick", "Alice", PERM_KICK, channel, true},
+		{"channel moderator may kick", "Dave", PERM_KICK, channel, true},
+		{"channel moderator may not manage channel mods", "Dave", PERM_MANAGE_CHANNEL_MODS, channel, false},
+		{"channel member may not kick", "Frank", PERM_KICK, channel, false},
+		{"moderator role may kick in any channel", "Bob", PERM_KICK, channel, true},
+		{"admin may manage channel mods in any channel", "Carol", PERM_MANAGE_CHANNEL_MODS, channel, true},
+
+		// accounts and permissions that do not exist
+		{"unknown user", "Mallory", PERM_CHANGE_TOPIC, nil, false},
+		{"unknown user in a channel", "Mallory", PERM_KICK, channel, false},
+		{"unknown permission", "Carol", "launch_rockets", channel, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got bool
+			if test.channel == nil {
+				got = authorize(test.username, test.permission, CHANNEL_MEMBER)
+			} else {
+				got = authorize_in_channel(test.username, test.permission, test.channel)
+			}
+			if got != test.want {
+				t.Errorf("authorize(%q, %q) = %t, want %t", test.username, test.permission, got, test.want)
+			}
+		})
+	}
+}
+
+/*
+ * This function tests which usernames are accepted when registering, in different alphabets and forms
+ */
+func Test_validate_username(t *testing.T) {
+	// swapping in accounts for the test and putting the real ones back after
+	saved_accounts := registered_accounts
+	t.Cleanup(func() {
+		registered_accounts = saved_accounts
+	})
+	registered_accounts = []Account_info{{Username: "Zoë_taken"}}
+
+	tests := []struct {
+		name     string
+		username string
+		want     bool
+	}{
+		// ascii names
+		{"plain name", "alice", true},
+		{"name with number, dash and underscore", "bob_the-2nd", true},
+		{"too short", "abcd", false},
+		{"shortest name", "abcde", true},
+		{"longest name", "abcdefghijklmnopqrst", true},
+		{"too long", "abcdefghijklmnopqrstu", false},
+		{"starts with a number", "1alice", false},
+		{"ends with a dash", "alice-", false},
+		{"contains a space", "alice smith", false},
+		{"contains punctuation", "alice!", false},
+		{"empty name", "", false},
+
+		// other alphabets
+		{"cyrillic name", "Дмитрий", true},
+		{"greek name", "Αλέξανδρος", true},
+		{"chinese name", "王小明你好", true},
+		{"japanese name mixing kanji and kana", "山田たろう", true},
+		{"korean name", "김민준이다", true},
+		{"cyrillic name with number", "Иван_1990", true},
+
+		// mixed alphabets, used to look like other names
+		{"latin name with cyrillic a", "аlice", false},
+		{"latin name with greek o", "bοbby", false},
+		{"cyrillic name with latin letters", "Иванivan", false},
+
+		// marks and symbols
+		{"precomposed accent", "José_luis", true},
+		{"combining accent", normalize_username("Jose\u0301_luis"), true},
+		{"uncomposed combining accent", "Jose\u0301_luis", false},
+		{"emoji", "alice😀bob", false},
+		{"zero width joiner", "ali\u200dce", false},
+
+		// names that are already taken
+		{"taken name", "Zoë_taken", false},
+		{"taken name typed with a combining mark", normalize_username("Zoe\u0308_taken"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, _ := validate_username(test.username)
+			if got != test.want {
+				t.Errorf("validate_username(%q) = %t, want %t", test.username, got, test.want)
+			}
+		})
+	}
+}
+
+/*
+ * This function tests composing usernames typed with combining marks
+ */
+func Test_normalize_username(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     string
+	}{
+		{"ascii is unchanged", "alice", "alice"},
+		{"precomposed is unchanged", "José", "José"},
+		{"combining acute is composed", "Jose\u0301", "José"},
+		{"combining diaeresis is composed", "Zoe\u0308", "Zoë"},
+		{"cyrillic short i is composed", "Андреи\u0306", "Андрей"},
+		{"hangul jamo are composed", "\u1100\u1161", "가"},
+		{"chinese is unchanged", "王小明", "王小明"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := normalize_username(test.username); got != test.want {
+				t.Errorf("normalize_username(%q) = %q, want %q", test.username, got, test.want)
+			}
+		})
+	}
+}
+
+/*
+ * This function tests finding the users mentioned in a message
+ */
+func Test_mention_regex(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    []string
+	}{
+		{"single mention", "@alice hello", []string{"alice"}},
+		{"mention mid sentence", "hello @alice, how are you", []string{"alice"}},
+		{"several mentions", "@alice and @bob_2", []string{"alice", "bob_2"}},
+		{"trailing dash is not part of the name", "@alice- hi", []string{"alice"}},
+		{"email address is not a mention", "mail alice@example.com", nil},
+		{"name must start with a letter", "@1alice", nil},
+		{"lone at sign", "@ hello", nil},
+		{"cyrillic mention", "привет @Дмитрий!", []string{"Дмитрий"}},
+		{"name after other letters is not a mention", "你好@王小明", nil},
+		{"mention after cjk text", "你好 @王小明 再见", []string{"王小明"}},
+		{"mention after emoji", "😀@alice", []string{"alice"}},
+		{"mention in brackets", "(@alice)", []string{"alice"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, match := range mention_regex.FindAllStringSubmatch(test.message, -1) {
+				got = append(got, match[1])
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("mentions in %q = %q, want %q", test.message, got, test.want)
+			}
+		})
+	}
+}
+
+/*
+ * This function tests keeping the ids of the archived messages of each channel in order as messages
+ * are added, edited, deleted and dropped
+ */
+func Test_index_message(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes []Archived_message
+		want    map[int][]int
+	}{
+		{"messages in order", []Archived_message{{Message_id: 1}, {Message_id: 2}, {Message_id: 3}}, map[int][]int{0: {1, 2, 3}}},
+		{"messages out of order", []Archived_message{{Message_id: 3}, {Message_id: 1}, {Message_id: 2}}, map[int][]int{0: {1, 2, 3}}},
+		{"messages in two channels", []Archived_message{{Message_id: 1}, {Message_id: 2, Channel: 1}, {Message_id: 3}}, map[int][]int{0: {1, 3}, 1: {2}}},
+		{"edit keeps its place", []Archived_message{{Message_id: 1}, {Message_id: 2}, {Message_id: 1, Edited: true}}, map[int][]int{0: {1, 2}}},
+		{"deleted message is taken out", []Archived_message{{Message_id: 1}, {Message_id: 2}, {Message_id: 3}, {Message_id: 2, Deleted: true}}, map[int][]int{0: {1, 3}}},
+		{"deleting a message that is not archived", []Archived_message{{Message_id: 1}, {Message_id: 5, Deleted: true}}, map[int][]int{0: {1}}},
+		{"message moved to another channel", []Archived_message{{Message_id: 1}, {Message_id: 2}, {Message_id: 1, Channel: 1}}, map[int][]int{0: {2}, 1: {1}}},
+	}
+
+	// putting the real archive back after the test
+	saved_archive, saved_channels, saved_index := archive, channel_archive, search_index
+	t.Cleanup(func() {
+		archive, channel_archive, search_index = saved_archive, saved_channels, saved_index
+	})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archive = make(map[int]Archived_message)
+			channel_archive = make(map[int][]int)
+			search_index = make(map[string]map[int]bool)
+
+			for _, change := range test.changes {
+				index_message(change)
+			}
+			for channel, want := range test.want {
+				if got := channel_archive[channel]; !slices.Equal(got, want) {
+					t.Errorf("ids in channel %d = %v, want %v", channel, got, want)
+				}
+			}
+		})
+	}
+}
+
+/*
+ * This function tests that transfers only connect back to the address of the client's command socket
+ */
+func Test_get_transfer_address(t *testing.T) {
+	client := Client{ip_address: "192.0.2.7"}
+
+	tests := []struct {
+		name    string
+		address string
+		want    string
+	}{
+		{"port of the client", "127.0.0.1:40000", "192.0.2.7:40000"},
+		{"other host is replaced", "10.0.0.1:22", "192.0.2.7:22"},
+		{"host name is replaced", "example.com:80", "192.0.2.7:80"},
+		{"ipv6 host is replaced", "[::1]:40000", "192.0.2.7:40000"},
+		{"missing port", "127.0.0.1", ""},
+		{"empty address", "", ""},
+		{"port zero", "127.0.0.1:0", ""},
+		{"port too high", "127.0.0.1:65536", ""},
+		{"named port", "127.0.0.1:http", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := get_transfer_address(client, test.address); got != test.want {
+				t.Errorf("get_transfer_address(%q) = %q, want %q", test.address, got, test.want)
+			}
+		})
+	}
+}